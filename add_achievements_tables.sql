@@ -0,0 +1,13 @@
+CREATE TABLE achievements(
+	uid TEXT REFERENCES users(id),
+	aid TEXT,
+	awarded TEXT,
+	PRIMARY KEY (uid, aid)
+);
+
+CREATE TABLE donations(
+	giver TEXT REFERENCES users(id),
+	receiver TEXT REFERENCES users(id),
+	ts TEXT,
+	amount INT
+);
@@ -0,0 +1,7 @@
+CREATE TABLE adjustments(
+	uid TEXT REFERENCES users(id),
+	ts TEXT,
+	amount INT,
+	operator TEXT,
+	reason TEXT
+);
@@ -0,0 +1,6 @@
+CREATE TABLE api_tokens(
+	hash TEXT PRIMARY KEY,
+	uid TEXT REFERENCES users(id),
+	name TEXT,
+	created TEXT
+);
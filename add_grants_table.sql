@@ -0,0 +1,7 @@
+CREATE TABLE grants(
+	uid TEXT REFERENCES users(id),
+	ts TEXT,
+	kind TEXT,
+	amount INT,
+	streak INT
+);
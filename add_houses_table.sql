@@ -0,0 +1,13 @@
+CREATE TABLE houses(
+	id TEXT PRIMARY KEY REFERENCES users(id)
+);
+
+DROP VIEW IF EXISTS leaderboard;
+CREATE VIEW leaderboard(id, balance, rank) AS
+SELECT id, balance, row_number() OVER()
+FROM (
+  SELECT id, balance
+  FROM users
+  WHERE id NOT IN (SELECT id FROM houses)
+  ORDER BY balance DESC
+);
@@ -0,0 +1,5 @@
+CREATE TABLE identities(
+	identity TEXT PRIMARY KEY,
+	uid TEXT REFERENCES users(id),
+	linked TEXT
+);
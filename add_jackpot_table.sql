@@ -0,0 +1,6 @@
+CREATE TABLE jackpot(
+	ts TEXT,
+	eid TEXT REFERENCES events(id),
+	amount INT,
+	kind TEXT
+);
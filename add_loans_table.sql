@@ -0,0 +1,7 @@
+CREATE TABLE loans(
+	uid TEXT PRIMARY KEY REFERENCES users(id),
+	owed INT,
+	taken TEXT,
+	charges INT,
+	defaulted INT
+);
@@ -0,0 +1,6 @@
+CREATE TABLE phases(
+	eid TEXT REFERENCES events(id),
+	ended TEXT,
+	encounters INT,
+	PRIMARY KEY (eid, ended)
+);
@@ -0,0 +1,9 @@
+CREATE TABLE results(
+	uid TEXT REFERENCES users(id),
+	eid TEXT REFERENCES events(id),
+	placed TEXT,
+	resolved TEXT,
+	outcome INT,
+	payout INT,
+	PRIMARY KEY (uid, placed)
+);
@@ -0,0 +1,8 @@
+CREATE TABLE seasons(
+	season INT,
+	ended TEXT,
+	uid TEXT REFERENCES users(id),
+	balance INT,
+	rank INT,
+	PRIMARY KEY (season, uid)
+);
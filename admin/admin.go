@@ -0,0 +1,256 @@
+// Package admin serves endpoints for operating the bot on a headless server,
+// where the stdin-based cli can't be used.  Every request must have the admin
+// token from the environment, given as "Authorization: Bearer <token>".
+package admin
+
+import (
+	"bet/core"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var adminReqs = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "admin_requests_total",
+	Help: "Number of requests to the admin endpoints",
+}, []string{"method"})
+
+// User compares a user's balance in core's cache with the database.
+type User struct {
+	UID     string   `json:"uid"`
+	Cached  *Balance `json:"cached"`
+	Stored  *Balance `json:"stored"`
+	Matches bool     `json:"matches"`
+}
+
+// Balance is a user's balance and how much of it is in bets.
+type Balance struct {
+	Balance int `json:"balance"`
+	InBets  int `json:"inBets"`
+}
+
+// Event is the state of an event.
+type Event struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	State   string    `json:"state"`
+	Opened  time.Time `json:"opened"`
+	Current int       `json:"current"`
+}
+
+// LogLevel is the body of log level requests and responses.
+type LogLevel struct {
+	Level string `json:"level"`
+}
+
+// Error is the body of every unsuccessful response.
+type Error struct {
+	Error string `json:"error"`
+}
+
+// Handler returns the handler for every admin endpoint, which only accepts
+// requests with the token.  level is the level of the program's logger.
+func Handler(c *core.Core, token string, level *slog.LevelVar) http.Handler {
+	h := &handler{core: c, level: level}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin/users", h.users)
+	mux.HandleFunc("POST /admin/users/refresh", h.refreshBalance)
+	mux.HandleFunc("GET /admin/events", h.events)
+	mux.HandleFunc("POST /admin/events/{eid}/{transition}", h.transition)
+	mux.HandleFunc("GET /admin/crons", h.crons)
+	mux.HandleFunc("POST /admin/crons/{id}/run", h.runCron)
+	mux.HandleFunc("GET /admin/log-level", h.logLevel)
+	mux.HandleFunc("PUT /admin/log-level", h.setLogLevel)
+	return authenticate(token, mux)
+}
+
+type handler struct {
+	core  *core.Core
+	level *slog.LevelVar
+}
+
+// authenticate rejects requests without the token.  An empty token rejects
+// every request.
+func authenticate(token string, next http.Handler) http.Handler {
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		adminReqs.WithLabelValues(r.Method).Inc()
+		got := []byte(r.Header.Get("Authorization"))
+		if token == "" || subtle.ConstantTimeCompare(got, want) != 1 {
+			slog.Warn(fmt.Sprintf("unauthorized admin request from %s", r.RemoteAddr))
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *handler) users(w http.ResponseWriter, r *http.Request) {
+	cached, err := h.core.CompareUsers()
+	if err != nil {
+		internalError(w, "compare users", err)
+		return
+	}
+	users := make([]User, 0, len(cached))
+	for _, u := range cached {
+		users = append(users, User{
+			UID:     u.UID,
+			Cached:  balance(u.Cached),
+			Stored:  balance(u.Stored),
+			Matches: u.Matches(),
+		})
+	}
+	writeJSON(w, http.StatusOK, users)
+}
+
+// refreshBalance bails out users the same as after an event resolves, and
+// responds with the achievements unlocked.
+func (h *handler) refreshBalance(w http.ResponseWriter, r *http.Request) {
+	unlocks, err := h.core.RefreshBalance()
+	if err != nil {
+		internalError(w, "refresh balances", err)
+		return
+	}
+	out := make([]string, 0, len(unlocks))
+	for _, u := range unlocks {
+		out = append(out, u.String())
+	}
+	slog.Info(fmt.Sprintf("admin refreshed balances, %d unlocks", len(unlocks)))
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (h *handler) events(w http.ResponseWriter, r *http.Request) {
+	es := make([]Event, 0)
+	for _, eid := range h.core.Events() {
+		event, err := h.core.GetEvent(eid)
+		if err != nil {
+			internalError(w, "get event", err)
+			return
+		}
+		es = append(es, adminEvent(event.Status()))
+	}
+	writeJSON(w, http.StatusOK, es)
+}
+
+// transition forces the event to open, close or resolve, and responds with the
+// event's state afterwards.
+func (h *handler) transition(w http.ResponseWriter, r *http.Request) {
+	eid := r.PathValue("eid")
+	event, ok := h.getEvent(eid)
+	if !ok {
+		writeError(w, http.StatusNotFound, "event not found")
+		return
+	}
+	var err error
+	switch transition := r.PathValue("transition"); transition {
+	case "open":
+		err = event.Open(time.Now())
+	case "close":
+		err = event.Close(time.Now())
+	case "resolve":
+		err = event.Resolve()
+	default:
+		writeError(w, http.StatusNotFound, fmt.Sprintf("unknown transition %q, must be one of open, close or resolve", transition))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	slog.Info(fmt.Sprintf("admin forced %s of event %s", r.PathValue("transition"), eid))
+	writeJSON(w, http.StatusOK, adminEvent(event.Status()))
+}
+
+func (h *handler) crons(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.core.Crons())
+}
+
+func (h *handler) runCron(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	err := h.core.RunCron(id)
+	if errors.Is(err, core.CronNotFoundError{ID: id}) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if errors.Is(err, core.CronRunningError{ID: id}) {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		internalError(w, "run cron", err)
+		return
+	}
+	slog.Info(fmt.Sprintf("admin ran cron %s", id))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) logLevel(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, LogLevel{Level: h.level.Level().String()})
+}
+
+// setLogLevel changes the log level, e.g. to "debug" or "info" like the cli's
+// debug command.
+func (h *handler) setLogLevel(w http.ResponseWriter, r *http.Request) {
+	var body LogLevel
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid body: %v", err))
+		return
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(body.Level)); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown log level %q", body.Level))
+		return
+	}
+	h.level.Set(level)
+	slog.Info(fmt.Sprintf("admin set log level to %s", level))
+	writeJSON(w, http.StatusOK, LogLevel{Level: level.String()})
+}
+
+// getEvent returns the registered event, without GetEvent's warning for
+// unknown ids.
+func (h *handler) getEvent(eid string) (core.Event, bool) {
+	for _, id := range h.core.Events() {
+		if id == eid {
+			event, err := h.core.GetEvent(eid)
+			return event, err == nil
+		}
+	}
+	return nil, false
+}
+
+func balance(b *core.UserBalance) *Balance {
+	if b == nil {
+		return nil
+	}
+	return &Balance{Balance: b.Balance, InBets: b.InBets}
+}
+
+func adminEvent(s core.EventStatus) Event {
+	return Event{ID: s.ID, Name: s.Name, State: string(s.State), Opened: s.Opened, Current: s.Current}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Warn(fmt.Sprintf("could not write admin response: %v", err))
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, Error{Error: message})
+}
+
+// internalError logs the error and responds with it, since only admins can
+// see it.
+func internalError(w http.ResponseWriter, context string, err error) {
+	slog.Warn(fmt.Sprintf("admin could not %s: %v", context, err))
+	writeError(w, http.StatusInternalServerError, err.Error())
+}
@@ -0,0 +1,155 @@
+package admin
+
+import (
+	"bet/core"
+	"bet/core/db"
+	"bet/core/events"
+	"bet/env"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testToken = "secret"
+
+type fakeCron struct {
+	runs int
+}
+
+func (f *fakeCron) ID() string           { return "fake" }
+func (f *fakeCron) After() time.Duration { return time.Hour }
+func (f *fakeCron) Run() error           { f.runs++; return nil }
+
+type clock struct{}
+
+func (clock) Now() time.Time                         { return time.Now() }
+func (clock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+func newTestServer(t *testing.T) (*httptest.Server, *core.Core, *slog.LevelVar) {
+	t.Helper()
+	d := db.FakeWithUsers()
+	c := core.New(d, nil, clock{})
+	if err := c.RegisterEvent("shiny", events.NewShinyEvent(c, env.OddsConfig{Base: 2}, env.PhaseEventConfig{}, "")); err != nil {
+		t.Fatalf("RegisterEvent() returned unexpected error: %v", err)
+	}
+	level := new(slog.LevelVar)
+	s := httptest.NewServer(Handler(c, testToken, level))
+	t.Cleanup(s.Close)
+	return s, c, level
+}
+
+// do makes a request to the server, and decodes the response into out when
+// it's given.
+func do(t *testing.T, s *httptest.Server, token, method, path, body string, out any) int {
+	t.Helper()
+	req, err := http.NewRequest(method, s.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("could not make request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := s.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("could not decode response of %s %s: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestAuthentication(t *testing.T) {
+	s, _, _ := newTestServer(t)
+	if status := do(t, s, "wrong", "GET", "/admin/users", "", nil); status != http.StatusUnauthorized {
+		t.Errorf("GET /admin/users with the wrong token = %d, want %d", status, http.StatusUnauthorized)
+	}
+	empty := httptest.NewServer(Handler(nil, "", new(slog.LevelVar)))
+	defer empty.Close()
+	if status := do(t, empty, "", "GET", "/admin/users", "", nil); status != http.StatusUnauthorized {
+		t.Errorf("GET /admin/users without a configured token = %d, want %d", status, http.StatusUnauthorized)
+	}
+}
+
+func TestUsers(t *testing.T) {
+	s, c, _ := newTestServer(t)
+	if _, err := c.GetUser("user1"); err != nil {
+		t.Fatalf("GetUser() returned unexpected error: %v", err)
+	}
+	var users []User
+	if status := do(t, s, testToken, "GET", "/admin/users", "", &users); status != http.StatusOK {
+		t.Fatalf("GET /admin/users = %d", status)
+	}
+	if len(users) != 1 || users[0].UID != "user1" || !users[0].Matches || users[0].Cached.Balance != core.StartingBalance {
+		t.Errorf("GET /admin/users = %+v, want user1 matching the database", users)
+	}
+	var unlocks []string
+	if status := do(t, s, testToken, "POST", "/admin/users/refresh", "", &unlocks); status != http.StatusOK || len(unlocks) != 0 {
+		t.Errorf("POST /admin/users/refresh = %d %v, want nobody bailed out", status, unlocks)
+	}
+}
+
+func TestTransition(t *testing.T) {
+	s, _, _ := newTestServer(t)
+	var e Event
+	if status := do(t, s, testToken, "POST", "/admin/events/shiny/close", "", &e); status != http.StatusOK {
+		t.Fatalf("POST /admin/events/shiny/close = %d", status)
+	}
+	if e.State != "closing" {
+		t.Errorf("after closing, the event is %s, want closing", e.State)
+	}
+	var errBody Error
+	if status := do(t, s, testToken, "POST", "/admin/events/shiny/close", "", &errBody); status != http.StatusConflict {
+		t.Errorf("closing twice = %d %+v, want %d", status, errBody, http.StatusConflict)
+	}
+	if status := do(t, s, testToken, "POST", "/admin/events/shiny/explode", "", &errBody); status != http.StatusNotFound {
+		t.Errorf("unknown transition = %d, want %d", status, http.StatusNotFound)
+	}
+	if status := do(t, s, testToken, "POST", "/admin/events/anti/open", "", &errBody); status != http.StatusNotFound {
+		t.Errorf("unknown event = %d, want %d", status, http.StatusNotFound)
+	}
+}
+
+func TestRunCron(t *testing.T) {
+	s, c, _ := newTestServer(t)
+	// The cron just ran, so only running it from the endpoint runs it again.
+	tx, _ := c.Database.OpenTransaction()
+	tx.WriteCronRun("fake", time.Now())
+	cron := &fakeCron{}
+	c.AddCron(cron)
+	var ids []string
+	if status := do(t, s, testToken, "GET", "/admin/crons", "", &ids); status != http.StatusOK || len(ids) != 1 || ids[0] != "fake" {
+		t.Errorf("GET /admin/crons = %d %v, want [fake]", status, ids)
+	}
+	if status := do(t, s, testToken, "POST", "/admin/crons/fake/run", "", nil); status != http.StatusNoContent {
+		t.Errorf("POST /admin/crons/fake/run = %d, want %d", status, http.StatusNoContent)
+	}
+	if cron.runs != 1 {
+		t.Errorf("cron ran %d times, want 1", cron.runs)
+	}
+	if status := do(t, s, testToken, "POST", "/admin/crons/other/run", "", nil); status != http.StatusNotFound {
+		t.Errorf("POST /admin/crons/other/run = %d, want %d", status, http.StatusNotFound)
+	}
+}
+
+func TestLogLevel(t *testing.T) {
+	s, _, level := newTestServer(t)
+	var got LogLevel
+	if status := do(t, s, testToken, "PUT", "/admin/log-level", `{"level": "debug"}`, &got); status != http.StatusOK {
+		t.Fatalf("PUT /admin/log-level = %d", status)
+	}
+	if level.Level() != slog.LevelDebug || got.Level != "DEBUG" {
+		t.Errorf("after setting debug, level is %s and response %+v", level.Level(), got)
+	}
+	if status := do(t, s, testToken, "PUT", "/admin/log-level", `{"level": "loud"}`, nil); status != http.StatusBadRequest {
+		t.Errorf("PUT an unknown level = %d, want %d", status, http.StatusBadRequest)
+	}
+	if status := do(t, s, testToken, "GET", "/admin/log-level", "", &got); status != http.StatusOK || got.Level != "DEBUG" {
+		t.Errorf("GET /admin/log-level = %d %+v, want DEBUG", status, got)
+	}
+}
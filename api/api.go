@@ -0,0 +1,129 @@
+// Package api serves an HTTP API for reading balances, events and bets, and for
+// placing and cancelling bets, so overlays and web frontends don't need to go
+// through a chat platform.  Every request must have an API token from
+// core.CreateAPIToken, given as "Authorization: Bearer <token>", and acts as
+// the user the token belongs to.
+package api
+
+import (
+	"bet/core"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Server serves the API on its own address, separate from the state listener.
+type Server struct {
+	server http.Server
+}
+
+// NewServer starts serving the API on the address in the background.
+func NewServer(c *core.Core, address string) (*Server, error) {
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{}
+	s.server.Handler = Handler(c)
+	go s.server.Serve(l)
+	slog.Info(fmt.Sprintf("api listening on %s", l.Addr()))
+	return s, nil
+}
+
+func (s *Server) Close() error {
+	return s.server.Close()
+}
+
+type contextKey struct{}
+
+// Handler returns the handler for every API endpoint.
+func Handler(c *core.Core) http.Handler {
+	h := &handler{core: c}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/me", h.me)
+	mux.HandleFunc("GET /api/users/{uid}", h.user)
+	mux.HandleFunc("GET /api/users/{uid}/bets", h.userBets)
+	mux.HandleFunc("GET /api/leaderboard", h.leaderboard)
+	mux.HandleFunc("GET /api/events", h.events)
+	mux.HandleFunc("GET /api/events/{eid}", h.event)
+	mux.HandleFunc("GET /api/events/{eid}/bets", h.eventBets)
+	mux.HandleFunc("POST /api/events/{eid}/bets", h.placeBet)
+	mux.HandleFunc("DELETE /api/events/{eid}/bets/{id}", h.cancelBet)
+	return h.authenticate(mux)
+}
+
+type handler struct {
+	core *core.Core
+}
+
+// authenticate rejects requests without a valid API token, and adds the uid of
+// the token's user to the request's context.
+func (h *handler) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiReqs.WithLabelValues(r.Method).Inc()
+		token, ok := bearerToken(r)
+		if !ok {
+			writeError(w, http.StatusUnauthorized, "missing api token")
+			return
+		}
+		uid, err := h.core.APITokenUser(token)
+		if errors.Is(err, core.InvalidTokenError{}) {
+			writeError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		if err != nil {
+			slog.Warn(fmt.Sprintf("could not look up api token: %v", err))
+			writeError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, uid)))
+	})
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	const prefix = "Bearer "
+	auth := r.Header.Get("Authorization")
+	if len(auth) <= len(prefix) || auth[:len(prefix)] != prefix {
+		return "", false
+	}
+	return auth[len(prefix):], true
+}
+
+// caller is the uid of the user the request's API token belongs to.
+func caller(r *http.Request) string {
+	return r.Context().Value(contextKey{}).(string)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Warn(fmt.Sprintf("could not write api response: %v", err))
+	}
+}
+
+// Error is the body of every unsuccessful response.
+type Error struct {
+	Error string `json:"error"`
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, Error{Error: message})
+}
+
+// internalError logs the error and responds without leaking its details.
+func internalError(w http.ResponseWriter, context string, err error) {
+	slog.Warn(fmt.Sprintf("api could not %s: %v", context, err))
+	writeError(w, http.StatusInternalServerError, "internal error")
+}
+
+// betID identifies a user's bet by when it was placed, which is unique per
+// user.  Bets are stored to the second.
+func betID(placed time.Time) int64 {
+	return placed.Unix()
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"bet/core"
+	"bet/core/db"
+	"bet/core/events"
+	"bet/env"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestServer(t *testing.T) (*httptest.Server, string) {
+	t.Helper()
+	c := core.New(db.Fake(), nil, nil)
+	c.SetCancelWindow(time.Minute)
+	if err := c.RegisterEvent("shiny", events.NewShinyEvent(c, env.OddsConfig{Base: 2}, env.PhaseEventConfig{}, "")); err != nil {
+		t.Fatalf("RegisterEvent() returned unexpected error: %v", err)
+	}
+	token, err := c.CreateAPIToken("user1", "test", time.Now())
+	if err != nil {
+		t.Fatalf("CreateAPIToken() returned unexpected error: %v", err)
+	}
+	s := httptest.NewServer(Handler(c))
+	t.Cleanup(s.Close)
+	return s, token
+}
+
+// do makes a request to the server, and decodes the response into out when
+// it's given.
+func do(t *testing.T, s *httptest.Server, token, method, path, body string, out any) int {
+	t.Helper()
+	req, err := http.NewRequest(method, s.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("could not make request: %v", err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := s.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("could not decode response of %s %s: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestAuthentication(t *testing.T) {
+	s, _ := newTestServer(t)
+	var e Error
+	if status := do(t, s, "", "GET", "/api/me", "", &e); status != http.StatusUnauthorized {
+		t.Errorf("GET /api/me without a token = %d, want %d", status, http.StatusUnauthorized)
+	}
+	if status := do(t, s, "not-a-token", "GET", "/api/me", "", &e); status != http.StatusUnauthorized || e.Error != "invalid api token" {
+		t.Errorf("GET /api/me with a bad token = %d %+v, want %d", status, e, http.StatusUnauthorized)
+	}
+}
+
+func TestReads(t *testing.T) {
+	s, token := newTestServer(t)
+	var u User
+	if status := do(t, s, token, "GET", "/api/me", "", &u); status != http.StatusOK {
+		t.Fatalf("GET /api/me = %d", status)
+	}
+	if want := (User{UID: "user1", Balance: 1000}); u != want {
+		t.Errorf("GET /api/me = %+v, want %+v", u, want)
+	}
+	var e Error
+	if status := do(t, s, token, "GET", "/api/users/nobody", "", &e); status != http.StatusNotFound {
+		t.Errorf("GET /api/users/nobody = %d, want %d", status, http.StatusNotFound)
+	}
+	var es []Event
+	if status := do(t, s, token, "GET", "/api/events", "", &es); status != http.StatusOK {
+		t.Fatalf("GET /api/events = %d", status)
+	}
+	if len(es) != 1 || es[0].ID != "shiny" || es[0].State != "open" {
+		t.Errorf("GET /api/events = %+v, want the open shiny event", es)
+	}
+	if status := do(t, s, token, "GET", "/api/events/anti", "", &e); status != http.StatusNotFound {
+		t.Errorf("GET /api/events/anti = %d, want %d", status, http.StatusNotFound)
+	}
+	var ranks []Rank
+	if status := do(t, s, token, "GET", "/api/leaderboard?page=0", "", &e); status != http.StatusBadRequest {
+		t.Errorf("GET /api/leaderboard?page=0 = %d, want %d", status, http.StatusBadRequest)
+	}
+	if status := do(t, s, token, "GET", "/api/leaderboard", "", &ranks); status != http.StatusOK {
+		t.Errorf("GET /api/leaderboard = %d", status)
+	}
+}
+
+func TestPlaceAndCancelBet(t *testing.T) {
+	s, token := newTestServer(t)
+	var e Error
+	if status := do(t, s, token, "POST", "/api/events/shiny/bets", `{"amount": 100, "direction": "sideways", "phase": 3}`, &e); status != http.StatusBadRequest {
+		t.Errorf("POST with a bad direction = %d, want %d", status, http.StatusBadRequest)
+	}
+	if status := do(t, s, token, "POST", "/api/events/shiny/bets", `{"amount": 10000, "direction": "over", "phase": 3}`, &e); status != http.StatusBadRequest {
+		t.Errorf("POST more than the balance = %d %+v, want %d", status, e, http.StatusBadRequest)
+	}
+
+	var placed PlacedBet
+	if status := do(t, s, token, "POST", "/api/events/shiny/bets", `{"amount": 100, "direction": "under", "phase": 3}`, &placed); status != http.StatusCreated {
+		t.Fatalf("POST /api/events/shiny/bets = %d", status)
+	}
+	if placed.UID != "user1" || placed.Amount != 100 || placed.Bet.Bet != "phase < 3" {
+		t.Errorf("POST /api/events/shiny/bets = %+v", placed)
+	}
+	var bets []Bet
+	if status := do(t, s, token, "GET", "/api/events/shiny/bets", "", &bets); status != http.StatusOK {
+		t.Fatalf("GET /api/events/shiny/bets = %d", status)
+	}
+	if len(bets) != 1 || bets[0].ID != placed.ID || bets[0].Bet != placed.Bet.Bet {
+		t.Errorf("GET /api/events/shiny/bets = %+v, want the placed bet %+v", bets, placed.Bet.Bet)
+	}
+	var u User
+	do(t, s, token, "GET", "/api/me", "", &u)
+	if u.Balance != 1000 || u.InBets != 100 {
+		t.Errorf("after betting, GET /api/me = %+v, want 100 of 1000 in bets", u)
+	}
+
+	cancel := fmt.Sprintf("/api/events/shiny/bets/%d", placed.ID)
+	if status := do(t, s, token, "DELETE", cancel, "", nil); status != http.StatusNoContent {
+		t.Fatalf("DELETE %s = %d, want %d", cancel, status, http.StatusNoContent)
+	}
+	if status := do(t, s, token, "DELETE", cancel, "", &e); status != http.StatusNotFound {
+		t.Errorf("DELETE %s twice = %d, want %d", cancel, status, http.StatusNotFound)
+	}
+	do(t, s, token, "GET", "/api/me", "", &u)
+	if u.Balance != 1000 || u.InBets != 0 {
+		t.Errorf("after cancelling, GET /api/me = %+v, want the bet refunded", u)
+	}
+}
@@ -0,0 +1,325 @@
+package api
+
+import (
+	"bet/core"
+	"bet/core/db"
+	"bet/core/events"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var apiReqs = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "api_requests_total",
+	Help: "Number of requests to the API",
+}, []string{"method"})
+
+// leaderboardPageSize is how many users are in each page of the leaderboard.
+const leaderboardPageSize = 10
+
+// User is a user's balance.
+type User struct {
+	UID     string `json:"uid"`
+	Balance int    `json:"balance"`
+	InBets  int    `json:"inBets"`
+}
+
+// Event is the state of an event.
+type Event struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	State string `json:"state"`
+	// Opened is when the event last opened, if it's known.
+	Opened *time.Time `json:"opened,omitempty"`
+	// Current is the number of encounters in the current phase for phase
+	// events.
+	Current int `json:"current"`
+}
+
+// Bet is an open bet.  Its id is used to cancel it.
+type Bet struct {
+	ID     int64     `json:"id"`
+	UID    string    `json:"uid"`
+	Event  string    `json:"event"`
+	Placed time.Time `json:"placed"`
+	Amount int       `json:"amount"`
+	Risk   float64   `json:"risk"`
+	Bet    string    `json:"bet"`
+}
+
+// Rank is a user's place on the leaderboard.
+type Rank struct {
+	Rank  int     `json:"rank"`
+	UID   string  `json:"uid"`
+	Value float64 `json:"value"`
+}
+
+// PlaceBet is the body of a request to place a bet.  Phase events take a
+// direction (one of "over", "under" or "exactly") and phase, and item events
+// take a guess.
+type PlaceBet struct {
+	Amount    int    `json:"amount"`
+	Direction string `json:"direction,omitempty"`
+	Phase     int    `json:"phase,omitempty"`
+	Guess     *bool  `json:"guess,omitempty"`
+}
+
+// PlacedBet is the response to placing a bet.
+type PlacedBet struct {
+	Bet
+	// Payout is what the bank pays if the bet wins, for events that pay out
+	// at fixed odds.
+	Payout int `json:"payout,omitempty"`
+}
+
+var directions = map[string]int{
+	"over":    events.GREATER,
+	"under":   events.LESS,
+	"exactly": events.EQUAL,
+}
+
+func (h *handler) me(w http.ResponseWriter, r *http.Request) {
+	h.writeUser(w, caller(r))
+}
+
+func (h *handler) user(w http.ResponseWriter, r *http.Request) {
+	uid := h.core.Account(r.PathValue("uid"))
+	if !h.core.UserExists(uid) {
+		writeError(w, http.StatusNotFound, "user not found")
+		return
+	}
+	h.writeUser(w, uid)
+}
+
+func (h *handler) writeUser(w http.ResponseWriter, uid string) {
+	u, err := h.core.GetUser(uid)
+	if err != nil {
+		internalError(w, "get user", err)
+		return
+	}
+	balance, inBets, err := u.Balance()
+	if err != nil {
+		internalError(w, "get balance", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, User{UID: uid, Balance: balance, InBets: inBets})
+}
+
+func (h *handler) userBets(w http.ResponseWriter, r *http.Request) {
+	bets, err := h.core.UserBets(h.core.Account(r.PathValue("uid")))
+	if err != nil {
+		internalError(w, "load user bets", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, apiBets(bets))
+}
+
+func (h *handler) leaderboard(w http.ResponseWriter, r *http.Request) {
+	by := r.URL.Query().Get("by")
+	if by == "" {
+		by = db.RankBalance
+	}
+	page := 1
+	if p := r.URL.Query().Get("page"); p != "" {
+		var err error
+		page, err = strconv.Atoi(p)
+		if err != nil || page < 1 {
+			writeError(w, http.StatusBadRequest, "page must be a positive number")
+			return
+		}
+	}
+	rows, err := h.core.Database.Ranking(by, (page-1)*leaderboardPageSize, leaderboardPageSize)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown ranking %q", by))
+		return
+	}
+	ranks := make([]Rank, 0)
+	for rows.Next() {
+		var rank Rank
+		if err := rows.Scan(&rank.Rank, &rank.UID, &rank.Value); err != nil {
+			internalError(w, "scan leaderboard", err)
+			return
+		}
+		ranks = append(ranks, rank)
+	}
+	writeJSON(w, http.StatusOK, ranks)
+}
+
+func (h *handler) events(w http.ResponseWriter, r *http.Request) {
+	es := make([]Event, 0)
+	for _, eid := range h.core.Events() {
+		event, err := h.core.GetEvent(eid)
+		if err != nil {
+			internalError(w, "get event", err)
+			return
+		}
+		es = append(es, apiEvent(event.Status()))
+	}
+	writeJSON(w, http.StatusOK, es)
+}
+
+func (h *handler) event(w http.ResponseWriter, r *http.Request) {
+	event, ok := h.getEvent(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, apiEvent(event.Status()))
+}
+
+func (h *handler) eventBets(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.getEvent(w, r); !ok {
+		return
+	}
+	bets, err := h.core.Bets(r.PathValue("eid"))
+	if err != nil {
+		internalError(w, "load bets", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, apiBets(bets))
+}
+
+// placeBet places a bet through core's Wager, the same as betting from
+// chat.
+func (h *handler) placeBet(w http.ResponseWriter, r *http.Request) {
+	event, ok := h.getEvent(w, r)
+	if !ok {
+		return
+	}
+	var body PlaceBet
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid body: %v", err))
+		return
+	}
+	if body.Amount <= 0 {
+		writeError(w, http.StatusBadRequest, "amount must be positive")
+		return
+	}
+	var bet any
+	var description string
+	if body.Guess != nil {
+		bet = *body.Guess
+		description = event.Interpret(strconv.FormatBool(*body.Guess))
+	} else {
+		direction, ok := directions[body.Direction]
+		if !ok {
+			writeError(w, http.StatusBadRequest, "direction must be one of over, under or exactly")
+			return
+		}
+		b := events.PhaseBet{Direction: direction, Phase: body.Phase}
+		bet = b
+		description = b.String()
+	}
+	uid := caller(r)
+	// Bets are stored to the second, in UTC so the bet's id matches when it's
+	// loaded back.
+	placed := time.Now().UTC().Truncate(time.Second)
+	result, err := h.core.Wager(r.PathValue("eid"), uid, body.Amount, placed, bet)
+	if err != nil {
+		writeWagerError(w, err)
+		return
+	}
+	response := PlacedBet{Bet: Bet{
+		ID:     betID(placed),
+		UID:    uid,
+		Event:  r.PathValue("eid"),
+		Placed: placed,
+		Amount: body.Amount,
+		Bet:    description,
+	}}
+	switch p := result.(type) {
+	case events.PlacedPhaseBet:
+		response.Risk = p.Risk
+		response.Payout = p.Payout
+	case float64:
+		response.Risk = p
+	}
+	writeJSON(w, http.StatusCreated, response)
+}
+
+func (h *handler) cancelBet(w http.ResponseWriter, r *http.Request) {
+	event, ok := h.getEvent(w, r)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bet id must be a number")
+		return
+	}
+	if err := event.Cancel(caller(r), time.Unix(id, 0).UTC(), time.Now()); err != nil {
+		writeWagerError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) getEvent(w http.ResponseWriter, r *http.Request) (core.Event, bool) {
+	eid := r.PathValue("eid")
+	for _, id := range h.core.Events() {
+		if id == eid {
+			event, err := h.core.GetEvent(eid)
+			if err != nil {
+				internalError(w, "get event", err)
+				return nil, false
+			}
+			return event, true
+		}
+	}
+	writeError(w, http.StatusNotFound, "event not found")
+	return nil, false
+}
+
+// writeWagerError responds with why a bet couldn't be placed or cancelled.
+func writeWagerError(w http.ResponseWriter, err error) {
+	var balanceErr *core.BalanceError
+	var limitErr core.LimitError
+	var windowErr core.CancelWindowError
+	var lockoutErr events.LockoutError
+	switch {
+	case errors.Is(err, core.BetNotFoundError{}):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.As(err, &balanceErr),
+		errors.As(err, &limitErr),
+		errors.As(err, &windowErr),
+		errors.As(err, &lockoutErr),
+		errors.Is(err, events.PhaseLengthError{}),
+		errors.Is(err, events.NoRiskError{}),
+		errors.Is(err, events.BankError{}):
+		writeError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, events.BettingClosedError{}),
+		errors.Is(err, core.DuplicateBetError{}):
+		writeError(w, http.StatusConflict, err.Error())
+	default:
+		internalError(w, "place or cancel bet", err)
+	}
+}
+
+func apiEvent(s core.EventStatus) Event {
+	e := Event{ID: s.ID, Name: s.Name, State: string(s.State), Current: s.Current}
+	if !s.Opened.IsZero() {
+		e.Opened = &s.Opened
+	}
+	return e
+}
+
+func apiBets(bets []core.Bet) []Bet {
+	out := make([]Bet, 0, len(bets))
+	for _, b := range bets {
+		out = append(out, Bet{
+			ID:     betID(b.Placed),
+			UID:    b.UID,
+			Event:  b.EID,
+			Placed: b.Placed,
+			Amount: b.Amount,
+			Risk:   b.Risk,
+			Bet:    b.Bet,
+		})
+	}
+	return out
+}
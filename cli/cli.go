@@ -4,58 +4,356 @@
 package cli
 
 import (
+	"bet/core"
+	"bet/env"
 	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
+	"strconv"
 	"strings"
+	"text/tabwriter"
+	"time"
 )
 
 var LogLevel = new(slog.LevelVar)
 
-func Loop() {
-	reader := bufio.NewReader(os.Stdin)
+// Prompt is written before reading each command.
+const Prompt = "> "
+
+// oddsEvents are the events whose per-encounter probability follows the hunt's
+// odds.
+var oddsEvents = []string{"shiny", "anti"}
+
+type probabilityEvent interface {
+	Probability() float64
+	SetProbability(float64) error
+}
+
+// Console reads commands from an operator and writes the results back.
+type Console struct {
+	core *core.Core
+	in   *bufio.Reader
+	out  io.Writer
+	// Reload reloads the configuration for the `reload` command.  When nil,
+	// the configuration can't be reloaded.
+	Reload func() error
+	// Operator is who is running the commands, as recorded with the balance
+	// adjustments they make.
+	Operator string
+}
+
+// NewConsole makes a console reading commands from in and writing to out.
+func NewConsole(c *core.Core, in io.Reader, out io.Writer) *Console {
+	return &Console{core: c, in: bufio.NewReader(in), out: out, Operator: "console"}
+}
+
+// Loop runs commands from stdin until it's closed.
+func Loop(c *core.Core) {
+	NewConsole(c, os.Stdin, os.Stdout).Loop()
+}
+
+// Loop runs commands until the input is closed.
+func (c *Console) Loop() {
 	for {
-		fmt.Printf("> ")
-		oneCommand(reader)
+		fmt.Fprint(c.out, Prompt)
+		if err := c.oneCommand(); err != nil {
+			if !errors.Is(err, io.EOF) {
+				fmt.Fprintf(c.out, "error: %v\n", err)
+			}
+			return
+		}
+	}
+}
+
+// command is a console command.  Commands are run with between minArgs and
+// maxArgs arguments, or any number above minArgs when maxArgs is negative.
+type command struct {
+	name    string
+	usage   string
+	help    string
+	minArgs int
+	maxArgs int
+	run     func(c *Console, args []string) error
+}
+
+// commands are the console's commands, in the order they're listed by help.
+// It's filled in by init, since help refers to it.
+var commands []command
+
+func init() {
+	commands = []command{
+		{name: "help", usage: "help", help: "list the commands", run: (*Console).handleHelp},
+		{name: "debug", usage: "debug on|off", help: "turn debug logging on or off", minArgs: 1, maxArgs: 1, run: (*Console).handleDebug},
+		{name: "events", usage: "events", help: "list the events and their states", run: (*Console).handleEvents},
+		{name: "event", usage: "event <id> open|close|resolve", help: "force an event to open, close or resolve", minArgs: 2, maxArgs: 2, run: (*Console).handleEvent},
+		{name: "balance", usage: "balance <uid> [+/-amount reason...]", help: "show a user's balance, or adjust it by the amount for the reason", minArgs: 1, maxArgs: -1, run: (*Console).handleBalance},
+		{name: "adjustments", usage: "adjustments <uid>", help: "list who adjusted a user's balance and why", minArgs: 1, maxArgs: 1, run: (*Console).handleAdjustments},
+		{name: "crons", usage: "crons", help: "list the crons with when they last and next run", run: (*Console).handleCrons},
+		{name: "odds", usage: "odds [base] [charm] [method]", help: "show the odds, or change them when the hunt changes, e.g. `odds 4096 charm masuda`", maxArgs: 3, run: (*Console).handleOdds},
+		{name: "token", usage: "token <uid> <name>", help: "make an API token for a user", minArgs: 2, maxArgs: 2, run: (*Console).handleToken},
+		{name: "reload", usage: "reload", help: "reload the configuration", run: (*Console).handleReload},
 	}
 }
 
-func oneCommand(stdin *bufio.Reader) {
+// oneCommand reads and runs one command.  Only errors reading the command are
+// returned, so the console can stop when the input closes.
+func (c *Console) oneCommand() error {
 	defer func() {
 		if r := recover(); r != nil {
 			slog.Error(fmt.Sprintf("recovering from panic in cli: %s", r))
+			fmt.Fprintf(c.out, "error: %s\n", r)
 		}
 	}()
-	command, err := stdin.ReadString('\n')
-	if err != nil {
-		fmt.Printf("error: %v\n", err)
-		return
+	line, err := c.in.ReadString('\n')
+	if err != nil && (line == "" || !errors.Is(err, io.EOF)) {
+		return err
 	}
-	// The combination of these 2 should work on Linux/Windows environments.
-	command = strings.TrimSuffix(command, "\n")
-	command = strings.TrimSuffix(command, "\r")
-	tokens := strings.Split(command, " ")
+	// Fields drops the line ending on both Linux and Windows, and extra spaces
+	// between arguments.
+	tokens := strings.Fields(line)
 	if len(tokens) == 0 {
-		fmt.Println("no command given")
+		return nil
+	}
+	c.run(tokens[0], tokens[1:])
+	return nil
+}
+
+func (c *Console) run(name string, args []string) {
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+		if len(args) < cmd.minArgs || (cmd.maxArgs >= 0 && len(args) > cmd.maxArgs) {
+			fmt.Fprintf(c.out, "usage: %s\n", cmd.usage)
+			return
+		}
+		if err := cmd.run(c, args); err != nil {
+			fmt.Fprintf(c.out, "error: %v\n", err)
+		}
 		return
 	}
-	switch tokens[0] {
-	case "debug":
-		handleDebug(tokens[1:]...)
-	default:
-		fmt.Printf("not a command: %s\n", tokens[0])
+	fmt.Fprintf(c.out, "not a command: %s, try help\n", name)
+}
+
+func (c *Console) handleHelp(args []string) error {
+	w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "%s\t%s\n", cmd.usage, cmd.help)
 	}
+	return w.Flush()
 }
 
-func handleDebug(args ...string) {
-	if args[0] == "on" {
+func (c *Console) handleDebug(args []string) error {
+	switch args[0] {
+	case "on":
 		LogLevel.Set(slog.LevelDebug)
-		fmt.Println("logging now set to debug level")
-	} else if args[0] == "off" {
+		fmt.Fprintln(c.out, "logging now set to debug level")
+	case "off":
 		LogLevel.Set(slog.LevelInfo)
-		fmt.Println("logging now set to info level")
-	} else {
-		fmt.Println("unknown arg " + args[0])
+		fmt.Fprintln(c.out, "logging now set to info level")
+	default:
+		return fmt.Errorf("unknown arg %s, must be on or off", args[0])
+	}
+	return nil
+}
+
+func (c *Console) handleEvents(args []string) error {
+	ids := c.core.Events()
+	if len(ids) == 0 {
+		fmt.Fprintln(c.out, "no events")
+		return nil
+	}
+	w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	for _, id := range ids {
+		event, err := c.core.GetEvent(id)
+		if err != nil {
+			return err
+		}
+		s := event.Status()
+		fmt.Fprintf(w, "%s\t%s\t%s", id, s.State, s.Name)
+		if s.Current > 0 {
+			fmt.Fprintf(w, " (%d encounters)", s.Current)
+		}
+		fmt.Fprintln(w)
+	}
+	return w.Flush()
+}
+
+func (c *Console) handleEvent(args []string) error {
+	id, transition := args[0], args[1]
+	if !c.isEvent(id) {
+		return fmt.Errorf("no event %s", id)
+	}
+	event, err := c.core.GetEvent(id)
+	if err != nil {
+		return err
+	}
+	switch transition {
+	case "open":
+		err = event.Open(time.Now())
+	case "close":
+		err = event.Close(time.Now())
+	case "resolve":
+		err = event.Resolve()
+	default:
+		return fmt.Errorf("unknown transition %s, must be open, close or resolve", transition)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "%s is now %s\n", id, event.Status().State)
+	return nil
+}
+
+// handleBalance shows the user's balance, e.g. `balance 1234`, or adjusts it
+// with a reason, e.g. `balance 1234 +500 won the raffle`.
+func (c *Console) handleBalance(args []string) error {
+	uid := c.core.Account(args[0])
+	if len(args) == 1 {
+		if !c.core.UserExists(uid) {
+			return fmt.Errorf("no user %s", uid)
+		}
+		u, err := c.core.GetUser(uid)
+		if err != nil {
+			return err
+		}
+		balance, inBets, _ := u.Balance()
+		fmt.Fprintf(c.out, "%s has %d cakes (%d in bets)\n", uid, balance, inBets)
+		return nil
+	}
+	delta, err := strconv.Atoi(args[1])
+	if err != nil || delta == 0 {
+		return fmt.Errorf("amount must be a non-zero number like +500 or -500")
+	}
+	balance, err := c.core.AdjustBalance(uid, delta, c.Operator, strings.Join(args[2:], " "))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "%s now has %d cakes\n", uid, balance)
+	return nil
+}
+
+func (c *Console) handleAdjustments(args []string) error {
+	uid := c.core.Account(args[0])
+	adjustments, err := c.core.Adjustments(uid)
+	if err != nil {
+		return err
+	}
+	if len(adjustments) == 0 {
+		fmt.Fprintf(c.out, "no adjustments to %s\n", uid)
+		return nil
+	}
+	w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "time\tamount\toperator\treason")
+	for _, a := range adjustments {
+		fmt.Fprintf(w, "%s\t%+d\t%s\t%s\n", a.Time.Format(time.DateTime), a.Amount, a.Operator, a.Reason)
+	}
+	return w.Flush()
+}
+
+func (c *Console) handleCrons(args []string) error {
+	schedules := c.core.CronSchedules()
+	if len(schedules) == 0 {
+		fmt.Fprintln(c.out, "no crons")
+		return nil
+	}
+	w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "cron\tlast run\tnext run")
+	for _, s := range schedules {
+		last := "never"
+		if !s.Last.IsZero() {
+			last = s.Last.Format(time.DateTime)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", s.ID, last, s.Next.Format(time.DateTime))
+	}
+	return w.Flush()
+}
+
+// handleOdds changes the odds of the phase events when the hunt changes, e.g.
+// `odds 4096 charm masuda`.  With no args it prints the current odds.
+func (c *Console) handleOdds(args []string) error {
+	if len(args) == 0 {
+		for _, id := range oddsEvents {
+			if e, ok := c.probabilityEventFor(id); ok {
+				fmt.Fprintf(c.out, "%s: 1/%.0f per encounter\n", id, 1/e.Probability())
+			}
+		}
+		return nil
+	}
+	base, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("base odds must be a number: %v", err)
+	}
+	conf := env.OddsConfig{Base: base}
+	for _, arg := range args[1:] {
+		if arg == "charm" {
+			conf.ShinyCharm = true
+		} else {
+			conf.Method = arg
+		}
+	}
+	probability, err := conf.Probability()
+	if err != nil {
+		return fmt.Errorf("invalid odds: %v", err)
+	}
+	for _, id := range oddsEvents {
+		e, ok := c.probabilityEventFor(id)
+		if !ok {
+			continue
+		}
+		if err := e.SetProbability(probability); err != nil {
+			fmt.Fprintf(c.out, "could not set odds of %s: %v\n", id, err)
+			continue
+		}
+		fmt.Fprintf(c.out, "%s odds now 1/%.0f per encounter\n", id, 1/probability)
+	}
+	return nil
+}
+
+// handleToken makes an API token for a user, e.g. `token 1234 overlay`.  The
+// token is only ever printed here, so it has to be copied now.
+func (c *Console) handleToken(args []string) error {
+	token, err := c.core.CreateAPIToken(args[0], args[1], time.Now())
+	if err != nil {
+		return fmt.Errorf("could not create token: %v", err)
+	}
+	fmt.Fprintf(c.out, "api token %q for %s: %s\n", args[1], args[0], token)
+	return nil
+}
+
+func (c *Console) handleReload(args []string) error {
+	if c.Reload == nil {
+		return fmt.Errorf("reloading isn't supported")
+	}
+	if err := c.Reload(); err != nil {
+		return fmt.Errorf("could not reload: %v", err)
+	}
+	fmt.Fprintln(c.out, "configuration reloaded")
+	return nil
+}
+
+// isEvent returns whether the event is registered, without GetEvent's warning
+// for unknown ids.
+func (c *Console) isEvent(id string) bool {
+	for _, e := range c.core.Events() {
+		if e == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Console) probabilityEventFor(id string) (probabilityEvent, bool) {
+	if !c.isEvent(id) {
+		return nil, false
+	}
+	event, err := c.core.GetEvent(id)
+	if err != nil {
+		return nil, false
 	}
+	e, ok := event.(probabilityEvent)
+	return e, ok
 }
@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"bet/core"
+	"bet/core/db"
+	"bet/core/events"
+	"bet/env"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type clock struct{}
+
+func (clock) Now() time.Time                         { return time.Now() }
+func (clock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+func newTestCore(t *testing.T) *core.Core {
+	t.Helper()
+	c := core.New(db.Fake(), nil, clock{})
+	if err := c.RegisterEvent("shiny", events.NewShinyEvent(c, env.OddsConfig{Base: 2}, env.PhaseEventConfig{}, "")); err != nil {
+		t.Fatalf("RegisterEvent() returned unexpected error: %v", err)
+	}
+	return c
+}
+
+// runConsole runs the input through a console until it ends, and returns what
+// was written.
+func runConsole(c *core.Core, input string, reload func() error) string {
+	var out strings.Builder
+	console := NewConsole(c, strings.NewReader(input), &out)
+	console.Reload = reload
+	console.Loop()
+	return out.String()
+}
+
+func TestConsole(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{name: "empty line", input: "\n\n", want: []string{"> > > "}},
+		{name: "no args", input: "debug\n", want: []string{"usage: debug on|off"}},
+		{name: "too many args", input: "events all\n", want: []string{"usage: events"}},
+		{name: "unknown command", input: "launch\n", want: []string{"not a command: launch, try help"}},
+		{name: "help", input: "help\n", want: []string{"debug on|off", "event <id> open|close|resolve", "reload"}},
+		{name: "debug", input: "debug on\r\ndebug loud\n", want: []string{"logging now set to debug level", "error: unknown arg loud"}},
+		{name: "events", input: "events\n", want: []string{"shiny  open"}},
+		{name: "event", input: "event shiny close\nevent shiny close\nevent anti open\n", want: []string{
+			"shiny is now closing",
+			"error: wrong state for transition",
+			"error: no event anti",
+		}},
+		{name: "crons", input: "crons\n", want: []string{"no crons"}},
+		{name: "last line without a newline", input: "crons", want: []string{"no crons"}},
+		{name: "reload", input: "reload\n", want: []string{"error: reloading isn't supported"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := runConsole(newTestCore(t), tc.input, nil)
+			for _, want := range tc.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("output doesn't contain %q:\n%s", want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestBalance(t *testing.T) {
+	c := newTestCore(t)
+	got := runConsole(c, "balance user1\n", nil)
+	if !strings.Contains(got, "error: no user user1") {
+		t.Errorf("balance of an unknown user = %q", got)
+	}
+	if _, err := c.GetUser("user1"); err != nil {
+		t.Fatalf("GetUser() returned unexpected error: %v", err)
+	}
+	got = runConsole(c, "balance user1 +500 won the raffle\nbalance user1 -200 refund mistake\nbalance user1\nbalance user1 -2000 typo\nbalance user1 lots\nbalance user1 +100\nadjustments user1\n", nil)
+	for _, want := range []string{
+		"user1 now has 1500 cakes",
+		"user1 now has 1300 cakes",
+		"user1 has 1300 cakes (0 in bets)",
+		"error: can't take 2000 cakes from user1",
+		"error: amount must be a non-zero number",
+		"error: a reason is needed to adjust a balance",
+		"-200    console   refund mistake",
+		"+500    console   won the raffle",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output doesn't contain %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestReload(t *testing.T) {
+	c := newTestCore(t)
+	reloads := 0
+	got := runConsole(c, "reload\n", func() error {
+		reloads++
+		return nil
+	})
+	if reloads != 1 || !strings.Contains(got, "configuration reloaded") {
+		t.Errorf("reload ran %d times and wrote %q", reloads, got)
+	}
+	got = runConsole(c, "reload\n", func() error { return errors.New("bad yaml") })
+	if !strings.Contains(got, "error: could not reload: bad yaml") {
+		t.Errorf("failed reload wrote %q", got)
+	}
+}
@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"bet/core"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Socket serves the console on a Unix socket, so the bot can be operated while
+// it runs in the background.  Only the user running the bot can connect.
+type Socket struct {
+	core     *core.Core
+	path     string
+	listener *net.UnixListener
+	// Reload is given to every console, see Console.Reload.
+	Reload func() error
+
+	mu    sync.Mutex
+	conns map[net.Conn]bool
+}
+
+// Listen makes the socket at path, replacing a socket left behind by a previous
+// run.  The socket is made in a directory only this user can enter, and moved
+// to path once only this user can connect to it, so nobody can connect while
+// it's being set up.
+func Listen(c *core.Core, path string) (*Socket, error) {
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket == 0 {
+		return nil, fmt.Errorf("%s already exists and isn't a socket", path)
+	}
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".console-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	private := filepath.Join(dir, "console.sock")
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: private, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	// The socket is removed from path by Close instead.
+	l.SetUnlinkOnClose(false)
+	if err := os.Chmod(private, 0600); err != nil {
+		l.Close()
+		return nil, err
+	}
+	// Renaming replaces any socket left behind at path.
+	if err := os.Rename(private, path); err != nil {
+		l.Close()
+		return nil, err
+	}
+	slog.Info(fmt.Sprintf("console listening on %s", path))
+	return &Socket{core: c, path: path, listener: l, conns: make(map[net.Conn]bool)}, nil
+}
+
+// Serve runs a console for every connection until the socket is closed.
+// Connections from other users are dropped.
+func (s *Socket) Serve() {
+	for {
+		conn, err := s.listener.AcceptUnix()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			slog.Warn(fmt.Sprintf("could not accept console connection: %v", err))
+			continue
+		}
+		if uid, err := peerUID(conn); err != nil || uid != os.Getuid() {
+			slog.Warn(fmt.Sprintf("dropping console connection from uid %d: %v", uid, err))
+			conn.Close()
+			continue
+		}
+		s.mu.Lock()
+		s.conns[conn] = true
+		s.mu.Unlock()
+		go s.serve(conn)
+	}
+}
+
+func (s *Socket) serve(conn net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+	slog.Info("console connected")
+	console := NewConsole(s.core, conn, conn)
+	console.Reload = s.Reload
+	console.Operator = "socket"
+	console.Loop()
+	slog.Info("console disconnected")
+}
+
+// Close stops accepting connections, disconnects every console and removes the
+// socket.
+func (s *Socket) Close() error {
+	err := s.listener.Close()
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	if rmErr := os.Remove(s.path); rmErr != nil && !errors.Is(rmErr, os.ErrNotExist) {
+		err = errors.Join(err, rmErr)
+	}
+	return err
+}
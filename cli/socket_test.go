@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bet.sock")
+	// A socket left behind by a previous run is replaced.
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("could not make stale socket: %v", err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	s, err := Listen(newTestCore(t), path)
+	if err != nil {
+		t.Fatalf("Listen() returned unexpected error: %v", err)
+	}
+	go s.Serve()
+	defer s.Close()
+
+	// Only the socket is left in the directory, not the private directory it
+	// was made in.
+	if entries, err := os.ReadDir(filepath.Dir(path)); err != nil || len(entries) != 1 {
+		t.Errorf("directory has %d entries after Listen() (err %v), want only the socket", len(entries), err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("could not stat socket: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("socket has permissions %v, want 0600", perm)
+	}
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("could not connect: %v", err)
+	}
+	defer conn.Close()
+	if _, err := io.WriteString(conn, "events\n"); err != nil {
+		t.Fatalf("could not write command: %v", err)
+	}
+	conn.(*net.UnixConn).CloseWrite()
+	out, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("could not read output: %v", err)
+	}
+	if got, want := string(out), "> shiny  open  Shiny\n> "; got != want {
+		t.Errorf("got output %q, want %q", got, want)
+	}
+}
+
+func TestSocketClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bet.sock")
+	s, err := Listen(newTestCore(t), path)
+	if err != nil {
+		t.Fatalf("Listen() returned unexpected error: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("Close() returned unexpected error: %v", err)
+	}
+	if _, err := os.Lstat(path); !os.IsNotExist(err) {
+		t.Errorf("socket still exists after Close(): %v", err)
+	}
+}
+
+func TestPeerUID(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "peer.sock")
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	defer l.Close()
+	client, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("could not connect: %v", err)
+	}
+	defer client.Close()
+	conn, err := l.AcceptUnix()
+	if err != nil {
+		t.Fatalf("could not accept: %v", err)
+	}
+	defer conn.Close()
+	uid, err := peerUID(conn)
+	if err != nil {
+		t.Fatalf("peerUID() returned unexpected error: %v", err)
+	}
+	if uid != os.Getuid() {
+		t.Errorf("peerUID() = %d, want %d", uid, os.Getuid())
+	}
+}
+
+func TestListenNotSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bet.sock")
+	if err := os.WriteFile(path, []byte("important"), 0600); err != nil {
+		t.Fatalf("could not write file: %v", err)
+	}
+	if _, err := Listen(newTestCore(t), path); err == nil {
+		t.Errorf("Listen() over a regular file succeeded, want an error")
+	}
+}
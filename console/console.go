@@ -0,0 +1,66 @@
+// This is a client for the operator console the bot serves on a Unix socket,
+// so the bot can be operated while it runs as a service.  Given a command, it
+// runs just that command, e.g. `console -socket bet.sock balance 1234`.
+// Otherwise it connects the console to the terminal.
+package main
+
+import (
+	"bet/cli"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+)
+
+func main() {
+	socket := flag.String("socket", "bet.sock", "path of the console socket, as set by ConsoleSocket in the bot's .env")
+	flag.Parse()
+
+	conn, err := net.Dial("unix", *socket)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not connect to the console: %v\n", err)
+		os.Exit(1)
+	}
+	defer conn.Close()
+
+	if flag.NArg() > 0 {
+		err = oneShot(conn.(*net.UnixConn), strings.Join(flag.Args(), " "))
+	} else {
+		err = interactive(conn.(*net.UnixConn))
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// oneShot runs the command and prints its output, without the prompts around
+// it.
+func oneShot(conn *net.UnixConn, command string) error {
+	if _, err := fmt.Fprintln(conn, command); err != nil {
+		return err
+	}
+	// The console ends once it's read everything we send.
+	if err := conn.CloseWrite(); err != nil {
+		return err
+	}
+	out, err := io.ReadAll(conn)
+	if err != nil {
+		return err
+	}
+	fmt.Print(strings.TrimSuffix(strings.TrimPrefix(string(out), cli.Prompt), cli.Prompt))
+	return nil
+}
+
+// interactive connects the console to the terminal until either side closes.
+func interactive(conn *net.UnixConn) error {
+	go func() {
+		io.Copy(conn, os.Stdin)
+		conn.CloseWrite()
+	}()
+	_, err := io.Copy(os.Stdout, conn)
+	fmt.Println()
+	return err
+}
@@ -0,0 +1,105 @@
+package core
+
+import (
+	"bet/core/db"
+	"fmt"
+	"time"
+)
+
+// Achievement is a badge users can unlock from their betting activity.
+type Achievement struct {
+	ID          string
+	Name        string
+	Description string
+}
+
+// Achievement IDs.  These are persisted, so must not be changed.
+const (
+	AchievementExact          = "exact"
+	AchievementLongshot       = "longshot"
+	AchievementAllIn          = "all-in"
+	AchievementPhilanthropist = "philanthropist"
+	AchievementBailout        = "bailout"
+)
+
+// PhilanthropistDonations is the total amount a user must have donated to
+// unlock the philanthropist achievement.
+const PhilanthropistDonations = 10000
+
+// LongshotRisk is the risk a winning bet must be over to unlock the longshot
+// achievement.
+const LongshotRisk = 0.99
+
+var achievements = map[string]Achievement{
+	AchievementExact: {
+		ID:          AchievementExact,
+		Name:        "Bullseye",
+		Description: "Win a bet on the exact phase",
+	},
+	AchievementLongshot: {
+		ID:          AchievementLongshot,
+		Name:        "Longshot",
+		Description: fmt.Sprintf("Win a bet with more than %.0f%% risk", LongshotRisk*100),
+	},
+	AchievementAllIn: {
+		ID:          AchievementAllIn,
+		Name:        "All In",
+		Description: "Bet every cake you have on a single bet",
+	},
+	AchievementPhilanthropist: {
+		ID:          AchievementPhilanthropist,
+		Name:        "Philanthropist",
+		Description: fmt.Sprintf("Donate %d cakes in total", PhilanthropistDonations),
+	},
+	AchievementBailout: {
+		ID:          AchievementBailout,
+		Name:        "Too Big To Fail",
+		Description: "Go broke and get bailed out",
+	},
+}
+
+// GetAchievement returns the achievement with the given id.
+func GetAchievement(id string) (Achievement, bool) {
+	a, ok := achievements[id]
+	return a, ok
+}
+
+// Unlock is an achievement that was newly awarded to a user.
+type Unlock struct {
+	UID         string
+	Achievement Achievement
+}
+
+func (u Unlock) String() string {
+	return fmt.Sprintf("<@%s> unlocked **%s**: %s", u.UID, u.Achievement.Name, u.Achievement.Description)
+}
+
+// Award gives the achievement to the user as part of the transaction.  Returns
+// the unlock if the user didn't already have the achievement, otherwise nil.
+func (c *Core) Award(tx db.Transaction, uid, id string) (*Unlock, error) {
+	a, ok := achievements[id]
+	if !ok {
+		return nil, fmt.Errorf("no achievement with id %q", id)
+	}
+	awarded, err := tx.WriteAchievement(uid, id, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !awarded {
+		return nil, nil
+	}
+	return &Unlock{UID: uid, Achievement: a}, nil
+}
+
+// UnlocksMessage formats the unlocks to be appended to a message.  Returns an
+// empty string if there were no unlocks.
+func UnlocksMessage(unlocks []Unlock) string {
+	if len(unlocks) == 0 {
+		return ""
+	}
+	message := "\nAchievements unlocked:"
+	for _, u := range unlocks {
+		message += fmt.Sprintf("\n * %s", u)
+	}
+	return message
+}
@@ -0,0 +1,186 @@
+package core
+
+import (
+	"bet/core/db"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// AllowancePolicy decides when users are given cakes so that they can keep
+// playing.
+type AllowancePolicy struct {
+	// Floor is the balance users are bailed out to when they've gone below it
+	// after an event resolves.
+	Floor int
+	// BailoutCooldown is the least time between two bailouts of the same user.
+	// Users still below the floor are bailed out after the next event once the
+	// cooldown has passed.
+	BailoutCooldown time.Duration
+	// Daily is the allowance users can claim once a day, or 0 to disable it.
+	Daily int
+	// StreakBonus is added to the daily allowance for every day in a row it's
+	// been claimed, up to MaxStreak days.
+	StreakBonus int
+	MaxStreak   int
+}
+
+// DefaultAllowancePolicy bails users out to 100 cakes after every event.
+var DefaultAllowancePolicy = AllowancePolicy{Floor: 100}
+
+// RefreshBalance bails out every user whose balance is below the floor of the
+// allowance policy, unless they were bailed out within the cooldown.  Houses
+// aren't bailed out, but are topped up to their funding instead.  Users
+// that needed bailing out are awarded an achievement, and any unlocks are
+// returned so they can be announced.
+func (c *Core) RefreshBalance() ([]Unlock, error) {
+	slog.Debug("RefreshBalance called")
+	policy := c.Allowance()
+	now := time.Now()
+	// Reading while the transaction is open would block, so find out who is
+	// still cooling down first.
+	last := make(map[string]time.Time)
+	if policy.BailoutCooldown > 0 {
+		rows, err := c.Database.LastGrants(db.GrantBailout)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var uid, ts string
+			if err := rows.Scan(&uid, &ts); err != nil {
+				return nil, err
+			}
+			t, err := time.Parse(time.DateTime, ts)
+			if err != nil {
+				slog.Warn(fmt.Sprintf("could not parse last bailout time %s: %v", ts, err))
+				continue
+			}
+			last[uid] = t
+		}
+	}
+	tx, err := c.Database.OpenTransaction()
+	if err != nil {
+		return nil, err
+	}
+	houses := c.fundings()
+	unlocks := make([]Unlock, 0)
+	for _, u := range c.users {
+		if funding, ok := houses[u.id]; ok {
+			if _, err := fundHouse(tx, u, funding, now); err != nil {
+				slog.Warn(fmt.Sprintf("could not fund house %s: %v", u.id, err))
+			}
+			continue
+		}
+		balance, _, _ := u.Balance()
+		if balance >= policy.Floor {
+			continue
+		}
+		if t, ok := last[u.id]; ok && now.Sub(t) < policy.BailoutCooldown {
+			continue
+		}
+		amount := policy.Floor - balance
+		if err := u.Earn(tx, amount); err != nil {
+			slog.Warn(fmt.Sprintf("could not bail out user %s: %v", u.id, err))
+			continue
+		}
+		if err := tx.WriteGrant(u.id, db.GrantBailout, now, amount, 0); err != nil {
+			slog.Warn(fmt.Sprintf("could not record bailout of user %s: %v", u.id, err))
+		}
+		unlock, err := c.Award(tx, u.id, AchievementBailout)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("could not award bailout achievement: %v", err))
+			continue
+		}
+		if unlock != nil {
+			unlocks = append(unlocks, *unlock)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return unlocks, nil
+}
+
+// NoDailyError is returned when claiming the daily allowance while it's
+// disabled.
+type NoDailyError struct{}
+
+func (e NoDailyError) Error() string {
+	return "the daily allowance is disabled"
+}
+
+// DailyError is returned when the daily allowance was already claimed today.
+type DailyError struct {
+	// Next is when the allowance can next be claimed.
+	Next time.Time
+}
+
+func (e DailyError) Error() string {
+	return fmt.Sprintf("the daily allowance was already claimed, next claim at %s", e.Next.Format(time.DateTime))
+}
+
+// day truncates the time to the start of its day in UTC.
+func day(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
+
+// ClaimDaily gives the user their daily allowance, with a bonus for claiming on
+// consecutive days.  Returns the amount given and the user's streak of days.
+func (c *Core) ClaimDaily(uid string, now time.Time) (int, int, error) {
+	policy := c.Allowance()
+	if policy.Daily <= 0 {
+		return 0, 0, NoDailyError{}
+	}
+	if c.IsHouse(uid) {
+		return 0, 0, HouseError{}
+	}
+	u, err := c.GetUser(uid)
+	if err != nil {
+		return 0, 0, err
+	}
+	// Hold the event lock so two claims can't both see the old claim.
+	c.EventMu.Lock()
+	defer c.EventMu.Unlock()
+	rows, err := c.Database.LastGrant(uid, db.GrantDaily)
+	if err != nil {
+		return 0, 0, err
+	}
+	var last time.Time
+	var streak int
+	for rows.Next() {
+		var ts string
+		if err := rows.Scan(&ts, &streak); err != nil {
+			return 0, 0, err
+		}
+		last, err = time.Parse(time.DateTime, ts)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	today := day(now)
+	switch {
+	case last.IsZero():
+		streak = 1
+	case !day(last).Before(today):
+		return 0, 0, DailyError{Next: today.AddDate(0, 0, 1)}
+	case day(last).AddDate(0, 0, 1).Equal(today):
+		streak++
+	default:
+		streak = 1
+	}
+	amount := policy.Daily + policy.StreakBonus*min(streak-1, policy.MaxStreak)
+	tx, err := c.Database.OpenTransaction()
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := u.Earn(tx, amount); err != nil {
+		return 0, 0, err
+	}
+	if err := tx.WriteGrant(uid, db.GrantDaily, now, amount, streak); err != nil {
+		return 0, 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+	return amount, streak, nil
+}
@@ -0,0 +1,123 @@
+package core
+
+import (
+	"bet/core/db"
+	"errors"
+	"testing"
+	"time"
+)
+
+// spend takes amount from the user's balance, as if they lost a bet.
+func spend(t *testing.T, c *Core, uid string, amount int) {
+	t.Helper()
+	u, err := c.GetUser(uid)
+	if err != nil {
+		t.Fatalf("GetUser(%s) returned unexpected error: %v", uid, err)
+	}
+	tx, _ := c.Database.OpenTransaction()
+	u.Reserve(tx, amount)
+	u.Resolve(tx, amount, true)
+	tx.Commit()
+}
+
+func balanceOf(c *Core, uid string) int {
+	u, _ := c.GetUser(uid)
+	balance, _, _ := u.Balance()
+	return balance
+}
+
+func TestRefreshBalance(t *testing.T) {
+	c := New(db.Fake(), nil, nil)
+	c.SetAllowance(AllowancePolicy{Floor: 200})
+	spend(t, c, "broke", 950)
+	spend(t, c, "fine", 500)
+	unlocks, err := c.RefreshBalance()
+	if err != nil {
+		t.Fatalf("RefreshBalance() returned unexpected error: %v", err)
+	}
+	if got := balanceOf(c, "broke"); got != 200 {
+		t.Errorf("broke user has %d balance after bailout, want 200", got)
+	}
+	if got := balanceOf(c, "fine"); got != 500 {
+		t.Errorf("fine user has %d balance after bailout, want 500", got)
+	}
+	if len(unlocks) != 1 || unlocks[0].UID != "broke" {
+		t.Errorf("RefreshBalance() unlocks = %v, want the bailout for broke", unlocks)
+	}
+}
+
+func TestRefreshBalanceCooldown(t *testing.T) {
+	c := New(db.Fake(), nil, nil)
+	c.SetAllowance(AllowancePolicy{Floor: 100, BailoutCooldown: time.Hour})
+	spend(t, c, "broke", 1000)
+	if _, err := c.RefreshBalance(); err != nil {
+		t.Fatalf("RefreshBalance() returned unexpected error: %v", err)
+	}
+	if got := balanceOf(c, "broke"); got != 100 {
+		t.Errorf("broke user has %d balance after first bailout, want 100", got)
+	}
+	// Losing it all again within the cooldown doesn't get another bailout.
+	spend(t, c, "broke", 100)
+	if _, err := c.RefreshBalance(); err != nil {
+		t.Fatalf("RefreshBalance() returned unexpected error: %v", err)
+	}
+	if got := balanceOf(c, "broke"); got != 0 {
+		t.Errorf("broke user has %d balance during the cooldown, want 0", got)
+	}
+	// The bailout was recorded.
+	rows, _ := c.Database.LastGrants(db.GrantBailout)
+	var bailedOut bool
+	for rows.Next() {
+		var uid, ts string
+		rows.Scan(&uid, &ts)
+		bailedOut = bailedOut || uid == "broke"
+	}
+	if !bailedOut {
+		t.Errorf("bailout of broke wasn't recorded")
+	}
+}
+
+func TestClaimDaily(t *testing.T) {
+	c := New(db.Fake(), nil, nil)
+	if _, _, err := c.ClaimDaily("user", time.Now()); !errors.Is(err, NoDailyError{}) {
+		t.Errorf("ClaimDaily() while disabled returned %v, want NoDailyError", err)
+	}
+	c.SetAllowance(AllowancePolicy{Floor: 100, Daily: 50, StreakBonus: 10, MaxStreak: 2})
+	day1 := time.Date(2025, time.March, 1, 20, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name       string
+		now        time.Time
+		wantAmount int
+		wantStreak int
+		wantNext   time.Time
+	}{
+		{name: "first claim", now: day1, wantAmount: 50, wantStreak: 1},
+		{
+			name:     "same day",
+			now:      day1.Add(2 * time.Hour),
+			wantNext: time.Date(2025, time.March, 2, 0, 0, 0, 0, time.UTC),
+		},
+		{name: "next day", now: day1.Add(5 * time.Hour), wantAmount: 60, wantStreak: 2},
+		{name: "third day", now: day1.AddDate(0, 0, 2), wantAmount: 70, wantStreak: 3},
+		{name: "bonus is capped", now: day1.AddDate(0, 0, 3), wantAmount: 70, wantStreak: 4},
+		{name: "missed a day", now: day1.AddDate(0, 0, 5), wantAmount: 50, wantStreak: 1},
+	} {
+		amount, streak, err := c.ClaimDaily("user", tc.now)
+		if !tc.wantNext.IsZero() {
+			var dailyErr DailyError
+			if !errors.As(err, &dailyErr) || !dailyErr.Next.Equal(tc.wantNext) {
+				t.Errorf("%s: ClaimDaily() returned %v, want DailyError until %v", tc.name, err, tc.wantNext)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: ClaimDaily() returned unexpected error: %v", tc.name, err)
+		}
+		if amount != tc.wantAmount || streak != tc.wantStreak {
+			t.Errorf("%s: ClaimDaily() = %d, %d, want %d, %d", tc.name, amount, streak, tc.wantAmount, tc.wantStreak)
+		}
+	}
+	if got := balanceOf(c, "user"); got != 1000+50+60+70+70+50 {
+		t.Errorf("user has %d balance after claims, want %d", got, 1000+50+60+70+70+50)
+	}
+}
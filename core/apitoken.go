@@ -0,0 +1,59 @@
+package core
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// InvalidTokenError is returned when an API token doesn't belong to anyone.
+type InvalidTokenError struct{}
+
+func (e InvalidTokenError) Error() string {
+	return "invalid api token"
+}
+
+// hashToken hashes the API token for storage, so a leaked database doesn't leak
+// working tokens.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateAPIToken makes a new API token that acts as the user.  The name is to
+// remember what the token is used for.  The token can't be recovered later, so
+// must be given to whoever needs it straight away.
+func (c *Core) CreateAPIToken(uid string, name string, now time.Time) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+	tx, err := c.Database.OpenTransaction()
+	if err != nil {
+		return "", err
+	}
+	if err := tx.WriteAPIToken(hashToken(token), uid, name, now); err != nil {
+		return "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// APITokenUser returns the id of the account the API token acts as.
+func (c *Core) APITokenUser(token string) (string, error) {
+	if token == "" {
+		return "", InvalidTokenError{}
+	}
+	uid, err := c.Database.APITokenUser(hashToken(token))
+	if err != nil {
+		return "", err
+	}
+	if uid == "" {
+		return "", InvalidTokenError{}
+	}
+	return c.Account(uid), nil
+}
@@ -0,0 +1,127 @@
+package core
+
+import (
+	"fmt"
+	"time"
+)
+
+// Bet is a bet placed on an event since it last opened.
+type Bet struct {
+	UID    string
+	EID    string
+	Placed time.Time
+	Amount int
+	Risk   float64
+	// Bet is what was bet on, as interpreted by the event.
+	Bet string
+}
+
+// BetNotFoundError is returned when cancelling a bet that doesn't exist.
+type BetNotFoundError struct{}
+
+func (e BetNotFoundError) Error() string {
+	return "bet not found"
+}
+
+// DuplicateBetError is returned when placing a bet in the same second as
+// another bet of the user's, since bets are told apart by when they were
+// placed.
+type DuplicateBetError struct{}
+
+func (e DuplicateBetError) Error() string {
+	return "a bet was already placed this second"
+}
+
+// CancelWindowError is returned when cancelling a bet after the cancel window
+// has passed.
+type CancelWindowError struct {
+	Window time.Duration
+}
+
+func (e CancelWindowError) Error() string {
+	if e.Window <= 0 {
+		return "bets cannot be cancelled"
+	}
+	return fmt.Sprintf("bets can only be cancelled within %s of placing them", e.Window)
+}
+
+// Bets returns the bets placed on the event since it last opened.
+func (c *Core) Bets(eid string) ([]Bet, error) {
+	event, err := c.GetEvent(eid)
+	if err != nil {
+		return nil, err
+	}
+	bets, err := c.loadBets(eid)
+	if err != nil {
+		return nil, err
+	}
+	for i := range bets {
+		bets[i].Bet = event.Interpret(bets[i].Bet)
+	}
+	return bets, nil
+}
+
+// loadBets loads the bets placed on the event since it last opened, with the
+// bet left as it's stored.
+func (c *Core) loadBets(eid string) ([]Bet, error) {
+	rows, err := c.Database.LoadBets(eid)
+	if err != nil {
+		return nil, err
+	}
+	bets := make([]Bet, 0)
+	for rows.Next() {
+		var b Bet
+		var placed string
+		if err := rows.Scan(&b.UID, &b.EID, &placed, &b.Amount, &b.Risk, &b.Bet); err != nil {
+			return nil, err
+		}
+		// The fake database doesn't filter bets by event.
+		if b.EID != eid {
+			continue
+		}
+		b.Placed, err = time.Parse(time.DateTime, placed)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse bet placed time %s: %v", placed, err)
+		}
+		bets = append(bets, b)
+	}
+	return bets, nil
+}
+
+// UserBets returns the bets the user has placed on every event since each
+// last opened.
+func (c *Core) UserBets(uid string) ([]Bet, error) {
+	bets := make([]Bet, 0)
+	for _, eid := range c.Events() {
+		eventBets, err := c.Bets(eid)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range eventBets {
+			if b.UID == uid {
+				bets = append(bets, b)
+			}
+		}
+	}
+	return bets, nil
+}
+
+// CheckCancel returns the user's bet on the event placed at `placed`, or an
+// error if it can't be cancelled at `now`.  This reads from the database, so
+// must be called before opening the cancellation's transaction.
+func (c *Core) CheckCancel(eid string, uid string, placed time.Time, now time.Time) (Bet, error) {
+	window := c.CancelWindow()
+	if window <= 0 || now.Sub(placed) > window {
+		return Bet{}, CancelWindowError{Window: window}
+	}
+	bets, err := c.loadBets(eid)
+	if err != nil {
+		return Bet{}, err
+	}
+	for _, b := range bets {
+		if b.UID == uid && b.Placed.Equal(placed) {
+			return b, nil
+		}
+	}
+	return Bet{}, BetNotFoundError{}
+}
@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"log/slog"
 
-	"github.com/bwmarrin/discordgo"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
@@ -26,62 +25,48 @@ type BalanceCommand struct {
 	Core *core.Core
 }
 
-func (c *BalanceCommand) Command() *discordgo.ApplicationCommand {
-	return &discordgo.ApplicationCommand{
-		Name:        "balance",
-		Description: "See your current balance",
-		Options: []*discordgo.ApplicationCommandOption{
-			{
-				Name:        "user",
-				Description: "User's balance to view, leave empty to see your own",
-				Type:        discordgo.ApplicationCommandOptionUser,
-				Required:    false,
-			},
-		},
-	}
-}
-
-func (c *BalanceCommand) Interaction(s *discordgo.Session, i *discordgo.InteractionCreate) {
+func (c *BalanceCommand) Handle(r Request) Response {
 	balanceReqs.Inc()
-	uid := i.Interaction.Member.User.ID
+	uid := r.UID
 	slog.Debug("balance interaction started", "user", uid)
 	message := "You have"
-	options := i.ApplicationCommandData().Options
-	if len(options) > 0 {
-		uid = options[0].UserValue(s).ID
+	if other, ok := r.String("user"); ok {
+		uid = other
 		message = fmt.Sprintf("<@%s> has", uid)
 	}
 	user, err := c.Core.GetUser(uid)
 	if err != nil {
 		slog.Warn(fmt.Sprintf("%s requested balance, could not fetch user: %s", uid, err))
-		genericError(s, i)
-		return
+		return genericError()
 	}
 	balance, inBets, err := user.Balance()
 	if err != nil {
 		slog.Warn(fmt.Sprintf("%s requested balance, could not load from user object: %s\n", uid, err))
-		genericError(s, i)
-		return
-	}
-	row, err := c.Core.Database.Rank(uid)
-	var rank int
-	for row.Next() {
-		if err := row.Scan(&rank); err != nil {
-			// Don't return in this case, just degrade to showing balance/bets.
-			slog.Warn(fmt.Sprintf("could not load rank for user %s: %v", uid, err))
-		}
+		return genericError()
 	}
+	rank := loadRank(c.Core, uid)
 	content := fmt.Sprintf("%s %d cakes (%d in bets)", message, balance, inBets)
 	if rank > 0 {
 		content += fmt.Sprintf(", rank %d on leaderboard", rank)
 	}
 	// Reply with a message like "@User has XXXX cake coins (YY in bets)"
-	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-		Type: discordgo.InteractionResponseChannelMessageWithSource,
-		Data: &discordgo.InteractionResponseData{
-			Flags:   discordgo.MessageFlagsEphemeral,
-			Content: content,
-		},
-	})
 	balanceSuccess.Inc()
+	return private(content)
+}
+
+// loadRank returns the user's rank on the leaderboard, or 0 if it couldn't be
+// loaded.  Callers should degrade to not showing the rank in that case.
+func loadRank(c *core.Core, uid string) int {
+	row, err := c.Database.Rank(uid)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("could not load rank for user %s: %v", uid, err))
+		return 0
+	}
+	var rank int
+	for row.Next() {
+		if err := row.Scan(&rank); err != nil {
+			slog.Warn(fmt.Sprintf("could not load rank for user %s: %v", uid, err))
+		}
+	}
+	return rank
 }
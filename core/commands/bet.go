@@ -6,16 +6,12 @@ import (
 	"bet/env"
 	"fmt"
 	"log/slog"
-	"time"
+	"sync"
 
-	"github.com/bwmarrin/discordgo"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
-// y tho.
-var integerOptionMinValue = 1.0
-
 var (
 	betReqs = promauto.NewCounter(prometheus.CounterOpts{
 		Name: "core/commands/bet_total",
@@ -28,159 +24,64 @@ var (
 )
 
 type BetCommand struct {
-	core   *core.Core
-	conf   env.EventConfig
+	core *core.Core
+	// mu guards itemID, which changes when the configuration is reloaded.
+	mu     sync.RWMutex
 	itemID []string
 }
 
 func NewBetCommand(c *core.Core, conf env.EventConfig) *BetCommand {
-	return &BetCommand{core: c, conf: conf}
+	return &BetCommand{core: c, itemID: ItemEventIDs(conf)}
 }
 
-func (c *BetCommand) Command() *discordgo.ApplicationCommand {
-	options := make([]*discordgo.ApplicationCommandOption, 0)
-	if c.conf.EnableShiny {
-		options = append(options, &discordgo.ApplicationCommandOption{
-			Name:        "shiny",
-			Description: "Place a bet on the phase length of this shiny encounter",
-			Type:        discordgo.ApplicationCommandOptionSubCommand,
-			Options:     phaseOptions(),
-		})
-	}
-	if c.conf.EnableAnti {
-		options = append(options, &discordgo.ApplicationCommandOption{
-			Name:        "anti",
-			Description: "Place a bet on the phase length of this anti shiny encounter",
-			Type:        discordgo.ApplicationCommandOptionSubCommand,
-			Options:     phaseOptions(),
-		})
-	}
-	for _, itemConf := range c.conf.ItemEvent {
+// Configure sets the item events that can be bet on from the reloaded config.
+func (c *BetCommand) Configure(conf env.EventConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.itemID = ItemEventIDs(conf)
+}
+
+func (c *BetCommand) isItemEvent(id string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return contains(c.itemID, id)
+}
+
+// ItemEventIDs returns the ids of the enabled item events, which are also the
+// names of their /bet subcommands.
+func ItemEventIDs(conf env.EventConfig) []string {
+	ids := make([]string, 0)
+	for _, itemConf := range conf.ItemEvent {
 		if itemConf.Enable {
 			name := itemConf.ID
 			if name == "" {
 				name = "item"
 			}
-			options = append(options, &discordgo.ApplicationCommandOption{
-				Name:        name,
-				Description: fmt.Sprintf("Place a bet on whether %s will hold %s", itemConf.Species, itemConf.Item),
-				Type:        discordgo.ApplicationCommandOptionSubCommand,
-				Options:     boolOptions(),
-			})
-			c.itemID = append(c.itemID, name)
+			ids = append(ids, name)
 		}
 	}
-	return &discordgo.ApplicationCommand{
-		Name:        "bet",
-		Description: "Place a bet on when an event will happen",
-		Options:     options,
-	}
+	return ids
 }
 
-func phaseOptions() []*discordgo.ApplicationCommandOption {
-	return []*discordgo.ApplicationCommandOption{
-		{
-			Name:        "amount",
-			Description: "How many cakes to wager",
-			Type:        discordgo.ApplicationCommandOptionInteger,
-			Required:    true,
-			MinValue:    &integerOptionMinValue,
-		},
-		{
-			Name:        "over-under",
-			Description: "Whether to bet over or under the phase lenth",
-			Type:        discordgo.ApplicationCommandOptionString,
-			Required:    true,
-			Choices: []*discordgo.ApplicationCommandOptionChoice{
-				{
-					Name:  "over",
-					Value: ">",
-				},
-				{
-					Name:  ">",
-					Value: ">",
-				},
-				{
-					Name:  "greater than",
-					Value: ">",
-				},
-				{
-					Name:  "under",
-					Value: "<",
-				},
-				{
-					Name:  "<",
-					Value: "<",
-				},
-				{
-					Name:  "less than",
-					Value: "<",
-				},
-				{
-					Name:  "equal",
-					Value: "=",
-				},
-				{
-					Name:  "exact",
-					Value: "=",
-				},
-				{
-					Name:  "=",
-					Value: "=",
-				},
-			},
-		},
-		{
-			Name:        "phase",
-			Description: "Phase length",
-			Type:        discordgo.ApplicationCommandOptionInteger,
-			Required:    true,
-		},
-	}
-}
-
-func boolOptions() []*discordgo.ApplicationCommandOption {
-	return []*discordgo.ApplicationCommandOption{
-		{
-			Name:        "amount",
-			Description: "How many cakes to wager",
-			Type:        discordgo.ApplicationCommandOptionInteger,
-			Required:    true,
-			MinValue:    &integerOptionMinValue,
-		},
-		{
-			Name:        "guess",
-			Description: "Will it hold the item?",
-			Type:        discordgo.ApplicationCommandOptionBoolean,
-			Required:    true,
-		},
-	}
-}
-
-func (c *BetCommand) Interaction(s *discordgo.Session, i *discordgo.InteractionCreate) {
+// Handle places a bet on the event named by the subcommand.  Phase events take
+// the options amount, over-under (one of ">", "<" or "=") and phase, and item
+// events take amount and guess.
+func (c *BetCommand) Handle(r Request) Response {
 	betReqs.Inc()
 	slog.Debug("bet interaction started")
-	options := i.ApplicationCommandData().Options
-	event, err := c.core.GetEvent(options[0].Name)
-	if err != nil {
-		slog.Warn(fmt.Sprintf("error getting event %s: %v", options[0].Name, err))
-		genericError(s, i)
-		return
-	}
-	messageTime, err := discordgo.SnowflakeTimestamp(i.ID)
+	eventName := r.Subcommand
+	event, err := c.core.GetEvent(eventName)
 	if err != nil {
-		slog.Warn(fmt.Sprintf("could not get timestamp from id: %v", err))
-		// We at least have a fallback for this one
-		messageTime = time.Now()
+		slog.Warn(fmt.Sprintf("error getting event %s: %v", eventName, err))
+		return genericError()
 	}
-	uid := i.Interaction.Member.User.ID
+	uid := r.UID
+	amount, _ := r.Int("amount")
 
-	eventName := options[0].Name
+	var response Response
 	switch {
 	case contains([]string{"shiny", "anti"}, eventName):
-		options = options[0].Options
-		amount := int(options[0].IntValue())
-		overUnder := options[1].StringValue()
+		overUnder, _ := r.String("over-under")
 		var direction int
 		if overUnder == ">" {
 			direction = events.GREATER
@@ -190,40 +91,21 @@ func (c *BetCommand) Interaction(s *discordgo.Session, i *discordgo.InteractionC
 			direction = events.EQUAL
 		} else {
 			slog.Debug(fmt.Sprintf("invalid over/under: %s", overUnder))
-			s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-				Type: discordgo.InteractionResponseChannelMessageWithSource,
-				Data: &discordgo.InteractionResponseData{
-					Flags:   discordgo.MessageFlagsEphemeral,
-					Content: "Must specify either 'over' or 'under' for the phase length.  For example: `/bet shiny 69 under 420`",
-				},
-			})
-			return
+			return private("Must specify either 'over' or 'under' for the phase length.  For example: `/bet shiny 69 under 420`")
 		}
-		phase := int(options[2].IntValue())
+		phase, _ := r.Int("phase")
 		b := events.PhaseBet{
 			Direction: direction,
 			Phase:     phase,
 		}
-		placedBet, err := event.Wager(uid, amount, messageTime, b)
+		placedBet, err := c.core.Wager(eventName, uid, amount, r.Time, b)
 		if err != nil {
-			respondToWagerError(s, i, err)
-			return
+			return wagerErrorResponse(err)
 		}
 		p, ok := placedBet.(events.PlacedPhaseBet)
 		if !ok {
 			slog.Warn(fmt.Sprintf("bad return from placed wager: %v", err))
-			s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-				Type: discordgo.InteractionResponseChannelMessageWithSource,
-				Data: &discordgo.InteractionResponseData{
-					Content: fmt.Sprintf("<@%s>'s bet for %d cakes was accepted.", uid, amount),
-					AllowedMentions: &discordgo.MessageAllowedMentions{
-						// Let's the user be tagged by ID so their name appears
-						// without pinging them.
-						Parse: []discordgo.AllowedMentionType{},
-					},
-				},
-			})
-			return
+			return Response{Content: fmt.Sprintf("<@%s>'s bet for %d cakes was accepted.", uid, amount)}
 		}
 		str := ""
 		switch direction {
@@ -234,68 +116,31 @@ func (c *BetCommand) Interaction(s *discordgo.Session, i *discordgo.InteractionC
 		case events.EQUAL:
 			str = "exactly"
 		}
-		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Content: fmt.Sprintf("<@%s> put %d cakes on the %s phase being %s %d encounters (%.2f%% risk).", uid, p.Amount, eventName, str, phase, p.Risk*100),
-				AllowedMentions: &discordgo.MessageAllowedMentions{
-					// Let's the user be tagged by ID so their name appears
-					// without pinging them.
-					Parse: []discordgo.AllowedMentionType{},
-				},
-			},
-		})
-	// TODO: hmmmm... How do I do this case now that the value is variable?
-	case contains(c.itemID, eventName):
-		options = options[0].Options
-		amount := int(options[0].IntValue())
-		guess := options[1].BoolValue()
-		placedBet, err := event.Wager(uid, amount, messageTime, guess)
+		content := fmt.Sprintf("<@%s> put %d cakes on the %s phase being %s %d encounters (%.2f%% risk).", uid, p.Amount, eventName, str, phase, p.Risk*100)
+		if p.Payout > 0 {
+			content += fmt.Sprintf(" The bank pays %d cakes if it wins.", p.Payout)
+		}
+		response = Response{Content: content}
+	case c.isItemEvent(eventName):
+		guess, _ := r.Bool("guess")
+		placedBet, err := c.core.Wager(eventName, uid, amount, r.Time, guess)
 		if err != nil {
-			respondToWagerError(s, i, err)
-			return
+			return wagerErrorResponse(err)
 		}
 		risk, ok := placedBet.(float64)
 		if !ok {
 			slog.Warn(fmt.Sprintf("bad return from placed wager: %v", err))
-			s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-				Type: discordgo.InteractionResponseChannelMessageWithSource,
-				Data: &discordgo.InteractionResponseData{
-					Content: fmt.Sprintf("<@%s>'s bet for %d cakes was accepted.", uid, amount),
-					AllowedMentions: &discordgo.MessageAllowedMentions{
-						// Let's the user be tagged by ID so their name appears
-						// without pinging them.
-						Parse: []discordgo.AllowedMentionType{},
-					},
-				},
-			})
-			return
+			return Response{Content: fmt.Sprintf("<@%s>'s bet for %d cakes was accepted.", uid, amount)}
 		}
 		guessStr := fmt.Sprintf("%t", guess)
 		betDisplay := event.Interpret(guessStr)
-		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Content: fmt.Sprintf("<@%s> placed %d cakes that %s (%.2f%% risk)", uid, amount, betDisplay, 100*risk),
-				AllowedMentions: &discordgo.MessageAllowedMentions{
-					// Let's the user be tagged by ID so their name appears
-					// without pinging them.
-					Parse: []discordgo.AllowedMentionType{},
-				},
-			},
-		})
+		response = Response{Content: fmt.Sprintf("<@%s> placed %d cakes that %s (%.2f%% risk)", uid, amount, betDisplay, 100*risk)}
 	default:
 		slog.Debug("no valid event specified")
-		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Flags:   discordgo.MessageFlagsEphemeral,
-				Content: "That's not an event you can bet on.",
-			},
-		})
-		return
+		return private("That's not an event you can bet on.")
 	}
 	betSuccess.Inc()
+	return response
 }
 
 func contains(ls []string, i string) bool {
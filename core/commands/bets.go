@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"log/slog"
 
-	"github.com/bwmarrin/discordgo"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
@@ -25,63 +24,36 @@ type ListBetsCommand struct {
 	Core *core.Core
 }
 
-func (c *ListBetsCommand) Command() *discordgo.ApplicationCommand {
-	return &discordgo.ApplicationCommand{
-		Name:        "bets",
-		Description: "See which bets you've placed",
-	}
-}
-
-func (c *ListBetsCommand) Interaction(s *discordgo.Session, i *discordgo.InteractionCreate) {
+func (c *ListBetsCommand) Handle(r Request) Response {
 	betsReqs.Inc()
-	uid := i.Interaction.Member.User.ID
+	uid := r.UID
 	slog.Debug("bets interaction started", "user", uid)
 	rows, err := c.Core.Database.LoadUserBets(uid)
 	if err != nil {
 		slog.Warn(fmt.Sprintf("%s requested bets, but error loading from db: %s", uid, err))
-		genericError(s, i)
-		return
+		return genericError()
 	}
 	content := fmt.Sprintf("<@%s> has the following open bets:", uid)
-	var notListed int
 	for rows.Next() {
 		var eid string
 		var amount int
 		var risk float64
 		var blob string
 		if err := rows.Scan(&eid, &amount, &risk, &blob); err != nil {
-			slog.Warn(fmt.Sprintf("could not scan bet row reading user bets: %s", uid, err))
-			genericError(s, i)
-			return
+			slog.Warn(fmt.Sprintf("could not scan bet row reading user %s bets: %s", uid, err))
+			return genericError()
 		}
 		blobInterpret := ""
 		event, err := c.Core.GetEvent(eid)
 		if err != nil {
-			slog.Warn(fmt.Sprintf("event %s couldn't be loaded", eid, err))
+			slog.Warn(fmt.Sprintf("event %s couldn't be loaded: %v", eid, err))
 			// Loading the event is just for interpreting the blob.  This isn't
 			// a necessary interaction to serve a request.
 		} else {
 			blobInterpret = event.Interpret(blob)
 		}
-		nextBet := fmt.Sprintf("\n 1. %d cakes on %s (%s), risk %.2f%%", amount, eid, blobInterpret, risk*100)
-		if len(content)+len(nextBet) >= 1980 {
-			// The message will be too big if we add this, so skip it and add a
-			// small message at the end to let the user know.  We still need to
-			// iterate all the way through `rows` otherwise we lock the db.
-			notListed++
-			continue
-		}
-		content += nextBet
-	}
-	if notListed > 0 {
-		content += fmt.Sprintf("\nand %d other bets.")
+		content += fmt.Sprintf("\n 1. %d cakes on %s (%s), risk %.2f%%", amount, eid, blobInterpret, risk*100)
 	}
-	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-		Type: discordgo.InteractionResponseChannelMessageWithSource,
-		Data: &discordgo.InteractionResponseData{
-			Flags:   discordgo.MessageFlagsEphemeral,
-			Content: content,
-		},
-	})
 	betsSuccess.Inc()
+	return paged(c.Core, "Open bets", content, true)
 }
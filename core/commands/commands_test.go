@@ -0,0 +1,142 @@
+package commands
+
+import (
+	"bet/core"
+	"bet/core/db"
+	"bet/core/events"
+	"bet/env"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestCore(t *testing.T) *core.Core {
+	t.Helper()
+	c := core.New(db.Fake(), nil, nil)
+	if err := c.RegisterEvent("shiny", events.NewShinyEvent(c, env.OddsConfig{Base: 2}, env.PhaseEventConfig{}, "")); err != nil {
+		t.Fatalf("RegisterEvent() returned unexpected error: %v", err)
+	}
+	return c
+}
+
+func TestBetCommand(t *testing.T) {
+	c := newTestCore(t)
+	bet := NewBetCommand(c, env.EventConfig{EnableShiny: true})
+	for i, tc := range []struct {
+		name       string
+		subcommand string
+		options    map[string]any
+		want       string
+		private    bool
+	}{
+		{
+			name:       "phase bet",
+			subcommand: "shiny",
+			options:    map[string]any{"amount": 100, "over-under": "<", "phase": 3},
+			want:       "<@user1> put 100 cakes on the shiny phase being less than 3 encounters (25.00% risk).",
+		},
+		{
+			name:       "invalid direction",
+			subcommand: "shiny",
+			options:    map[string]any{"amount": 100, "over-under": "?", "phase": 3},
+			want:       "Must specify either 'over' or 'under'",
+			private:    true,
+		},
+		{
+			name:       "not enough cakes",
+			subcommand: "shiny",
+			options:    map[string]any{"amount": 10000, "over-under": ">", "phase": 3},
+			want:       "You don't have enough cakes",
+			private:    true,
+		},
+		{
+			name:       "unknown event",
+			subcommand: "anti",
+			options:    map[string]any{"amount": 100, "over-under": ">", "phase": 3},
+			want:       "Something went wrong",
+			private:    true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// Bets of the same user must be placed in different seconds.
+			placed := time.Now().Add(time.Duration(i) * time.Second)
+			got := bet.Handle(Request{UID: "user1", Time: placed, Subcommand: tc.subcommand, Options: tc.options})
+			if !strings.HasPrefix(got.Content, tc.want) || got.Private != tc.private {
+				t.Errorf("Handle() = %+v, want content starting %q and private %t", got, tc.want, tc.private)
+			}
+		})
+	}
+}
+
+func TestBalanceCommand(t *testing.T) {
+	c := newTestCore(t)
+	balance := &BalanceCommand{Core: c}
+	got := balance.Handle(Request{UID: "user1"})
+	if got.Content != "You have 1000 cakes (0 in bets)" || !got.Private {
+		t.Errorf("Handle() of own balance = %+v", got)
+	}
+	got = balance.Handle(Request{UID: "user1", Options: map[string]any{"user": "user2"}})
+	if got.Content != "<@user2> has 1000 cakes (0 in bets)" {
+		t.Errorf("Handle() of another user's balance = %+v", got)
+	}
+}
+
+func TestProfileCommand(t *testing.T) {
+	c := newTestCore(t)
+	profile := &ProfileCommand{Core: c}
+	got := profile.Handle(Request{UID: "user1", Options: map[string]any{"user": "user2"}})
+	if got.Content != "<@user2> doesn't have a profile yet." {
+		t.Errorf("Handle() of an unknown user = %+v", got)
+	}
+	if c.UserExists("user2") {
+		t.Errorf("looking up user2's profile made them an account")
+	}
+	c.GetUser("user1")
+	got = profile.Handle(Request{UID: "user2", Options: map[string]any{"user": "user1"}})
+	if !strings.Contains(got.Content, "Profile of <@user1>") {
+		t.Errorf("Handle() of a known user = %+v, want their profile", got)
+	}
+}
+
+func TestLedgerCommand(t *testing.T) {
+	c := newTestCore(t)
+	bet := NewBetCommand(c, env.EventConfig{EnableShiny: true})
+	bet.Handle(Request{UID: "user1", Time: time.Now(), Subcommand: "shiny", Options: map[string]any{"amount": 100, "over-under": ">", "phase": 3}})
+	got := NewLedgerCommand(c).Handle(Request{UID: "user2", Options: map[string]any{"event": "shiny"}})
+	if got.Page == nil || got.Page.Title != "Ledger for the shiny event" || !got.Private {
+		t.Fatalf("Handle() = %+v, want a private paged ledger", got)
+	}
+	if !strings.Contains(got.Content, "<@user1> placed 100 cakes on phase > 3") {
+		t.Errorf("ledger doesn't list the bet: %q", got.Content)
+	}
+}
+
+func TestLinkCommand(t *testing.T) {
+	c := newTestCore(t)
+	link := &LinkCommand{Core: c}
+	now := time.Now()
+	got := link.Handle(Request{UID: "user1", Identity: "user1", Time: now})
+	fields := strings.Fields(got.Content)
+	if len(fields) < 5 || !got.Private || !got.Secret {
+		t.Fatalf("Handle() without a code = %+v, want a secret code", got)
+	}
+	code := strings.TrimSuffix(fields[4], ".")
+	got = link.Handle(Request{UID: "twitch:1", Identity: "twitch:1", Time: now, Options: map[string]any{"code": strings.ToLower(code)}})
+	if !strings.HasPrefix(got.Content, "Linked!") {
+		t.Errorf("Handle() with the code = %+v, want linked", got)
+	}
+	if uid := c.Account("twitch:1"); uid != "user1" {
+		t.Errorf("twitch:1 is linked to %s, want user1", uid)
+	}
+	got = link.Handle(Request{UID: "twitch:2", Identity: "twitch:2", Time: now, Options: map[string]any{"code": code}})
+	if got.Content != "That link code is invalid or has expired." {
+		t.Errorf("Handle() with a used code = %+v", got)
+	}
+}
+
+func TestWagerErrorResponseLimits(t *testing.T) {
+	got := wagerErrorResponse(core.LimitError{Limit: core.LimitMinBet, Value: 10})
+	if want := "That bet is outside the betting limits: bets must be at least 10 cakes."; got.Content != want || !got.Private {
+		t.Errorf("wagerErrorResponse() = %+v, want %q", got, want)
+	}
+}
@@ -6,48 +6,31 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
-
-	"github.com/bwmarrin/discordgo"
 )
 
-func genericError(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-		Type: discordgo.InteractionResponseChannelMessageWithSource,
-		Data: &discordgo.InteractionResponseData{
-			Flags:   discordgo.MessageFlagsEphemeral,
-			Content: "Something went wrong processing your request. Please try again later.",
-		},
-	})
+func genericError() Response {
+	return private("Something went wrong processing your request. Please try again later.")
 }
 
-func respondToWagerError(s *discordgo.Session, i *discordgo.InteractionCreate, err error) {
+func wagerErrorResponse(err error) Response {
 	slog.Warn(fmt.Sprintf("error placing wager: %v", err))
-	if errors.Is(err, &core.BalanceError{}) {
-		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Flags:   discordgo.MessageFlagsEphemeral,
-				Content: "You don't have enough cakes to make that bet!",
-			},
-		})
-	} else if errors.Is(err, &events.PhaseLengthError{}) {
-		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Flags:   discordgo.MessageFlagsEphemeral,
-				Content: "The predicted phase needs to be greater than the current phase!",
-			},
-		})
-		return
-	} else if errors.Is(err, events.BettingClosedError{}) {
-		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Flags:   discordgo.MessageFlagsEphemeral,
-				Content: "Betting on this event is closed.",
-			},
-		})
-	} else {
-		genericError(s, i)
+	var limitErr core.LimitError
+	var lockoutErr events.LockoutError
+	switch {
+	case errors.Is(err, &core.BalanceError{}):
+		return private("You don't have enough cakes to make that bet!")
+	case errors.Is(err, &events.PhaseLengthError{}):
+		return private("The predicted phase needs to be greater than the current phase!")
+	case errors.As(err, &lockoutErr):
+		return private(fmt.Sprintf("The predicted phase needs to be more than %d encounters past the current phase!", lockoutErr.Encounters))
+	case errors.Is(err, events.BankError{}):
+		return private("The bank can't cover the payout of that bet right now. Try a smaller bet.")
+	case errors.Is(err, events.BettingClosedError{}):
+		return private("Betting on this event is closed.")
+	case errors.Is(err, core.DuplicateBetError{}):
+		return private("You already placed a bet this second. Try again in a moment.")
+	case errors.As(err, &limitErr):
+		return private(fmt.Sprintf("That bet is outside the betting limits: %s.", limitErr))
 	}
+	return genericError()
 }
@@ -0,0 +1,53 @@
+package commands
+
+import (
+	"bet/core"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	dailyReqs = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "core_commands_daily_total",
+		Help: "Number of times /daily was called",
+	})
+	dailySuccess = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "core_commands_daily_success",
+		Help: "Number of times /daily succeeded",
+	})
+)
+
+type DailyCommand struct {
+	Core *core.Core
+}
+
+func (c *DailyCommand) Handle(r Request) Response {
+	dailyReqs.Inc()
+	uid := r.UID
+	slog.Debug("daily interaction started", "user", uid)
+	amount, streak, err := c.Core.ClaimDaily(uid, r.Time)
+	var content string
+	var dailyErr core.DailyError
+	switch {
+	case errors.Is(err, core.NoDailyError{}):
+		content = "There is no daily allowance."
+	case errors.Is(err, core.HouseError{}):
+		content = "The bank doesn't get an allowance."
+	case errors.As(err, &dailyErr):
+		content = fmt.Sprintf("You've already claimed today's allowance. Come back <t:%d:R>.", dailyErr.Next.Unix())
+	case err != nil:
+		slog.Warn(fmt.Sprintf("%s could not claim daily allowance: %v", uid, err))
+		return genericError()
+	default:
+		content = fmt.Sprintf("You claimed %d cakes!", amount)
+		if streak > 1 {
+			content += fmt.Sprintf(" That's %d days in a row.", streak)
+		}
+	}
+	dailySuccess.Inc()
+	return private(content)
+}
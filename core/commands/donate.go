@@ -5,8 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"time"
 
-	"github.com/bwmarrin/discordgo"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
@@ -26,118 +26,93 @@ type DonateCommand struct {
 	Core *core.Core
 }
 
-func (c *DonateCommand) Command() *discordgo.ApplicationCommand {
-	return &discordgo.ApplicationCommand{
-		Name:        "donate",
-		Description: "Donate cakes to another user",
-		Options: []*discordgo.ApplicationCommandOption{
-			{
-				Name:        "amount",
-				Description: "How many cakes to give",
-				Type:        discordgo.ApplicationCommandOptionInteger,
-				Required:    true,
-			},
-			{
-				Name:        "to",
-				Description: "User to give cakes to",
-				Type:        discordgo.ApplicationCommandOptionUser,
-				Required:    true,
-			},
-		},
-	}
-}
-
-func (c *DonateCommand) Interaction(s *discordgo.Session, i *discordgo.InteractionCreate) {
+func (c *DonateCommand) Handle(r Request) Response {
 	donateReqs.Inc()
 	slog.Debug("donate interaction started")
-	options := i.ApplicationCommandData().Options
-	if options[0].IntValue() < 0 {
-		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Flags:   discordgo.MessageFlagsEphemeral,
-				Content: "It was worth a shot, wasn't it?",
-			},
-		})
+	amount, _ := r.Int("amount")
+	if amount < 0 {
 		donateSuccess.Inc()
-		return
-	}
-	if options[0].IntValue() == 0 {
-		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Flags:   discordgo.MessageFlagsEphemeral,
-				Content: "You must donate a positive number of cakes.",
-			},
-		})
-		return
-	}
-	amount := int(options[0].IntValue())
-	givingUserID := i.Interaction.Member.User.ID
-	takingUserID := options[1].UserValue(s).ID
+		return private("It was worth a shot, wasn't it?")
+	}
+	if amount == 0 {
+		return private("You must donate a positive number of cakes.")
+	}
+	givingUserID := r.UID
+	takingUserID, _ := r.String("to")
 	givingUser, err := c.Core.GetUser(givingUserID)
 	if err != nil {
 		slog.Warn(fmt.Sprintf("error getting giving user: %v", err))
-		genericError(s, i)
-		return
+		return genericError()
 	}
 	takingUser, err := c.Core.GetUser(takingUserID)
 	if err != nil {
 		slog.Warn(fmt.Sprintf("error getting taking user: %v", err))
-		genericError(s, i)
-		return
+		return genericError()
 	}
 	tx, err := c.Core.Database.OpenTransaction()
 	if err != nil {
 		slog.Warn(fmt.Sprintf("error opening transaction: %v", err))
-		genericError(s, i)
-		return
+		return genericError()
 	}
 	err = givingUser.Reserve(tx, amount)
 	if errors.Is(err, &core.BalanceError{}) {
-		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Flags:   discordgo.MessageFlagsEphemeral,
-				Content: "You don't have enough cakes to donate that much!",
-			},
-		})
-		return
+		return private("You don't have enough cakes to donate that much!")
 	} else if err != nil {
 		slog.Warn(fmt.Sprintf("error reserving donate amount: %v", err))
-		genericError(s, i)
-		return
+		return genericError()
 	}
 	err = givingUser.Resolve(tx, amount, true)
 	if err != nil {
 		slog.Warn(fmt.Sprintf("error resolving donate amount: %v", err))
-		genericError(s, i)
-		return
+		return genericError()
 	}
 	err = takingUser.Earn(tx, amount)
 	if err != nil {
 		slog.Warn(fmt.Sprintf("error earning donate amount: %v", err))
-		genericError(s, i)
-		return
+		return genericError()
+	}
+	err = tx.WriteDonation(givingUserID, takingUserID, time.Now(), amount)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("error writing donation: %v", err))
+		return genericError()
 	}
 	err = tx.Commit()
 	if err != nil {
 		slog.Warn(fmt.Sprintf("error committing transaction: %v", err))
-		genericError(s, i)
-		return
-	}
-	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-		Type: discordgo.InteractionResponseChannelMessageWithSource,
-		Data: &discordgo.InteractionResponseData{
-			Content: fmt.Sprintf("<@%s> donated %d cakes to <@%s>!", givingUserID, amount, takingUserID),
-			AllowedMentions: &discordgo.MessageAllowedMentions{
-				// Let's the user be tagged by ID so their name appears without
-				// pinging them everytime anyone uses the donate command.
-				// TODO: consider allowing taking user to be pinged, but this
-				// could be a spam vector.
-				Parse: []discordgo.AllowedMentionType{},
-			},
-		},
-	})
+		return genericError()
+	}
+	content := fmt.Sprintf("<@%s> donated %d cakes to <@%s>!", givingUserID, amount, takingUserID)
+	if unlock := c.awardPhilanthropist(givingUserID); unlock != nil {
+		content += fmt.Sprintf("\nAchievement unlocked! %s", unlock)
+	}
 	donateSuccess.Inc()
+	return Response{Content: content}
+}
+
+// Awards the philanthropist achievement if the user has now donated enough.
+// The donation has already gone through, so errors are only logged.
+func (c *DonateCommand) awardPhilanthropist(uid string) *core.Unlock {
+	total, err := c.Core.Database.TotalDonated(uid)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("error getting total donated: %v", err))
+		return nil
+	}
+	if total < core.PhilanthropistDonations {
+		return nil
+	}
+	tx, err := c.Core.Database.OpenTransaction()
+	if err != nil {
+		slog.Warn(fmt.Sprintf("error opening transaction: %v", err))
+		return nil
+	}
+	unlock, err := c.Core.Award(tx, uid, core.AchievementPhilanthropist)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("error awarding philanthropist: %v", err))
+		return nil
+	}
+	if err := tx.Commit(); err != nil {
+		slog.Warn(fmt.Sprintf("error committing transaction: %v", err))
+		return nil
+	}
+	return unlock
 }
@@ -0,0 +1,73 @@
+package commands
+
+import (
+	"bet/core"
+	"bet/core/db"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	jackpotReqs = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "core_commands_jackpot_total",
+		Help: "Number of times /jackpot was called",
+	})
+	jackpotSuccess = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "core_commands_jackpot_success",
+		Help: "Number of times /jackpot succeeded",
+	})
+)
+
+// jackpotHistoryLength is how many changes to the jackpot are shown.
+const jackpotHistoryLength = 50
+
+type JackpotCommand struct {
+	Core *core.Core
+}
+
+func (c *JackpotCommand) Handle(r Request) Response {
+	jackpotReqs.Inc()
+	slog.Debug("jackpot interaction started")
+	pool, err := c.Core.Database.Jackpot()
+	if err != nil {
+		slog.Warn(fmt.Sprintf("could not load jackpot: %v", err))
+		return genericError()
+	}
+	rows, err := c.Core.Database.JackpotHistory(jackpotHistoryLength)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("could not load jackpot history: %v", err))
+		return genericError()
+	}
+	content := fmt.Sprintf("The jackpot is %d cakes. It's paid out to everyone who bets on the exact phase when it happens.\n\nHistory:", pool)
+	var found bool
+	for rows.Next() {
+		var ts, eid, kind string
+		var amount int
+		if err := rows.Scan(&ts, &eid, &amount, &kind); err != nil {
+			slog.Warn(fmt.Sprintf("could not scan jackpot row: %v", err))
+			return genericError()
+		}
+		found = true
+		when := ts
+		if t, err := time.Parse(time.DateTime, ts); err == nil {
+			when = fmt.Sprintf("<t:%d:d>", t.Unix())
+		}
+		switch kind {
+		case db.JackpotRake:
+			content += fmt.Sprintf("\n * %s: %d cakes raked from the %s event", when, amount, eid)
+		case db.JackpotWin:
+			content += fmt.Sprintf("\n * %s: %d cakes won on the %s event", when, -amount, eid)
+		case db.JackpotRollover:
+			content += fmt.Sprintf("\n * %s: rolled over on the %s event", when, eid)
+		}
+	}
+	if !found {
+		content += "\nNothing has gone into the jackpot yet."
+	}
+	jackpotSuccess.Inc()
+	return paged(c.Core, "Jackpot", content, false)
+}
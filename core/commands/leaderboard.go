@@ -2,10 +2,10 @@ package commands
 
 import (
 	"bet/core"
+	"bet/core/db"
 	"fmt"
 	"log/slog"
 
-	"github.com/bwmarrin/discordgo"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
@@ -21,47 +21,90 @@ var (
 	})
 )
 
-type LeaderboardCommand struct {
-	Core *core.Core
+// leaderboardPageSize is how many ranks are shown on each page of the
+// leaderboard.
+const leaderboardPageSize = 10
+
+// leaderboardNeighbors is how many ranks are shown either side of the caller
+// when they ask to see where they are.
+const leaderboardNeighbors = 5
+
+var leaderboardTitles = map[string]string{
+	db.RankBalance:      "The top holders of cakes are:",
+	db.RankProfit:       "The most profitable bettors of all time are:",
+	db.RankSeasonProfit: "The most profitable bettors this season are:",
+	db.RankWinRate:      fmt.Sprintf("The best win rates (at least %d bets) are:", db.WinRateMinBets),
+	db.RankBiggestWin:   "The biggest single wins are:",
 }
 
-func (c *LeaderboardCommand) Command() *discordgo.ApplicationCommand {
-	return &discordgo.ApplicationCommand{
-		Name:        "leaderboard",
-		Description: "See the users with the most cakes",
-	}
+type LeaderboardCommand struct {
+	Core *core.Core
 }
 
-func (c *LeaderboardCommand) Interaction(s *discordgo.Session, i *discordgo.InteractionCreate) {
+func (c *LeaderboardCommand) Handle(r Request) Response {
 	leaderboardReqs.Inc()
-	slog.Debug("leaderboard interaction started")
-	rows, err := c.Core.Database.Leaderboard()
+	uid := r.UID
+	slog.Debug("leaderboard interaction started", "user", uid)
+	by := db.RankBalance
+	if o, ok := r.String("by"); ok {
+		by = o
+	}
+	page := 1
+	if o, ok := r.Int("page"); ok {
+		page = o
+	}
+	aroundMe, _ := r.Bool("around-me")
+	title, ok := leaderboardTitles[by]
+	if !ok {
+		slog.Warn(fmt.Sprintf("unknown leaderboard ranking %q", by))
+		return genericError()
+	}
+	offset := (page - 1) * leaderboardPageSize
+	limit := leaderboardPageSize
+	if aroundMe {
+		rank, err := c.Core.Database.RankOf(by, uid)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("could not get %s rank of %s: %s", by, uid, err))
+			return genericError()
+		}
+		if rank == 0 {
+			return private("You aren't ranked on that leaderboard yet.")
+		}
+		offset = max(0, rank-1-leaderboardNeighbors)
+		limit = 2*leaderboardNeighbors + 1
+	}
+	rows, err := c.Core.Database.Ranking(by, offset, limit)
 	if err != nil {
-		slog.Warn(fmt.Sprintf("could not get leaderboard: %s", err))
-		genericError(s, i)
-		return
+		slog.Warn(fmt.Sprintf("could not get %s leaderboard: %s", by, err))
+		return genericError()
 	}
-	content := "The top holders of cakes are:"
+	content := title
+	var found bool
 	for rows.Next() {
+		var rank int
 		var id string
-		var balance int
-		if err := rows.Scan(&id, &balance); err != nil {
+		var value float64
+		if err := rows.Scan(&rank, &id, &value); err != nil {
 			slog.Warn(fmt.Sprintf("DEBUG: could not scan leaderboard row: %s", err))
-			genericError(s, i)
-			return
+			return genericError()
 		}
-		content += fmt.Sprintf("\n 1. <@%s>: %d cakes", id, balance)
+		found = true
+		line := fmt.Sprintf("%d. <@%s>: %s", rank, id, formatRankValue(by, value))
+		if id == uid {
+			line = "**" + line + "**"
+		}
+		content += "\n" + line
+	}
+	if !found {
+		content = fmt.Sprintf("There is no one on page %d of that leaderboard.", page)
 	}
-	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-		Type: discordgo.InteractionResponseChannelMessageWithSource,
-		Data: &discordgo.InteractionResponseData{
-			Content: content,
-			AllowedMentions: &discordgo.MessageAllowedMentions{
-				// Let's the user be tagged by ID so their name appears without
-				// pinging them everytime anyone uses the leaderboard command.
-				Parse: []discordgo.AllowedMentionType{},
-			},
-		},
-	})
 	leaderboardSuccess.Inc()
+	return Response{Content: content}
+}
+
+func formatRankValue(by string, value float64) string {
+	if by == db.RankWinRate {
+		return fmt.Sprintf("%.0f%%", value*100)
+	}
+	return fmt.Sprintf("%d cakes", int(value))
 }
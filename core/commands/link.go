@@ -0,0 +1,67 @@
+package commands
+
+import (
+	"bet/core"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	linkReqs = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "core_commands_link_total",
+		Help: "Number of times /link was called",
+	})
+	linkSuccess = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "core_commands_link_success",
+		Help: "Number of times /link succeeded",
+	})
+)
+
+// LinkCommand links identities from other chats to the user's account.  Run
+// without a code it gives the user a code, which they then run the command
+// with from the chat they want to link.
+type LinkCommand struct {
+	Core *core.Core
+}
+
+func (c *LinkCommand) Handle(r Request) Response {
+	linkReqs.Inc()
+	slog.Debug("link interaction started", "user", r.UID, "identity", r.Identity)
+	code, ok := r.String("code")
+	if !ok {
+		code, err := c.Core.LinkCode(r.UID, r.Time)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("%s could not make link code: %v", r.UID, err))
+			return genericError()
+		}
+		linkSuccess.Inc()
+		return secret(fmt.Sprintf("Your link code is %s. Within %d minutes, send `!link %s` in Twitch chat (or `/link %s` from another account) to bet with the same cakes there.", code, int(core.LinkCodeTTL.Minutes()), code, code))
+	}
+	var content string
+	_, err := c.Core.Link(r.Identity, strings.TrimSpace(code), r.Time)
+	var linkedErr core.AlreadyLinkedError
+	switch {
+	case errors.Is(err, core.InvalidLinkCodeError{}):
+		content = "That link code is invalid or has expired."
+	case errors.As(err, &linkedErr):
+		content = "You're already linked to an account."
+	case errors.Is(err, core.LinkedAccountError{}):
+		content = "Other chats are already linked to your account, so it can't be linked to another."
+	case errors.Is(err, core.LinkOpenBetsError{}):
+		content = "You can't link while you have open bets. Try again once they've resolved."
+	case errors.Is(err, core.LinkHistoryError{}):
+		content = "This account can't be linked, since it already has cakes, a loan or bets of its own that would be lost. Only an account that hasn't played yet can be linked, so if the account that made the code hasn't played, make a code with `/link` on Discord from this account and link that one with it instead."
+	case err != nil:
+		slog.Warn(fmt.Sprintf("%s could not link: %v", r.Identity, err))
+		return genericError()
+	default:
+		content = "Linked! You now bet with the same cakes as the account that made the code."
+	}
+	linkSuccess.Inc()
+	return private(content)
+}
@@ -0,0 +1,91 @@
+package commands
+
+import (
+	"bet/core"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	loanReqs = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "core_commands_loan_total",
+		Help: "Number of times /loan was called",
+	})
+	loanSuccess = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "core_commands_loan_success",
+		Help: "Number of times /loan succeeded",
+	})
+)
+
+type LoanCommand struct {
+	Core *core.Core
+}
+
+func (c *LoanCommand) Handle(r Request) Response {
+	loanReqs.Inc()
+	uid := r.UID
+	slog.Debug("loan interaction started", "user", uid)
+	amount, ok := r.Int("amount")
+	if !ok {
+		content, err := c.status(uid)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("%s could not view loan: %v", uid, err))
+			return genericError()
+		}
+		loanSuccess.Inc()
+		return private(content)
+	}
+	var content string
+	err := c.Core.Borrow(uid, amount, r.Time)
+	var outstandingErr core.LoanOutstandingError
+	var limitErr core.LoanLimitError
+	switch {
+	case errors.Is(err, core.NoLoansError{}):
+		content = "Loans are not available."
+	case errors.Is(err, core.HouseError{}):
+		content = "The bank can't borrow cakes."
+	case errors.Is(err, core.LoanDefaultedError{}):
+		content = "You defaulted on a loan, so you can't borrow any more cakes."
+	case errors.As(err, &outstandingErr):
+		content = fmt.Sprintf("You still owe %d cakes. Pay that back before borrowing more.", outstandingErr.Owed)
+	case errors.As(err, &limitErr):
+		content = fmt.Sprintf("You can borrow at most %d cakes.", limitErr.Max)
+	case err != nil:
+		slog.Warn(fmt.Sprintf("%s could not borrow: %v", uid, err))
+		return genericError()
+	default:
+		content = fmt.Sprintf("You borrowed %d cakes. It will be repaid from your winnings, with %g%% interest every event.", amount, c.Core.Loans().InterestPercent)
+	}
+	loanSuccess.Inc()
+	return private(content)
+}
+
+// status describes the user's loan, or how much they could borrow.
+func (c *LoanCommand) status(uid string) (string, error) {
+	owed, events, defaulted, err := c.Core.Loan(uid)
+	if err != nil {
+		return "", err
+	}
+	if defaulted {
+		return fmt.Sprintf("You defaulted on your loan and still owe %d cakes. It will be repaid from your winnings.", owed), nil
+	}
+	if owed > 0 {
+		message := fmt.Sprintf("You owe %d cakes, borrowed %d events ago.", owed, events)
+		if term := c.Core.Loans().Term; term > 0 {
+			message += fmt.Sprintf(" You default if it isn't repaid in %d more events.", term-events)
+		}
+		return message, nil
+	}
+	if c.Core.Loans().Max <= 0 {
+		return "Loans are not available.", nil
+	}
+	max, err := c.Core.MaxLoan(uid)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("You don't owe anything. You can borrow up to %d cakes.", max), nil
+}
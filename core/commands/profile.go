@@ -0,0 +1,146 @@
+package commands
+
+import (
+	"bet/core"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	profileReqs = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "core_commands_profile_total",
+		Help: "Number of times /profile was called",
+	})
+	profileSuccess = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "core_commands_profile_success",
+		Help: "Number of times /profile succeeded",
+	})
+)
+
+type ProfileCommand struct {
+	Core *core.Core
+}
+
+func (c *ProfileCommand) Handle(r Request) Response {
+	profileReqs.Inc()
+	uid := r.UID
+	slog.Debug("profile interaction started", "user", uid)
+	if other, ok := r.String("user"); ok {
+		// Looking someone up shouldn't open an account for them.
+		if !c.Core.UserExists(other) {
+			profileSuccess.Inc()
+			return private(fmt.Sprintf("<@%s> doesn't have a profile yet.", other))
+		}
+		uid = other
+	}
+	user, err := c.Core.GetUser(uid)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("%s requested profile, could not fetch user: %s", uid, err))
+		return genericError()
+	}
+	balance, inBets, err := user.Balance()
+	if err != nil {
+		slog.Warn(fmt.Sprintf("%s requested profile, could not load from user object: %s", uid, err))
+		return genericError()
+	}
+	content := fmt.Sprintf("Profile of <@%s>\n%d cakes (%d in bets)", uid, balance, inBets)
+	if rank := loadRank(c.Core, uid); rank > 0 {
+		content += fmt.Sprintf(", rank %d on leaderboard", rank)
+	}
+	stats, err := c.stats(uid)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("could not load stats for %s: %s", uid, err))
+		return genericError()
+	}
+	content += stats
+	rows, err := c.Core.Database.LoadAchievements(uid)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("could not load achievements for %s: %s", uid, err))
+		return genericError()
+	}
+	badges := ""
+	for rows.Next() {
+		var aid string
+		var awarded string
+		if err := rows.Scan(&aid, &awarded); err != nil {
+			slog.Warn(fmt.Sprintf("could not scan achievement row: %s", err))
+			continue
+		}
+		a, ok := core.GetAchievement(aid)
+		if !ok {
+			slog.Warn(fmt.Sprintf("unknown achievement %s for user %s", aid, uid))
+			continue
+		}
+		badges += fmt.Sprintf("\n * **%s**: %s", a.Name, a.Description)
+		if ts, err := time.Parse(time.DateTime, awarded); err == nil {
+			badges += fmt.Sprintf(" (<t:%d:d>)", ts.Unix())
+		}
+	}
+	if badges == "" {
+		content += "\nNo achievements unlocked yet."
+	} else {
+		content += "\nAchievements:" + badges
+	}
+	profileSuccess.Inc()
+	return paged(c.Core, "Profile", content, true)
+}
+
+// stats builds the lifetime stats section of the profile.
+func (c *ProfileCommand) stats(uid string) (string, error) {
+	rows, err := c.Core.Database.UserStats(uid)
+	if err != nil {
+		return "", err
+	}
+	var wagered, profit, largest, resolved int
+	for rows.Next() {
+		if err := rows.Scan(&wagered, &profit, &largest, &resolved); err != nil {
+			return "", err
+		}
+	}
+	stats := fmt.Sprintf("\nLifetime stats:\n * %d cakes wagered", wagered)
+	stats += fmt.Sprintf("\n * %+d cakes net profit over %d resolved bets", profit, resolved)
+	if largest > 0 {
+		stats += fmt.Sprintf("\n * %d cakes largest single payout", largest)
+	}
+	streak, err := c.Core.Database.Streak(uid)
+	if err != nil {
+		return "", err
+	}
+	if streak > 0 {
+		stats += fmt.Sprintf("\n * On a %d bet winning streak", streak)
+	} else if streak < 0 {
+		stats += fmt.Sprintf("\n * On a %d bet losing streak", -streak)
+	}
+	rows, err = c.Core.Database.FavoriteDirection(uid)
+	if err != nil {
+		return "", err
+	}
+	for rows.Next() {
+		var direction string
+		var count int
+		if err := rows.Scan(&direction, &count); err != nil {
+			return "", err
+		}
+		stats += fmt.Sprintf("\n * Favorite bet is %s (%d bets)", direction, count)
+	}
+	rows, err = c.Core.Database.WinRates(uid)
+	if err != nil {
+		return "", err
+	}
+	for rows.Next() {
+		var eid string
+		var wins, losses int
+		if err := rows.Scan(&eid, &wins, &losses); err != nil {
+			return "", err
+		}
+		if wins+losses == 0 {
+			continue
+		}
+		stats += fmt.Sprintf("\n * %s: won %d of %d (%.1f%%)", eid, wins, wins+losses, 100*float64(wins)/float64(wins+losses))
+	}
+	return stats, nil
+}
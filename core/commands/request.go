@@ -0,0 +1,80 @@
+package commands
+
+import (
+	"bet/core"
+	"time"
+)
+
+// Command is a chat command that doesn't depend on the chat platform it's run
+// from.  Each platform adapts its own interactions into requests, and renders
+// the responses.
+type Command interface {
+	Handle(r Request) Response
+}
+
+// Request is a command run by a user.
+type Request struct {
+	// UID is the id of the account of the user who ran the command.
+	UID string
+	// Identity is the id the user is known by on the platform they ran the
+	// command from, which may be linked to another account.  See
+	// core.Core.Account.
+	Identity string
+	// Time is when the user sent the command.
+	Time time.Time
+	// Subcommand is the subcommand that was run, for commands that have them.
+	Subcommand string
+	// Options are the values given for the command's options by name.  Values
+	// are int, string or bool.  Users are given by the id of their account.
+	Options map[string]any
+}
+
+// Int returns the value of an integer option, and whether it was given.
+func (r Request) Int(name string) (int, bool) {
+	v, ok := r.Options[name].(int)
+	return v, ok
+}
+
+// String returns the value of a string or user option, and whether it was
+// given.
+func (r Request) String(name string) (string, bool) {
+	v, ok := r.Options[name].(string)
+	return v, ok
+}
+
+// Bool returns the value of a boolean option, and whether it was given.
+func (r Request) Bool(name string) (bool, bool) {
+	v, ok := r.Options[name].(bool)
+	return v, ok
+}
+
+// Response is the reply to a command.  Users may be mentioned as <@id>, which
+// platforms should show without pinging the user.
+type Response struct {
+	Content string
+	// Private responses are only shown to the user who ran the command.
+	Private bool
+	// Secret responses are private ones that mustn't be seen by anyone else,
+	// like link codes, so platforms that can't reply privately withhold them.
+	Secret bool
+	// Page is shown instead of the content when set, with controls to page
+	// through the rest of the message.
+	Page *core.Page
+}
+
+// paged responds with content as a paged message.
+func paged(c *core.Core, title, content string, private bool) Response {
+	page := c.Paginate(title, content)
+	return Response{Content: page.Content, Private: private, Page: &page}
+}
+
+// private responds with content only shown to the user who ran the command.
+func private(content string) Response {
+	return Response{Content: content, Private: true}
+}
+
+// secret responds with content that mustn't be seen by anyone but the user who
+// ran the command.
+func secret(content string) Response {
+	return Response{Content: content, Private: true, Secret: true}
+}
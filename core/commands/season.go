@@ -0,0 +1,89 @@
+package commands
+
+import (
+	"bet/core"
+	"bet/env"
+	"fmt"
+	"log/slog"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	seasonReqs = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "core_commands_season_total",
+		Help: "Number of times /season was called",
+	})
+	seasonSuccess = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "core_commands_season_success",
+		Help: "Number of times /season succeeded",
+	})
+)
+
+type SeasonCommand struct {
+	core *core.Core
+	conf env.SeasonConfig
+}
+
+func NewSeasonCommand(c *core.Core, conf env.SeasonConfig) *SeasonCommand {
+	return &SeasonCommand{core: c, conf: conf}
+}
+
+func (c *SeasonCommand) Handle(r Request) Response {
+	seasonReqs.Inc()
+	slog.Debug("season interaction started")
+	current := c.core.Database.LastSeason() + 1
+	season := current
+	if number, ok := r.Int("number"); ok {
+		season = number
+	}
+	var content string
+	switch {
+	case season > current:
+		content = fmt.Sprintf("Season %d hasn't started yet, this is season %d.", season, current)
+	case season == current:
+		content = fmt.Sprintf("Season %d is in progress.", current)
+		if start := c.core.Database.LastRun("season"); c.conf.Enable && !start.IsZero() {
+			content += fmt.Sprintf(" It ends <t:%d:R>.", start.Add(c.conf.Length).Unix())
+		}
+		rows, err := c.core.Database.Leaderboard()
+		if err != nil {
+			slog.Warn(fmt.Sprintf("could not get leaderboard: %s", err))
+			return genericError()
+		}
+		content += "\nThe current standings are:"
+		for rows.Next() {
+			var id string
+			var balance int
+			if err := rows.Scan(&id, &balance); err != nil {
+				slog.Warn(fmt.Sprintf("could not scan leaderboard row: %s", err))
+				return genericError()
+			}
+			content += fmt.Sprintf("\n 1. <@%s>: %d cakes", id, balance)
+		}
+	default:
+		rows, err := c.core.Database.SeasonStandings(season)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("could not get season %d standings: %s", season, err))
+			return genericError()
+		}
+		content = fmt.Sprintf("The final standings of season %d were:", season)
+		var found bool
+		for rows.Next() {
+			var id string
+			var balance int
+			if err := rows.Scan(&id, &balance); err != nil {
+				slog.Warn(fmt.Sprintf("could not scan season row: %s", err))
+				return genericError()
+			}
+			found = true
+			content += fmt.Sprintf("\n 1. <@%s>: %d cakes", id, balance)
+		}
+		if !found {
+			content = fmt.Sprintf("There are no standings recorded for season %d.", season)
+		}
+	}
+	seasonSuccess.Inc()
+	return Response{Content: content}
+}
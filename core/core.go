@@ -2,12 +2,13 @@ package core
 
 import (
 	"bet/core/db"
+	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
+	"strings"
 	"sync"
 	"time"
-
-	"github.com/bwmarrin/discordgo"
 )
 
 type Clock interface {
@@ -25,19 +26,59 @@ type Core struct {
 	// eventMu is a mutex to ensure that event closures do not overwrite user's
 	// state when committing to storage.
 	EventMu sync.Mutex
+	// WagerMu is held while placing a wager, so the checks that a wager can
+	// be covered see every wager placed before it.
+	WagerMu sync.Mutex
+	// eventsMu guards events, which can change when the configuration is
+	// reloaded.
+	eventsMu sync.RWMutex
 	// Events is the list of events that Core is handling
 	events map[string]Event
 	// Database is used for persisting new users.
 	Database db.Database
-	// session is the Discord session that can be used for interacting outside
+	// messenger is the chat platform that can be used for interacting outside
 	// of commands
-	session InteractionSession
+	messenger Messenger
 	// clock is used for time controls in crons. It is injected so that it can
 	// be used in unit tests.
 	clock Clock
+	// pages keeps the content of long messages, so they can be paged through
+	// with buttons.
+	pages *pageStore
+	// policyMu guards the policies, which can be changed while commands run.
+	policyMu sync.RWMutex
+	// allowance is the policy for giving users cakes so they can keep playing.
+	allowance AllowancePolicy
+	// loans is the policy for lending users cakes.  Loans are disabled by
+	// default.
+	loans LoanPolicy
+	// limits bounds how much users can bet.  There are no limits by default.
+	limits WagerLimits
+	// cancelWindow is how long after placing a bet it can be cancelled.  Bets
+	// can't be cancelled by default.
+	cancelWindow time.Duration
+	// identityMu guards identities and linkCodes.
+	identityMu sync.Mutex
+	// identities is a map from linked identity to the id of its account.
+	identities map[string]string
+	// linkCodes are the unredeemed codes for linking identities, keyed by the
+	// code.
+	linkCodes map[string]linkCode
+	// houseMu guards houses.
+	houseMu sync.Mutex
+	// houses is a map from the id of each house to its funding.
+	houses map[string]int
+	// cronMu guards crons.
+	cronMu sync.Mutex
+	// crons are the scheduled crons, keyed by their id, so they can be run on
+	// demand and removed.
+	crons map[string]scheduledCron
+	// cronLocks are held by each cron's id while it runs, so runs of the same
+	// cron never overlap.
+	cronLocks map[string]*sync.Mutex
 }
 
-func New(d db.Database, session InteractionSession, clock Clock) *Core {
+func New(d db.Database, messenger Messenger, clock Clock) *Core {
 	rows, err := d.LoadUsers()
 	if err != nil {
 		slog.Error(fmt.Sprintf("error loading users: %v", err))
@@ -52,13 +93,24 @@ func New(d db.Database, session InteractionSession, clock Clock) *Core {
 		}
 		users[u.id] = u
 	}
-	return &Core{
-		users:    users,
-		events:   make(map[string]Event),
-		Database: d,
-		session:  session,
-		clock:    clock,
+	c := &Core{
+		users:      users,
+		events:     make(map[string]Event),
+		Database:   d,
+		messenger:  messenger,
+		clock:      clock,
+		pages:      newPageStore(),
+		allowance:  DefaultAllowancePolicy,
+		identities: make(map[string]string),
+		linkCodes:  make(map[string]linkCode),
+		houses:     make(map[string]int),
+		crons:      make(map[string]scheduledCron),
+		cronLocks:  make(map[string]*sync.Mutex),
 	}
+	c.loadLoans()
+	c.loadIdentities()
+	c.loadHouses()
+	return c
 }
 
 func (c *Core) Close() {}
@@ -67,6 +119,7 @@ func (c *Core) Close() {}
 // User Operations //
 // //////////////////
 func (c *Core) GetUser(id string) (*user, error) {
+	id = c.Account(id)
 	u, ok := c.users[id]
 	if ok {
 		return u, nil
@@ -87,30 +140,228 @@ func (c *Core) GetUser(id string) (*user, error) {
 	return u, nil
 }
 
-// RefreshBalance makes it so that all users have at least 100 balance
-// remaining.
-func (c *Core) RefreshBalance() error {
-	slog.Debug("RefreshBalance called")
+// UserExists returns whether the user has an account, without creating one
+// like GetUser does.
+func (c *Core) UserExists(id string) bool {
+	_, ok := c.users[c.Account(id)]
+	return ok
+}
+
+// AdjustBalance adds delta to the user's balance, or takes it away when delta
+// is negative, and returns the new balance.  Cakes in open bets can't be taken
+// away.  The adjustment is recorded with the operator who made it and their
+// reason.  This locks EventMu, so must not be called while resolving events.
+func (c *Core) AdjustBalance(uid string, delta int, operator string, reason string) (int, error) {
+	if strings.TrimSpace(reason) == "" {
+		return 0, fmt.Errorf("a reason is needed to adjust a balance")
+	}
+	c.EventMu.Lock()
+	defer c.EventMu.Unlock()
+	u, err := c.GetUser(uid)
+	if err != nil {
+		return 0, err
+	}
+	balance, inBets, _ := u.Balance()
+	if balance+delta < inBets {
+		return 0, fmt.Errorf("can't take %d cakes from %s, who has %d of %d in bets", -delta, uid, inBets, balance)
+	}
 	tx, err := c.Database.OpenTransaction()
 	if err != nil {
+		return 0, err
+	}
+	if err := u.Earn(tx, delta); err != nil {
+		return 0, err
+	}
+	if err := tx.WriteAdjustment(u.id, c.Now(), delta, operator, reason); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	slog.Info(fmt.Sprintf("%s adjusted balance of %s by %d: %s", operator, u.id, delta, reason))
+	return balance + delta, nil
+}
+
+// Adjustment is a change an operator made to a user's balance.
+type Adjustment struct {
+	Time     time.Time
+	Amount   int
+	Operator string
+	Reason   string
+}
+
+// Adjustments returns the adjustments made to the user's balance, most recent
+// first.
+func (c *Core) Adjustments(uid string) ([]Adjustment, error) {
+	rows, err := c.Database.LoadAdjustments(c.Account(uid))
+	if err != nil {
+		return nil, err
+	}
+	adjustments := make([]Adjustment, 0)
+	for rows.Next() {
+		var a Adjustment
+		var ts string
+		if err := rows.Scan(&ts, &a.Amount, &a.Operator, &a.Reason); err != nil {
+			return nil, err
+		}
+		a.Time, err = time.Parse(time.DateTime, ts)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse adjustment time %s: %v", ts, err)
+		}
+		adjustments = append(adjustments, a)
+	}
+	return adjustments, nil
+}
+
+// CachedUser compares a user's balance in core's cache with what's stored in
+// the database.  Either is nil when the user is missing from it.
+type CachedUser struct {
+	UID    string
+	Cached *UserBalance
+	Stored *UserBalance
+}
+
+// UserBalance is a user's balance and how much of it is in bets.
+type UserBalance struct {
+	Balance int
+	InBets  int
+}
+
+// Matches returns whether the cache agrees with the database.
+func (u CachedUser) Matches() bool {
+	return u.Cached != nil && u.Stored != nil && *u.Cached == *u.Stored
+}
+
+// CompareUsers compares every cached user with the database, to check that
+// updates to one have made it to the other.  Users are ordered by id.
+func (c *Core) CompareUsers() ([]CachedUser, error) {
+	rows, err := c.Database.LoadUsers()
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*CachedUser)
+	for rows.Next() {
+		u, err := loadUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		byID[u.id] = &CachedUser{UID: u.id, Stored: &UserBalance{Balance: u.balance, InBets: u.inBets}}
+	}
+	for id, u := range c.users {
+		balance, inBets, _ := u.Balance()
+		cu, ok := byID[id]
+		if !ok {
+			cu = &CachedUser{UID: id}
+			byID[id] = cu
+		}
+		cu.Cached = &UserBalance{Balance: balance, InBets: inBets}
+	}
+	users := make([]CachedUser, 0, len(byID))
+	for _, u := range byID {
+		users = append(users, *u)
+	}
+	slices.SortFunc(users, func(a, b CachedUser) int {
+		return strings.Compare(a.UID, b.UID)
+	})
+	return users, nil
+}
+
+// EndSeason archives the current leaderboard as the standings for the given
+// season, and then resets every user's balance to the starting balance.  Houses
+// keep their balance.  Cakes that are in open bets are kept on top of the
+// reset, so those bets can still resolve.  Loans are forgiven, including
+// defaults, since they were borrowed against a balance that's gone.  This locks
+// EventMu, so must not be called while resolving events.
+func (c *Core) EndSeason(season int, ended time.Time) error {
+	c.EventMu.Lock()
+	defer c.EventMu.Unlock()
+	tx, err := c.Database.OpenTransaction()
+	if err != nil {
+		return err
+	}
+	if err := tx.ArchiveSeason(season, ended); err != nil {
 		return err
 	}
-	if err := tx.RefreshBalance(); err != nil {
+	if err := tx.ResetBalances(StartingBalance); err != nil {
+		return err
+	}
+	if err := tx.ClearLoans(); err != nil {
 		return err
 	}
 	if err := tx.Commit(); err != nil {
 		return err
 	}
-	// This is SUPER ugly, but because of transaction/locking, it's kind of a
-	// necessary evil for now.
-	for _, u := range c.users {
-		if u.balance < 100 {
-			u.balance = 100
+	// The balances were reset for every user in the database at once, so the
+	// cache has to be kept in line with the database by hand.
+	houses := c.fundings()
+	for id, u := range c.users {
+		if _, ok := houses[id]; ok {
+			continue
 		}
+		u.mu.Lock()
+		u.balance = StartingBalance + u.inBets
+		u.loan = loan{}
+		u.mu.Unlock()
 	}
 	return nil
 }
 
+// ////////////
+// Policies //
+// ////////////
+
+// Allowance returns the policy for giving users cakes.
+func (c *Core) Allowance() AllowancePolicy {
+	c.policyMu.RLock()
+	defer c.policyMu.RUnlock()
+	return c.allowance
+}
+
+func (c *Core) SetAllowance(policy AllowancePolicy) {
+	c.policyMu.Lock()
+	defer c.policyMu.Unlock()
+	c.allowance = policy
+}
+
+// Loans returns the policy for lending users cakes.
+func (c *Core) Loans() LoanPolicy {
+	c.policyMu.RLock()
+	defer c.policyMu.RUnlock()
+	return c.loans
+}
+
+func (c *Core) SetLoans(policy LoanPolicy) {
+	c.policyMu.Lock()
+	defer c.policyMu.Unlock()
+	c.loans = policy
+}
+
+// Limits returns the bounds on how much users can bet.
+func (c *Core) Limits() WagerLimits {
+	c.policyMu.RLock()
+	defer c.policyMu.RUnlock()
+	return c.limits
+}
+
+func (c *Core) SetLimits(limits WagerLimits) {
+	c.policyMu.Lock()
+	defer c.policyMu.Unlock()
+	c.limits = limits
+}
+
+// CancelWindow returns how long after placing a bet it can be cancelled.
+func (c *Core) CancelWindow() time.Duration {
+	c.policyMu.RLock()
+	defer c.policyMu.RUnlock()
+	return c.cancelWindow
+}
+
+func (c *Core) SetCancelWindow(window time.Duration) {
+	c.policyMu.Lock()
+	defer c.policyMu.Unlock()
+	c.cancelWindow = window
+}
+
 // ///////////////////
 // Event Operations //
 // ///////////////////
@@ -126,6 +377,8 @@ func (e *EventAlreadyExistsErr) Error() string {
 // placers can get the event outside of load time.  It is an error to register
 // 2 events by the same id.
 func (c *Core) RegisterEvent(id string, event Event) error {
+	c.eventsMu.Lock()
+	defer c.eventsMu.Unlock()
 	if _, ok := c.events[id]; ok {
 		slog.Warn(fmt.Sprintf("duplicate event registration: %s", id))
 		return &EventAlreadyExistsErr{eventId: id}
@@ -134,7 +387,59 @@ func (c *Core) RegisterEvent(id string, event Event) error {
 	return nil
 }
 
+// EventOpenBetsError is returned when unregistering an event that has bets
+// which haven't resolved yet.
+type EventOpenBetsError struct {
+	ID   string
+	Bets int
+}
+
+func (e EventOpenBetsError) Error() string {
+	return fmt.Sprintf("event %s has %d open bets", e.ID, e.Bets)
+}
+
+// UnregisterEvent stops handling the event, so it can be removed or replaced
+// with a new configuration.  Events with open bets can't be unregistered, so
+// those bets are never left without an event to resolve them.  Wagers placed
+// through Wager hold eventsMu, so none can land between checking the bets and
+// removing the event.
+func (c *Core) UnregisterEvent(id string) error {
+	c.eventsMu.Lock()
+	defer c.eventsMu.Unlock()
+	event, ok := c.events[id]
+	if !ok {
+		return fmt.Errorf("event of id %s is not registered", id)
+	}
+	// Bets since the event last opened stay in the database after they
+	// resolve, until it opens again.
+	if event.Status().State != EventClosed {
+		bets, err := c.loadBets(id)
+		if err != nil {
+			return err
+		}
+		if len(bets) > 0 {
+			return EventOpenBetsError{ID: id, Bets: len(bets)}
+		}
+	}
+	delete(c.events, id)
+	return nil
+}
+
+// Events returns the ids of the registered events in order.
+func (c *Core) Events() []string {
+	c.eventsMu.RLock()
+	defer c.eventsMu.RUnlock()
+	ids := make([]string, 0, len(c.events))
+	for id := range c.events {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
 func (c *Core) GetEvent(id string) (Event, error) {
+	c.eventsMu.RLock()
+	defer c.eventsMu.RUnlock()
 	if e, ok := c.events[id]; ok {
 		return e, nil
 	}
@@ -142,64 +447,231 @@ func (c *Core) GetEvent(id string) (Event, error) {
 	return nil, fmt.Errorf("event of id %s is not registered", id)
 }
 
-// ///////////////////////
-// Discord Interactions //
-// ///////////////////////
-type InteractionSession interface {
-	ChannelMessageSendComplex(string, *discordgo.MessageSend, ...discordgo.RequestOption) (*discordgo.Message, error)
+// Wager places the bet on the registered event, see Event.Wager.  The event
+// can't be unregistered while the wager is placed, so bets always have an
+// event to resolve them.
+func (c *Core) Wager(eid string, uid string, amount int, placed time.Time, bet any) (any, error) {
+	c.eventsMu.RLock()
+	defer c.eventsMu.RUnlock()
+	event, ok := c.events[eid]
+	if !ok {
+		slog.Warn(fmt.Sprintf("wager for non existent event: %s", eid))
+		return nil, fmt.Errorf("event of id %s is not registered", eid)
+	}
+	return event.Wager(uid, amount, placed, bet)
+}
+
+// ////////////////////
+// Chat Interactions //
+// ////////////////////
+
+// Messenger sends messages to a channel of a chat platform.  Messages may
+// mention users as <@id>, which the platform should show without pinging them.
+type Messenger interface {
+	Send(channel, message string) error
 }
 
+// Messengers sends every message with each of the messengers, so that events
+// can be followed from more than one chat platform.
+type Messengers []Messenger
+
+// Send sends the message with every messenger.  A messenger failing is logged
+// rather than returned, so one platform being down doesn't look like the
+// message wasn't sent.  An error is only returned when every messenger failed.
+func (ms Messengers) Send(channel, message string) error {
+	var errs []error
+	for _, m := range ms {
+		if err := m.Send(channel, message); err != nil {
+			slog.Warn(fmt.Sprintf("could not send message with %T: %v", m, err))
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) < len(ms) {
+		return nil
+	}
+	return errors.Join(errs...)
+}
+
+// MaxMessageLength is the most characters Discord allows in one message.
+const MaxMessageLength = 2000
+
+// SendMessage sends the message to the channel.  Messages that are too long for
+// Discord are split on line boundaries and sent as several messages in order.
+// Every chunk is sent even if one fails, and the errors are returned together.
 func (c *Core) SendMessage(channel, message string) error {
-	_, err := c.session.ChannelMessageSendComplex(channel, &discordgo.MessageSend{
-		Content: message,
-		AllowedMentions: &discordgo.MessageAllowedMentions{
-			// By default, we don't allow mentions so there's so the bot doesn't
-			// ping people awake.
-			Parse: []discordgo.AllowedMentionType{},
-		},
-	})
-	return err
+	var errs []error
+	for _, chunk := range SplitLines(message, MaxMessageLength) {
+		if err := c.messenger.Send(channel, chunk); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
 }
 
 // //////////////////
 // Cron Operations //
 // //////////////////
+// Now returns the time from core's clock, so crons can be tested with a fake
+// one.
+func (c *Core) Now() time.Time {
+	if c.clock == nil {
+		return time.Now()
+	}
+	return c.clock.Now()
+}
+
+// scheduledCron is a cron with the channel that stops its schedule.
+type scheduledCron struct {
+	cron Cron
+	stop chan struct{}
+}
+
+// AddCron schedules the cron, replacing any scheduled cron with the same id.
 func (c *Core) AddCron(cron Cron) {
+	stop := make(chan struct{})
+	c.cronMu.Lock()
+	if old, ok := c.crons[cron.ID()]; ok {
+		close(old.stop)
+	}
+	c.crons[cron.ID()] = scheduledCron{cron: cron, stop: stop}
+	c.cronMu.Unlock()
 	lastRun := c.Database.LastRun(cron.ID())
-	go c.schedule(cron, lastRun.Add(cron.After()))
+	go c.schedule(cron, lastRun.Add(cron.After()), stop)
 }
 
-func (c *Core) schedule(cron Cron, at time.Time) {
+// RemoveCron stops running the cron.  A run that has already started is left
+// to finish.
+func (c *Core) RemoveCron(id string) error {
+	c.cronMu.Lock()
+	defer c.cronMu.Unlock()
+	s, ok := c.crons[id]
+	if !ok {
+		return CronNotFoundError{ID: id}
+	}
+	close(s.stop)
+	delete(c.crons, id)
+	return nil
+}
+
+func (c *Core) schedule(cron Cron, at time.Time, stop <-chan struct{}) {
 	// If at is before now, run immediately, otherwise wait until the correct
 	// time to start.
 	if !at.Before(c.clock.Now()) {
 		wait := at.Sub(c.clock.Now())
-		<-c.clock.After(wait)
+		select {
+		case <-c.clock.After(wait):
+		case <-stop:
+			return
+		}
 	}
 	for {
 		go func() {
-			defer func() {
-				if r := recover(); r != nil {
-					slog.Error("recovering from panic in %q cron: %v", cron.ID(), r)
-				}
-			}()
-			if err := cron.Run(); err != nil {
-				slog.Error("error in %q cron: %v", cron.ID(), err)
-			}
-			tx, err := c.Database.OpenTransaction()
-			if err != nil {
-				slog.Error("error opening transaction for %q cron: %v", cron.ID(), err)
-				return
-			}
-			if err := tx.WriteCronRun(cron.ID(), c.clock.Now()); err != nil {
-				slog.Error("error writing run for %q cron: %v", cron.ID(), err)
-				return
-			}
-			if err := tx.Commit(); err != nil {
-				slog.Error("error committing run for %q cron: %v", cron.ID(), err)
-				return
+			if err := c.runCron(cron, true); err != nil {
+				slog.Error(err.Error())
 			}
 		}()
-		<-c.clock.After(cron.After())
+		select {
+		case <-c.clock.After(cron.After()):
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Crons returns the ids of the scheduled crons in order.
+func (c *Core) Crons() []string {
+	c.cronMu.Lock()
+	defer c.cronMu.Unlock()
+	ids := make([]string, 0, len(c.crons))
+	for id := range c.crons {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// CronSchedule is when a cron last ran and when it's next due to run.
+type CronSchedule struct {
+	ID   string
+	Last time.Time
+	Next time.Time
+}
+
+// CronSchedules returns the schedules of the scheduled crons in order of id.
+// Crons that have never run have a zero Last, and are due straight away.
+func (c *Core) CronSchedules() []CronSchedule {
+	c.cronMu.Lock()
+	crons := make([]Cron, 0, len(c.crons))
+	for _, s := range c.crons {
+		crons = append(crons, s.cron)
+	}
+	c.cronMu.Unlock()
+	slices.SortFunc(crons, func(a, b Cron) int {
+		return strings.Compare(a.ID(), b.ID())
+	})
+	schedules := make([]CronSchedule, 0, len(crons))
+	for _, cron := range crons {
+		id := cron.ID()
+		last := c.Database.LastRun(id)
+		s := CronSchedule{ID: id, Last: last, Next: c.clock.Now()}
+		if !last.IsZero() {
+			s.Next = last.Add(cron.After())
+		}
+		schedules = append(schedules, s)
+	}
+	return schedules
+}
+
+// RunCron runs the scheduled cron now, without waiting for its next run.  The
+// cron keeps to its schedule afterwards.  It isn't run if it's already
+// running.
+func (c *Core) RunCron(id string) error {
+	c.cronMu.Lock()
+	s, ok := c.crons[id]
+	c.cronMu.Unlock()
+	if !ok {
+		return CronNotFoundError{ID: id}
+	}
+	return c.runCron(s.cron, false)
+}
+
+// runCron runs the cron and records that it ran.  An error from the cron is
+// returned, but the run is still recorded.  Runs of the same cron never
+// overlap: when wait is true the run waits for the current one to finish,
+// otherwise it's skipped with a CronRunningError.
+func (c *Core) runCron(cron Cron, wait bool) (err error) {
+	id := cron.ID()
+	c.cronMu.Lock()
+	lock, ok := c.cronLocks[id]
+	if !ok {
+		lock = &sync.Mutex{}
+		c.cronLocks[id] = lock
+	}
+	c.cronMu.Unlock()
+	if wait {
+		lock.Lock()
+	} else if !lock.TryLock() {
+		return CronRunningError{ID: id}
+	}
+	defer lock.Unlock()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("recovering from panic in %q cron: %v", cron.ID(), r)
+		}
+	}()
+	runErr := cron.Run()
+	if runErr != nil {
+		runErr = fmt.Errorf("error in %q cron: %w", cron.ID(), runErr)
+	}
+	tx, err := c.Database.OpenTransaction()
+	if err != nil {
+		return errors.Join(runErr, fmt.Errorf("error opening transaction for %q cron: %w", cron.ID(), err))
+	}
+	if err := tx.WriteCronRun(cron.ID(), c.clock.Now()); err != nil {
+		return errors.Join(runErr, fmt.Errorf("error writing run for %q cron: %w", cron.ID(), err))
+	}
+	if err := tx.Commit(); err != nil {
+		return errors.Join(runErr, fmt.Errorf("error committing run for %q cron: %w", cron.ID(), err))
 	}
+	return runErr
 }
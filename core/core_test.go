@@ -2,6 +2,8 @@ package core
 
 import (
 	"bet/core/db"
+	"errors"
+	"strings"
 	"testing"
 	"time"
 )
@@ -73,3 +75,306 @@ func TestCron(t *testing.T) {
 	<-notify
 	c.Close()
 }
+
+func TestRunCron(t *testing.T) {
+	d := db.Fake()
+	now := time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)
+	c := New(d, nil, NewFakeClock(now))
+	notify := make(chan bool, 1)
+	// The cron last ran just now, so it isn't due for a while.
+	tx, _ := d.OpenTransaction()
+	tx.WriteCronRun("fake-cron", now)
+	c.AddCron(&FakeCron{notify: notify})
+	if got := c.Crons(); len(got) != 1 || got[0] != "fake-cron" {
+		t.Errorf("Crons() = %v, want [fake-cron]", got)
+	}
+	if err := c.RunCron("fake-cron"); err != nil {
+		t.Fatalf("RunCron() returned unexpected error: %v", err)
+	}
+	select {
+	case <-notify:
+	default:
+		t.Errorf("RunCron() didn't run the cron")
+	}
+	if err := c.RunCron("other"); !errors.Is(err, CronNotFoundError{ID: "other"}) {
+		t.Errorf("RunCron(other) = %v, want CronNotFoundError", err)
+	}
+}
+
+// blockingCron runs until it's released.
+type blockingCron struct {
+	started chan bool
+	release chan bool
+}
+
+func (b *blockingCron) ID() string { return "blocking" }
+
+func (b *blockingCron) After() time.Duration { return time.Hour }
+
+func (b *blockingCron) Run() error {
+	b.started <- true
+	<-b.release
+	return nil
+}
+
+func TestRunCronOverlap(t *testing.T) {
+	d := db.Fake()
+	now := time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)
+	c := New(d, nil, NewFakeClock(now))
+	tx, _ := d.OpenTransaction()
+	tx.WriteCronRun("blocking", now)
+	cron := &blockingCron{started: make(chan bool), release: make(chan bool)}
+	c.AddCron(cron)
+	done := make(chan error)
+	go func() { done <- c.RunCron("blocking") }()
+	<-cron.started
+	if err := c.RunCron("blocking"); !errors.Is(err, CronRunningError{ID: "blocking"}) {
+		t.Errorf("RunCron() while running = %v, want CronRunningError", err)
+	}
+	cron.release <- true
+	if err := <-done; err != nil {
+		t.Errorf("RunCron() returned unexpected error: %v", err)
+	}
+	// Once finished, it can run again.
+	go func() { done <- c.RunCron("blocking") }()
+	<-cron.started
+	cron.release <- true
+	if err := <-done; err != nil {
+		t.Errorf("RunCron() after the last run returned unexpected error: %v", err)
+	}
+}
+
+func TestRemoveCron(t *testing.T) {
+	d := db.Fake()
+	now := time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)
+	c := New(d, nil, NewFakeClock(now))
+	tx, _ := d.OpenTransaction()
+	tx.WriteCronRun("fake-cron", now)
+	c.AddCron(&FakeCron{notify: make(chan bool, 1)})
+	if err := c.RemoveCron("fake-cron"); err != nil {
+		t.Fatalf("RemoveCron() returned unexpected error: %v", err)
+	}
+	if got := c.Crons(); len(got) != 0 {
+		t.Errorf("Crons() = %v after removing, want none", got)
+	}
+	if err := c.RemoveCron("fake-cron"); !errors.Is(err, CronNotFoundError{ID: "fake-cron"}) {
+		t.Errorf("RemoveCron() twice = %v, want CronNotFoundError", err)
+	}
+}
+
+func TestCompareUsers(t *testing.T) {
+	d := db.FakeWithUsers()
+	c := New(d, nil, nil)
+	for _, uid := range []string{"user1", "user2"} {
+		if _, err := c.GetUser(uid); err != nil {
+			t.Fatalf("GetUser(%s) returned unexpected error: %v", uid, err)
+		}
+	}
+	// The cache drifts from the database, and a user is added to the
+	// database behind core's back.
+	c.users["user2"].balance = 5
+	tx, _ := d.OpenTransaction()
+	tx.WriteNewUser("user3", 10, 0)
+
+	got, err := c.CompareUsers()
+	if err != nil {
+		t.Fatalf("CompareUsers() returned unexpected error: %v", err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("CompareUsers() = %+v, want 3 users", got)
+	}
+	for i, want := range []struct {
+		uid     string
+		matches bool
+	}{{"user1", true}, {"user2", false}, {"user3", false}} {
+		if got[i].UID != want.uid || got[i].Matches() != want.matches {
+			t.Errorf("CompareUsers()[%d] = %+v, want %s matching %t", i, got[i], want.uid, want.matches)
+		}
+	}
+	if got[1].Cached.Balance != 5 || got[1].Stored.Balance != StartingBalance {
+		t.Errorf("CompareUsers() of user2 = %+v %+v, want cached 5 and stored %d", *got[1].Cached, *got[1].Stored, StartingBalance)
+	}
+	if got[2].Cached != nil {
+		t.Errorf("CompareUsers() of user3 has a cached balance %+v, want none", *got[2].Cached)
+	}
+}
+
+func TestSplitLines(t *testing.T) {
+	for _, tc := range []struct {
+		content string
+		limit   int
+		want    []string
+	}{
+		{
+			content: "short",
+			limit:   10,
+			want:    []string{"short"},
+		},
+		{
+			content: "one\ntwo\nthree",
+			limit:   7,
+			want:    []string{"one\ntwo", "three"},
+		},
+		{
+			content: "a line that is too long\nok",
+			limit:   10,
+			want:    []string{"a line tha", "t is too l", "ong\nok"},
+		},
+		{
+			// Limits count characters, not bytes, and cakes aren't cut in
+			// half.
+			content: "🎂🎂🎂\nok",
+			limit:   2,
+			want:    []string{"🎂🎂", "🎂", "ok"},
+		},
+		{
+			content: "",
+			limit:   10,
+			want:    []string{""},
+		},
+	} {
+		got := SplitLines(tc.content, tc.limit)
+		if strings.Join(got, "|") != strings.Join(tc.want, "|") {
+			t.Errorf("SplitLines(%q, %d) = %q, want %q", tc.content, tc.limit, got, tc.want)
+		}
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	c := New(db.Fake(), nil, nil)
+	page := c.Paginate("short", "fits on one page")
+	if page.ID != "" || page.Total != 1 {
+		t.Errorf("Paginate() of a short message = %+v, want a single unstored page", page)
+	}
+	if page.Content != "fits on one page" {
+		t.Errorf("Paginate() content = %q, want %q", page.Content, "fits on one page")
+	}
+
+	content := strings.Repeat("a line of a long message\n", 200)
+	page = c.Paginate("long", content)
+	if page.ID == "" || page.Total < 2 || page.Index != 0 {
+		t.Fatalf("Paginate() of a long message = %+v, want the first of several stored pages", page)
+	}
+	next, err := c.Page(page.ID, 1)
+	if err != nil {
+		t.Fatalf("Page(%s, 1) returned unexpected error: %v", page.ID, err)
+	}
+	if next.Index != 1 || next.Title != "long" {
+		t.Errorf("Page(%s, 1) = %+v, want the second page", page.ID, next)
+	}
+	if last, _ := c.Page(page.ID, 1000); last.Index != page.Total-1 {
+		t.Errorf("Page(%s, 1000) index = %d, want the last page %d", page.ID, last.Index, page.Total-1)
+	}
+	if _, err := c.Page("404", 0); err == nil {
+		t.Errorf("Page() of an unknown message returned no error")
+	}
+}
+
+// fakeEvent is an event that only keeps its state, and stores the wagers
+// placed on it as bets.
+type fakeEvent struct {
+	c     *Core
+	id    string
+	state EventState
+}
+
+func (e *fakeEvent) Open(time.Time) error  { return nil }
+func (e *fakeEvent) Update(any)            {}
+func (e *fakeEvent) Close(time.Time) error { return nil }
+func (e *fakeEvent) Resolve() error        { return nil }
+func (e *fakeEvent) Interpret(blob string) string {
+	return blob
+}
+func (e *fakeEvent) BetsSummary(string) (string, error) { return "", nil }
+func (e *fakeEvent) Status() EventStatus {
+	return EventStatus{ID: e.id, State: e.state}
+}
+func (e *fakeEvent) Cancel(string, time.Time, time.Time) error { return nil }
+
+func (e *fakeEvent) Wager(uid string, amount int, placed time.Time, bet any) (any, error) {
+	tx, err := e.c.Database.OpenTransaction()
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.WriteBet(uid, e.id, placed, amount, 0.0, "true"); err != nil {
+		return nil, err
+	}
+	return nil, tx.Commit()
+}
+
+func TestUnregisterEvent(t *testing.T) {
+	c := New(db.Fake(), nil, nil)
+	open := &fakeEvent{c: c, id: "open", state: EventOpen}
+	closed := &fakeEvent{c: c, id: "closed", state: EventClosed}
+	if err := c.RegisterEvent("open", open); err != nil {
+		t.Fatalf("RegisterEvent(open) returned unexpected error: %v", err)
+	}
+	if err := c.RegisterEvent("closed", closed); err != nil {
+		t.Fatalf("RegisterEvent(closed) returned unexpected error: %v", err)
+	}
+	// Resolved bets stay stored until the event opens again, so only the
+	// open event's bet still needs the event.
+	for _, eid := range []string{"open", "closed"} {
+		if _, err := c.Wager(eid, "user", 100, time.Now(), true); err != nil {
+			t.Fatalf("Wager(%s) returned unexpected error: %v", eid, err)
+		}
+	}
+
+	var openErr EventOpenBetsError
+	if err := c.UnregisterEvent("open"); !errors.As(err, &openErr) || openErr.Bets != 1 {
+		t.Errorf("UnregisterEvent(open) = %v, want EventOpenBetsError with 1 bet", err)
+	}
+	if err := c.UnregisterEvent("closed"); err != nil {
+		t.Errorf("UnregisterEvent(closed) returned unexpected error: %v", err)
+	}
+	if got := c.Events(); len(got) != 1 || got[0] != "open" {
+		t.Errorf("Events() = %v, want [open]", got)
+	}
+	if err := c.UnregisterEvent("closed"); err == nil {
+		t.Errorf("UnregisterEvent(closed) twice returned no error")
+	}
+	// Wagers can't reach an event once it's unregistered.
+	if _, err := c.Wager("closed", "user", 100, time.Now(), true); err == nil {
+		t.Errorf("Wager() on an unregistered event returned no error")
+	}
+}
+
+type fakeMessenger struct {
+	sent []string
+	err  error
+}
+
+func (f *fakeMessenger) Send(channel, message string) error {
+	f.sent = append(f.sent, message)
+	return f.err
+}
+
+func TestMessengers(t *testing.T) {
+	failing := &fakeMessenger{err: errors.New("offline")}
+	working := &fakeMessenger{}
+	ms := Messengers{failing, working}
+	// A messenger failing doesn't stop the message going to the others, and
+	// isn't an error while one of them works.
+	if err := ms.Send("channel", "hello"); err != nil {
+		t.Errorf("Send() returned unexpected error: %v", err)
+	}
+	if len(working.sent) != 1 || working.sent[0] != "hello" {
+		t.Errorf("got sent %q, want [hello]", working.sent)
+	}
+	if err := (Messengers{failing}).Send("channel", "hello"); err == nil {
+		t.Errorf("expected an error when every messenger failed")
+	}
+}
+
+func TestSendMessageAllChunks(t *testing.T) {
+	failing := &fakeMessenger{err: errors.New("offline")}
+	c := New(db.Fake(), failing, nil)
+	message := strings.Repeat("a", MaxMessageLength) + "\nb"
+	if err := c.SendMessage("channel", message); err == nil {
+		t.Errorf("expected the messenger's errors")
+	}
+	// Every chunk is tried even though the first failed.
+	if len(failing.sent) != 2 {
+		t.Errorf("got %d chunks sent, want 2", len(failing.sent))
+	}
+}
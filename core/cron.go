@@ -1,6 +1,9 @@
 package core
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 // Cron is the interface for a Core to periodically run tasks.
 type Cron interface {
@@ -13,3 +16,22 @@ type Cron interface {
 	// otherwise ignored. Panics are also caught and recovered.
 	Run() error
 }
+
+// CronNotFoundError is returned when running a cron that isn't scheduled.
+type CronNotFoundError struct {
+	ID string
+}
+
+func (e CronNotFoundError) Error() string {
+	return fmt.Sprintf("no cron with id %q is scheduled", e.ID)
+}
+
+// CronRunningError is returned when running a cron that's already running, so
+// a manual run can't overlap a scheduled one.
+type CronRunningError struct {
+	ID string
+}
+
+func (e CronRunningError) Error() string {
+	return fmt.Sprintf("cron %q is already running", e.ID)
+}
@@ -0,0 +1,94 @@
+// season implements a cron which ends the current season.  The final standings
+// are archived, and every balance is reset so that newer players have a chance
+// to climb the leaderboard.
+package crons
+
+import (
+	"bet/core"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+type SeasonCron struct {
+	after   time.Duration
+	channel string
+	core    *core.Core
+	// testing only, same as the self bet cron.
+	done chan bool
+}
+
+func NewSeasonCron(c *core.Core, length time.Duration, channel string) *SeasonCron {
+	cron := &SeasonCron{
+		after:   length,
+		channel: channel,
+		core:    c,
+		done:    make(chan bool, 1),
+	}
+	// A cron that has never run is run immediately, which would end a season
+	// the moment seasons are turned on.  Instead, mark now as the start of the
+	// first season.
+	if c.Database.LastRun(cron.ID()).IsZero() {
+		if err := writeRun(c, cron.ID(), c.Now()); err != nil {
+			slog.Error(fmt.Sprintf("could not start the first season: %v", err))
+		}
+	}
+	return cron
+}
+
+func writeRun(c *core.Core, id string, ts time.Time) error {
+	tx, err := c.Database.OpenTransaction()
+	if err != nil {
+		return err
+	}
+	if err := tx.WriteCronRun(id, ts); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (c *SeasonCron) ID() string {
+	return "season"
+}
+
+func (c *SeasonCron) After() time.Duration {
+	return c.after
+}
+
+func (c *SeasonCron) Run() error {
+	slog.Info("starting season cron")
+	defer func() {
+		// Nothing reads done outside of tests, so don't block when it's full.
+		select {
+		case c.done <- true:
+		default:
+		}
+	}()
+	season := c.core.Database.LastSeason() + 1
+	if err := c.core.EndSeason(season, c.core.Now()); err != nil {
+		return err
+	}
+	message := strings.Builder{}
+	fmt.Fprintf(&message, "Season %d has ended! Every balance has been reset to %d cakes.", season, core.StartingBalance)
+	rows, err := c.core.Database.SeasonStandings(season)
+	if err != nil {
+		return err
+	}
+	medals := []string{"🥇", "🥈", "🥉"}
+	var place int
+	for rows.Next() {
+		var uid string
+		var balance int
+		if err := rows.Scan(&uid, &balance); err != nil {
+			slog.Warn(fmt.Sprintf("could not scan season standings: %v", err))
+			continue
+		}
+		// Still need to iterate every row so the database isn't left locked.
+		if place < len(medals) {
+			fmt.Fprintf(&message, "\n%s <@%s> with %d cakes", medals[place], uid, balance)
+		}
+		place++
+	}
+	return c.core.SendMessage(c.channel, message.String())
+}
@@ -17,6 +17,7 @@ import (
 type PhaseEvent interface {
 	core.Event
 	Current() int
+	Probability() float64
 }
 
 type SelfBetCron struct {
@@ -49,7 +50,13 @@ func (c *SelfBetCron) After() time.Duration {
 
 func (c *SelfBetCron) Run() error {
 	slog.Info("starting self bet cron")
-	defer func() { c.done <- true }()
+	defer func() {
+		// Nothing reads done outside of tests, so don't block when it's full.
+		select {
+		case c.done <- true:
+		default:
+		}
+	}()
 	me, err := c.core.GetUser(c.user)
 	if err != nil {
 		return err
@@ -75,17 +82,19 @@ func (c *SelfBetCron) Run() error {
 	}
 	// Pick the phase length to bet on
 	psp := rand.Float64()
-	length := math.Log(psp) / math.Log(8191.0/8192.0)
-	// Always pick the direction with higher risk
+	logInverseProb := math.Log(1 - pe.Probability())
+	length := math.Log(psp) / logInverseProb
+	// Always pick the direction with higher risk, which flips at the median
+	// phase length.
 	dir := events.LESS
 	dirStr := "less"
-	if length > 5678 {
+	if length > math.Log(0.5)/logInverseProb {
 		dir = events.GREATER
 		dirStr = "greater"
 	}
 	// And finally add the length to the current phase.
 	betLength := pe.Current() + int(length)
-	p, err := pe.Wager(c.user, available, time.Now(), events.PhaseBet{Direction: dir, Phase: betLength})
+	p, err := c.core.Wager("shiny", c.user, available, c.core.Now(), events.PhaseBet{Direction: dir, Phase: betLength})
 	if err != nil {
 		return err
 	}
@@ -4,10 +4,9 @@ import (
 	"bet/core"
 	"bet/core/db"
 	"bet/core/events"
+	"bet/env"
 	"testing"
 	"time"
-
-	"github.com/bwmarrin/discordgo"
 )
 
 type fakeClock struct {
@@ -50,9 +49,9 @@ type FakeSession struct {
 	SendCount int
 }
 
-func (f *FakeSession) ChannelMessageSendComplex(string, *discordgo.MessageSend, ...discordgo.RequestOption) (*discordgo.Message, error) {
+func (f *FakeSession) Send(string, string) error {
 	f.SendCount++
-	return nil, nil
+	return nil
 }
 
 func TestSelfBetCron(t *testing.T) {
@@ -61,7 +60,7 @@ func TestSelfBetCron(t *testing.T) {
 	clock := NewFakeClock(time.Time{})
 	c := core.New(d, s, clock)
 	user, _ := c.GetUser("test")
-	shiny := events.NewShinyEvent(c, "")
+	shiny := events.NewShinyEvent(c, env.OddsConfig{}, env.PhaseEventConfig{}, "")
 	c.RegisterEvent("shiny", shiny)
 	cron := NewSelfBetCron(c, "test", time.Second, "test-channel")
 	c.AddCron(cron)
@@ -96,3 +95,47 @@ func TestSelfBetCron(t *testing.T) {
 		t.Errorf("got %d sent messages, want 2", s.SendCount)
 	}
 }
+
+func TestSeasonCron(t *testing.T) {
+	d := db.Fake()
+	s := &FakeSession{}
+	clock := NewFakeClock(time.Time{})
+	c := core.New(d, s, clock)
+	// Pretend the first season started at the fake clock's start time.
+	tx, _ := d.OpenTransaction()
+	tx.WriteCronRun("season", time.Time{}.Add(time.Second))
+	tx.Commit()
+	user, _ := c.GetUser("test")
+	tx, _ = d.OpenTransaction()
+	user.Earn(tx, 500)
+	user.Reserve(tx, 200)
+	tx.Commit()
+
+	cron := NewSeasonCron(c, time.Hour, "test-channel")
+	c.AddCron(cron)
+	clock.Set(time.Time{}.Add(time.Hour + time.Second))
+	<-cron.done
+	balance, inBets, _ := user.Balance()
+	if balance != 1200 {
+		t.Errorf("user has %d balance, wanted 1200 (reset plus cakes in bets)", balance)
+	}
+	if inBets != 200 {
+		t.Errorf("user has %d in bets, wanted 200 (bets are untouched)", inBets)
+	}
+	if got := d.LastSeason(); got != 1 {
+		t.Errorf("LastSeason() = %d after the first season ended, want 1", got)
+	}
+	if s.SendCount != 1 {
+		t.Errorf("got %d sent messages, want 1", s.SendCount)
+	}
+}
+
+func TestSeasonCronStartsFirstSeason(t *testing.T) {
+	d := db.Fake()
+	start := time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)
+	c := core.New(d, &FakeSession{}, NewFakeClock(start))
+	NewSeasonCron(c, time.Hour, "test-channel")
+	if got := d.LastRun("season"); !got.Equal(start) {
+		t.Errorf("the first season started at %v, want the clock's %v", got, start)
+	}
+}
@@ -5,6 +5,7 @@ package db
 
 import (
 	"database/sql"
+	"fmt"
 	"time"
 
 	_ "modernc.org/sqlite"
@@ -18,12 +19,56 @@ type Database interface {
 	LoadUser(uid string) (Scanner, error)
 	LoadBets(eid string) (Scanner, error)
 	Leaderboard() (Scanner, error)
+	Ranking(by string, offset, limit int) (Scanner, error)
+	RankOf(by string, uid string) (int, error)
 	LoadUserBets(uid string) (Scanner, error)
+	BetPlaced(uid string, placed time.Time) (bool, error)
 	Rank(uid string) (Scanner, error)
 	LastRun(id string) time.Time
+	LastSeason() int
+	SeasonStandings(season int) (Scanner, error)
+	LoadAchievements(uid string) (Scanner, error)
+	TotalDonated(uid string) (int, error)
+	UserStats(uid string) (Scanner, error)
+	WinRates(uid string) (Scanner, error)
+	FavoriteDirection(uid string) (Scanner, error)
+	Streak(uid string) (int, error)
+	PhaseHistory(eid string) (int, int, error)
+	Jackpot() (int, error)
+	LastGrants(kind string) (Scanner, error)
+	LoadLoans() (Scanner, error)
+	LoadIdentities() (Scanner, error)
+	LoadHouses() (Scanner, error)
+	LoadAdjustments(uid string) (Scanner, error)
+	APITokenUser(hash string) (string, error)
+	LastGrant(uid string, kind string) (Scanner, error)
+	JackpotHistory(limit int) (Scanner, error)
+	RecentResolutions(limit int) (Scanner, error)
 	OpenTransaction() (Transaction, error)
 }
 
+// Bet outcomes, as recorded in the results table.
+const (
+	OutcomeLoss   = -1
+	OutcomeRefund = 0
+	OutcomeWin    = 1
+)
+
+// Kinds of changes to the jackpot, as recorded in the jackpot table.
+const (
+	JackpotRake     = "rake"
+	JackpotWin      = "win"
+	JackpotRollover = "rollover"
+)
+
+// Kinds of grants given to users, as recorded in the grants table.
+const (
+	GrantBailout = "bailout"
+	GrantDaily   = "daily"
+	// Funding tops up a house so it can keep paying out.
+	GrantFunding = "funding"
+)
+
 // Scanner for mocking
 type Scanner interface {
 	Next() bool
@@ -79,6 +124,107 @@ func (d *DB) Leaderboard() (Scanner, error) {
 	return d.db.Query(`SELECT id, balance FROM leaderboard LIMIT 10;`)
 }
 
+// Ways users can be ranked on the leaderboard.
+const (
+	RankBalance      = "balance"
+	RankProfit       = "profit"
+	RankSeasonProfit = "season"
+	RankWinRate      = "winrate"
+	RankBiggestWin   = "biggest"
+)
+
+// WinRateMinBets is how many resolved bets a user needs to be ranked by win
+// rate, so a single lucky bet doesn't top the leaderboard.
+const WinRateMinBets = 3
+
+// rankings are queries for the id and value columns each ranking orders by.
+var rankings = map[string]string{
+	RankBalance: `SELECT id, balance AS value FROM users`,
+	RankProfit: `
+	SELECT uid AS id, SUM(payout) AS value FROM results
+	GROUP BY uid`,
+	// Season profit counts the results since the last season ended.
+	RankSeasonProfit: `
+	SELECT uid AS id, SUM(payout) AS value FROM results
+	WHERE unixepoch(resolved) > COALESCE((SELECT MAX(unixepoch(ended)) FROM seasons), 0)
+	GROUP BY uid`,
+	// Refunds aren't counted as bets for win rate.
+	RankWinRate: fmt.Sprintf(`
+	SELECT uid AS id, 1.0 * SUM(outcome = 1) / COUNT(*) AS value FROM results
+	WHERE outcome != 0
+	GROUP BY uid
+	HAVING COUNT(*) >= %d`, WinRateMinBets),
+	RankBiggestWin: `
+	SELECT uid AS id, MAX(payout) AS value FROM results
+	WHERE outcome = 1
+	GROUP BY uid`,
+}
+
+func rankedQuery(by string) (string, error) {
+	q, ok := rankings[by]
+	if !ok {
+		return "", fmt.Errorf("unknown ranking %q", by)
+	}
+	return fmt.Sprintf(`
+	WITH ranked(id, value, rank) AS (
+	  SELECT id, value, row_number() OVER (ORDER BY value DESC, id)
+	  FROM (%s)
+	  WHERE id NOT IN (SELECT id FROM houses)
+	)`, q), nil
+}
+
+// Loads a page of the leaderboard ranked by the given ranking, as rows of
+// rank, id and value.  Values are floats because win rates are fractions.
+func (d *DB) Ranking(by string, offset, limit int) (Scanner, error) {
+	q, err := rankedQuery(by)
+	if err != nil {
+		return nil, err
+	}
+	return d.db.Query(q+`
+	SELECT rank, id, value FROM ranked
+	ORDER BY rank
+	LIMIT ? OFFSET ?;`, limit, offset)
+}
+
+// Returns the user's rank in the given ranking, or 0 if they aren't ranked.
+func (d *DB) RankOf(by string, uid string) (int, error) {
+	q, err := rankedQuery(by)
+	if err != nil {
+		return 0, err
+	}
+	row, err := d.db.Query(q+`
+	SELECT rank FROM ranked WHERE id = ?;`, uid)
+	if err != nil {
+		return 0, err
+	}
+	var rank int
+	for row.Next() {
+		if err := row.Scan(&rank); err != nil {
+			return 0, err
+		}
+	}
+	return rank, nil
+}
+
+// Returns whether the user already has a bet placed in the same second as
+// placed.  Bets are keyed by their user and placed time, so a second one can't
+// be written.
+func (d *DB) BetPlaced(uid string, placed time.Time) (bool, error) {
+	row, err := d.db.Query(`
+	SELECT COUNT(*) FROM bets
+	WHERE uid = ? AND unixepoch(placed) = unixepoch(?);`, uid, placed.Format(time.DateTime))
+	if err != nil {
+		return false, err
+	}
+	var n int
+	for row.Next() {
+		if err := row.Scan(&n); err != nil {
+			return false, err
+		}
+	}
+	return n > 0, nil
+}
+
 // Loads all the open bets placed by the user across all events.
 func (d *DB) LoadUserBets(uid string) (Scanner, error) {
 	return d.db.Query(`
@@ -112,6 +258,236 @@ func (d *DB) LastRun(id string) time.Time {
 	return lastRun
 }
 
+// Returns the number of the last season that was archived, or 0 if no season
+// has ended yet.
+func (d *DB) LastSeason() int {
+	row, err := d.db.Query(`SELECT MAX(season) FROM seasons`)
+	if err != nil {
+		return 0
+	}
+	var season sql.NullInt64
+	for row.Next() {
+		if err := row.Scan(&season); err != nil {
+			return 0
+		}
+	}
+	return int(season.Int64)
+}
+
+// Loads the top 10 of the archived standings for the given season.
+func (d *DB) SeasonStandings(season int) (Scanner, error) {
+	return d.db.Query(`
+	SELECT uid, balance FROM seasons
+	WHERE season = ?
+	ORDER BY rank
+	LIMIT 10;`, season)
+}
+
+// Loads the ids and award times of all the user's achievements, earliest first.
+func (d *DB) LoadAchievements(uid string) (Scanner, error) {
+	return d.db.Query(`
+	SELECT aid, awarded FROM achievements
+	WHERE uid = ?
+	ORDER BY awarded;`, uid)
+}
+
+// Returns the total amount the user has ever donated to other users.
+func (d *DB) TotalDonated(uid string) (int, error) {
+	row, err := d.db.Query(`SELECT COALESCE(SUM(amount), 0) FROM donations WHERE giver = ?`, uid)
+	if err != nil {
+		return 0, err
+	}
+	var total int
+	for row.Next() {
+		if err := row.Scan(&total); err != nil {
+			return 0, err
+		}
+	}
+	return total, nil
+}
+
+// Loads a single row of lifetime stats for the user: the total amount ever
+// wagered, net profit across resolved bets, the largest payout of a single
+// bet, and the number of resolved bets.
+func (d *DB) UserStats(uid string) (Scanner, error) {
+	return d.db.Query(`
+	SELECT
+	  (SELECT COALESCE(SUM(amount), 0) FROM bets WHERE uid = ?),
+	  COALESCE(SUM(payout), 0),
+	  COALESCE(MAX(CASE WHEN outcome = 1 THEN payout END), 0),
+	  COUNT(*)
+	FROM results
+	WHERE uid = ?;`, uid, uid)
+}
+
+// Loads the number of wins and losses the user has on each event.  Refunded
+// bets aren't counted.
+func (d *DB) WinRates(uid string) (Scanner, error) {
+	return d.db.Query(`
+	SELECT eid, SUM(outcome = 1), SUM(outcome = -1)
+	FROM results
+	WHERE uid = ?
+	GROUP BY eid
+	ORDER BY eid;`, uid)
+}
+
+// Loads the direction the user bets most often, and how many times they've bet
+// that direction.  Phase bets are "under", "exact" or "over" and item bets are
+// "for" or "against".
+func (d *DB) FavoriteDirection(uid string) (Scanner, error) {
+	return d.db.Query(`
+	SELECT
+	  CASE
+	    WHEN bet LIKE '0,%' THEN 'under'
+	    WHEN bet LIKE '1,%' THEN 'exact'
+	    WHEN bet LIKE '2,%' THEN 'over'
+	    WHEN bet = 'true' THEN 'for'
+	    ELSE 'against'
+	  END AS direction,
+	  COUNT(*) AS n
+	FROM bets
+	WHERE uid = ?
+	GROUP BY direction
+	ORDER BY n DESC
+	LIMIT 1;`, uid)
+}
+
+// Returns the user's current streak of resolved bets.  Positive for a winning
+// streak and negative for a losing streak.  Refunded bets don't break streaks.
+func (d *DB) Streak(uid string) (int, error) {
+	row, err := d.db.Query(`
+	WITH r AS (
+	  SELECT outcome, row_number() OVER (ORDER BY resolved DESC, placed DESC) AS n
+	  FROM results
+	  WHERE uid = ? AND outcome != 0
+	), latest AS (
+	  SELECT outcome FROM r WHERE n = 1
+	)
+	SELECT COALESCE(
+	  (SELECT outcome FROM latest) * (
+	    COALESCE(
+	      (SELECT MIN(n) FROM r WHERE outcome != (SELECT outcome FROM latest)),
+	      (SELECT COUNT(*) + 1 FROM r)
+	    ) - 1
+	  ), 0);`, uid)
+	if err != nil {
+		return 0, err
+	}
+	var streak int
+	for row.Next() {
+		if err := row.Scan(&streak); err != nil {
+			return 0, err
+		}
+	}
+	return streak, nil
+}
+
+// Returns how many phases of the event have been recorded and the total number
+// of encounters across them.
+func (d *DB) PhaseHistory(eid string) (int, int, error) {
+	row, err := d.db.Query(`SELECT COUNT(*), COALESCE(SUM(encounters), 0) FROM phases WHERE eid = ?`, eid)
+	if err != nil {
+		return 0, 0, err
+	}
+	var phases, encounters int
+	for row.Next() {
+		if err := row.Scan(&phases, &encounters); err != nil {
+			return 0, 0, err
+		}
+	}
+	return phases, encounters, nil
+}
+
+// Returns the amount currently in the jackpot.
+func (d *DB) Jackpot() (int, error) {
+	row, err := d.db.Query(`SELECT COALESCE(SUM(amount), 0) FROM jackpot`)
+	if err != nil {
+		return 0, err
+	}
+	var pool int
+	for row.Next() {
+		if err := row.Scan(&pool); err != nil {
+			return 0, err
+		}
+	}
+	return pool, nil
+}
+
+// Loads the most recent changes to the jackpot, newest first, as rows of
+// timestamp, event id, amount and kind.
+func (d *DB) JackpotHistory(limit int) (Scanner, error) {
+	return d.db.Query(`
+	SELECT ts, eid, amount, kind FROM jackpot
+	ORDER BY unixepoch(ts) DESC, rowid DESC
+	LIMIT ?`, limit)
+}
+
+// Loads the most recent times events resolved, newest first, as rows of event
+// id, when it resolved, the number of bets resolved, how many of them won and
+// the total paid out to the winners.
+func (d *DB) RecentResolutions(limit int) (Scanner, error) {
+	return d.db.Query(`
+	SELECT eid, resolved, COUNT(*), SUM(outcome = 1),
+	  SUM(CASE WHEN outcome = 1 THEN payout ELSE 0 END)
+	FROM results
+	GROUP BY eid, resolved
+	ORDER BY unixepoch(resolved) DESC, eid
+	LIMIT ?`, limit)
+}
+
+// Loads every loan, as rows of uid, amount owed, when it was taken, the number
+// of times interest was charged since, and whether it was defaulted on.
+func (d *DB) LoadLoans() (Scanner, error) {
+	return d.db.Query(`SELECT uid, owed, taken, charges, defaulted FROM loans ORDER BY uid`)
+}
+
+// Loads the balance adjustments made to the user, as rows of time, amount,
+// operator and reason, most recent first.
+func (d *DB) LoadAdjustments(uid string) (Scanner, error) {
+	return d.db.Query(`
+	SELECT ts, amount, operator, reason FROM adjustments
+	WHERE uid = ?
+	ORDER BY unixepoch(ts) DESC;`, uid)
+}
+
+// Loads the ids of the users that are houses.
+func (d *DB) LoadHouses() (Scanner, error) {
+	return d.db.Query(`SELECT id FROM houses`)
+}
+
+// Loads every linked identity, as rows of identity and the uid of the account
+// it's linked to.
+func (d *DB) LoadIdentities() (Scanner, error) {
+	return d.db.Query(`SELECT identity, uid FROM identities ORDER BY identity`)
+}
+
+// Returns the uid of the user the API token with the given hash belongs to, or
+// an empty string if there's no such token.
+func (d *DB) APITokenUser(hash string) (string, error) {
+	var uid string
+	err := d.db.QueryRow(`SELECT uid FROM api_tokens WHERE hash = ?`, hash).Scan(&uid)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return uid, err
+}
+
+// Loads when each user was last given a grant of the kind, as rows of uid and
+// timestamp.
+func (d *DB) LastGrants(kind string) (Scanner, error) {
+	return d.db.Query(`SELECT uid, MAX(ts) FROM grants WHERE kind = ? GROUP BY uid`, kind)
+}
+
+// Loads the user's latest grant of the kind, as a single row of timestamp and
+// streak.
+func (d *DB) LastGrant(uid string, kind string) (Scanner, error) {
+	return d.db.Query(`
+	SELECT ts, streak FROM grants
+	WHERE uid = ? AND kind = ?
+	ORDER BY ts DESC
+	LIMIT 1`, uid, kind)
+}
+
 func (d *DB) OpenTransaction() (Transaction, error) {
 	tx, err := d.db.Begin()
 	if err != nil {
@@ -123,6 +499,9 @@ func (d *DB) OpenTransaction() (Transaction, error) {
 // Again, the interface is for test doubles.
 type Transaction interface {
 	Commit() error
+	// Rollback discards the transaction's writes, for when it can't be
+	// committed.
+	Rollback() error
 	WriteInBets(uid string, inBets int) error
 	WriteBalance(uid string, balance int) error
 	WriteNewEvent(eid string, ts time.Time, details string) error
@@ -131,8 +510,22 @@ type Transaction interface {
 	WriteOpened(eid string, opened time.Time) error
 	WriteClosed(eid string, closed time.Time) error
 	WriteEventDetails(eid string, details string) error
-	RefreshBalance() error
+	ResetBalances(balance int) error
+	ClearLoans() error
+	ArchiveSeason(season int, ended time.Time) error
 	WriteCronRun(id string, ts time.Time) error
+	WriteAchievement(uid string, aid string, ts time.Time) (bool, error)
+	WriteDonation(giver string, receiver string, ts time.Time, amount int) error
+	WriteResult(uid string, eid string, placed time.Time, resolved time.Time, outcome int, payout int) error
+	WritePhase(eid string, ended time.Time, encounters int) error
+	WriteJackpot(eid string, ts time.Time, amount int, kind string) error
+	WriteGrant(uid string, kind string, ts time.Time, amount int, streak int) error
+	WriteLoan(uid string, owed int, taken time.Time, charges int, defaulted bool) error
+	WriteIdentity(identity string, uid string, linked time.Time) error
+	DeleteBet(uid string, placed time.Time) error
+	WriteAPIToken(hash string, uid string, name string, created time.Time) error
+	WriteHouse(uid string) error
+	WriteAdjustment(uid string, ts time.Time, amount int, operator string, reason string) error
 }
 
 type Tx struct {
@@ -143,6 +536,10 @@ func (t *Tx) Commit() error {
 	return t.tx.Commit()
 }
 
+func (t *Tx) Rollback() error {
+	return t.tx.Rollback()
+}
+
 func (t *Tx) WriteInBets(uid string, inBets int) error {
 	_, err := t.tx.Exec("UPDATE users SET inBets = ? WHERE id = ?", inBets, uid)
 	return err
@@ -169,6 +566,31 @@ func (t *Tx) WriteBet(uid string, eid string, ts time.Time, amount int, risk flo
 	return err
 }
 
+// Deletes the user's bet placed at the given time, for bets that are cancelled
+// before they resolve.
+func (t *Tx) DeleteBet(uid string, placed time.Time) error {
+	_, err := t.tx.Exec("DELETE FROM bets WHERE uid = ? AND unixepoch(placed) = unixepoch(?)", uid, placed.Format(time.DateTime))
+	return err
+}
+
+// Records an API token for the user.  Only the hash of the token is stored.
+func (t *Tx) WriteAPIToken(hash string, uid string, name string, created time.Time) error {
+	_, err := t.tx.Exec("INSERT INTO api_tokens VALUES(?, ?, ?, ?)", hash, uid, name, created.Format(time.DateTime))
+	return err
+}
+
+// Records a balance adjustment made by an operator, and why.
+func (t *Tx) WriteAdjustment(uid string, ts time.Time, amount int, operator string, reason string) error {
+	_, err := t.tx.Exec("INSERT INTO adjustments VALUES(?, ?, ?, ?, ?)", uid, ts.Format(time.DateTime), amount, operator, reason)
+	return err
+}
+
+// Marks the user as a house, which isn't ranked or reset with the players.
+func (t *Tx) WriteHouse(uid string) error {
+	_, err := t.tx.Exec("INSERT OR IGNORE INTO houses VALUES(?)", uid)
+	return err
+}
+
 func (t *Tx) WriteOpened(eid string, opened time.Time) error {
 	_, err := t.tx.Exec("UPDATE events SET lastOpen = ? WHERE id = ?", opened.Format(time.DateTime), eid)
 	return err
@@ -184,8 +606,27 @@ func (t *Tx) WriteEventDetails(eid string, details string) error {
 	return err
 }
 
-func (t *Tx) RefreshBalance() error {
-	_, err := t.tx.Exec("UPDATE users SET balance = 100 WHERE balance < 100;")
+// Sets every user's balance to the given balance, plus whatever they have in
+// open bets.
+// Resets the balance of every user except the houses, keeping what's in their
+// bets on top.
+func (t *Tx) ResetBalances(balance int) error {
+	_, err := t.tx.Exec("UPDATE users SET balance = ? + inBets WHERE id NOT IN (SELECT id FROM houses);", balance)
+	return err
+}
+
+// Deletes every loan, so nobody owes anything or is marked as defaulted.
+func (t *Tx) ClearLoans() error {
+	_, err := t.tx.Exec("DELETE FROM loans;")
+	return err
+}
+
+// Copies the current leaderboard into the seasons table as the final standings
+// of the given season.
+func (t *Tx) ArchiveSeason(season int, ended time.Time) error {
+	_, err := t.tx.Exec(`
+	INSERT INTO seasons
+	SELECT ?, ?, id, balance, rank FROM leaderboard;`, season, ended.Format(time.DateTime))
 	return err
 }
 
@@ -193,3 +634,63 @@ func (t *Tx) WriteCronRun(id string, ts time.Time) error {
 	_, err := t.tx.Exec("INSERT OR REPLACE INTO crons VALUES(?, ?)", id, ts.Format(time.DateTime))
 	return err
 }
+
+// Writes the achievement for the user, returning true if it was newly awarded
+// and false if the user already had it.
+func (t *Tx) WriteAchievement(uid string, aid string, ts time.Time) (bool, error) {
+	res, err := t.tx.Exec("INSERT OR IGNORE INTO achievements VALUES(?, ?, ?)", uid, aid, ts.Format(time.DateTime))
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+func (t *Tx) WriteDonation(giver string, receiver string, ts time.Time, amount int) error {
+	_, err := t.tx.Exec("INSERT INTO donations VALUES(?, ?, ?, ?)", giver, receiver, ts.Format(time.DateTime), amount)
+	return err
+}
+
+// Writes the outcome of a resolved bet.  payout is the net change the bet made
+// to the user's balance.
+func (t *Tx) WriteResult(uid string, eid string, placed time.Time, resolved time.Time, outcome int, payout int) error {
+	_, err := t.tx.Exec("INSERT OR REPLACE INTO results VALUES(?, ?, ?, ?, ?, ?)", uid, eid, placed.Format(time.DateTime), resolved.Format(time.DateTime), outcome, payout)
+	return err
+}
+
+// Records a change to the jackpot.  Amount is negative when the jackpot is paid
+// out.
+func (t *Tx) WriteJackpot(eid string, ts time.Time, amount int, kind string) error {
+	_, err := t.tx.Exec("INSERT INTO jackpot VALUES(?, ?, ?, ?)", ts.Format(time.DateTime), eid, amount, kind)
+	return err
+}
+
+// Writes the state of the user's loan.  Users only have one loan at a time, so
+// this replaces any earlier loan.
+func (t *Tx) WriteLoan(uid string, owed int, taken time.Time, charges int, defaulted bool) error {
+	_, err := t.tx.Exec("INSERT OR REPLACE INTO loans VALUES(?, ?, ?, ?, ?)", uid, owed, taken.Format(time.DateTime), charges, defaulted)
+	return err
+}
+
+// Links the identity to the user's account.  An identity is only linked to one
+// account, so this replaces any earlier link.
+func (t *Tx) WriteIdentity(identity string, uid string, linked time.Time) error {
+	_, err := t.tx.Exec("INSERT OR REPLACE INTO identities VALUES(?, ?, ?)", identity, uid, linked.Format(time.DateTime))
+	return err
+}
+
+// Records a grant of cakes given to the user.  Streak is how many days in a row
+// the grant has been claimed, for grants that have streaks.
+func (t *Tx) WriteGrant(uid string, kind string, ts time.Time, amount int, streak int) error {
+	_, err := t.tx.Exec("INSERT INTO grants VALUES(?, ?, ?, ?, ?)", uid, ts.Format(time.DateTime), kind, amount, streak)
+	return err
+}
+
+// Records the length of a completed phase of the event.
+func (t *Tx) WritePhase(eid string, ended time.Time, encounters int) error {
+	_, err := t.tx.Exec("INSERT OR REPLACE INTO phases VALUES(?, ?, ?)", eid, ended.Format(time.DateTime), encounters)
+	return err
+}
@@ -3,6 +3,8 @@ package db
 import (
 	"fmt"
 	"os"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 )
@@ -351,6 +353,678 @@ func TestCrons(t *testing.T) {
 	}
 }
 
+func TestAchievements(t *testing.T) {
+	tx, err := db.OpenTransaction()
+	if err != nil {
+		t.Fatalf("error while opening transaction: %s", err)
+	}
+	ts := time.Date(2025, time.March, 2, 0, 0, 0, 0, time.UTC)
+	awarded, err := tx.WriteAchievement("user3", "exact", ts)
+	if err != nil {
+		t.Errorf("error writing achievement: %s", err)
+	}
+	if awarded {
+		t.Errorf("WriteAchievement() of an existing achievement returned true, want false")
+	}
+	awarded, err = tx.WriteAchievement("user3", "longshot", ts)
+	if err != nil {
+		t.Errorf("error writing achievement: %s", err)
+	}
+	if !awarded {
+		t.Errorf("WriteAchievement() of a new achievement returned false, want true")
+	}
+	if err := tx.Commit(); err != nil {
+		t.Errorf("error while commiting transaction: %s", err)
+	}
+	rows, err := db.LoadAchievements("user3")
+	if err != nil {
+		t.Errorf("unexpected error loading achievements: %s", err)
+	}
+	got := []string{}
+	for rows.Next() {
+		var aid string
+		var awarded string
+		if err := rows.Scan(&aid, &awarded); err != nil {
+			t.Errorf("unexpected error during scan: %s", err)
+		}
+		got = append(got, aid)
+	}
+	if strings.Join(got, ",") != "exact,longshot" {
+		t.Errorf("LoadAchievements(user3) = %v, want [exact longshot]", got)
+	}
+}
+
+func TestTotalDonated(t *testing.T) {
+	for _, tc := range []struct {
+		user string
+		want int
+	}{
+		{user: "user3", want: 300},
+		{user: "user1", want: 0},
+	} {
+		got, err := db.TotalDonated(tc.user)
+		if err != nil {
+			t.Errorf("unexpected error getting total donated: %s", err)
+		}
+		if got != tc.want {
+			t.Errorf("TotalDonated(%s) = %d, want %d", tc.user, got, tc.want)
+		}
+	}
+}
+
+func TestRanking(t *testing.T) {
+	for _, tc := range []struct {
+		by     string
+		offset int
+		limit  int
+		want   []string
+	}{
+		{by: RankBalance, offset: 0, limit: 10, want: []string{"1,user1,1000", "2,user2,500", "3,user3,400"}},
+		{by: RankBalance, offset: 1, limit: 1, want: []string{"2,user2,500"}},
+		{by: RankProfit, offset: 0, limit: 10, want: []string{"1,user2,700", "2,user3,190"}},
+		// user2's big win was before the last season ended.
+		{by: RankSeasonProfit, offset: 0, limit: 10, want: []string{"1,user3,190", "2,user2,-300"}},
+		// user2 only has 2 bets that weren't refunded.
+		{by: RankWinRate, offset: 0, limit: 10, want: []string{"1,user3,0.67"}},
+		{by: RankBiggestWin, offset: 0, limit: 10, want: []string{"1,user2,1000", "2,user3,250"}},
+	} {
+		rows, err := db.Ranking(tc.by, tc.offset, tc.limit)
+		if err != nil {
+			t.Errorf("unexpected error loading %s ranking: %s", tc.by, err)
+			continue
+		}
+		got := []string{}
+		for rows.Next() {
+			var rank int
+			var id string
+			var value float64
+			if err := rows.Scan(&rank, &id, &value); err != nil {
+				t.Errorf("unexpected error during scan: %s", err)
+			}
+			got = append(got, fmt.Sprintf("%d,%s,%s", rank, id, strings.TrimSuffix(fmt.Sprintf("%.2f", value), ".00")))
+		}
+		if strings.Join(got, " ") != strings.Join(tc.want, " ") {
+			t.Errorf("Ranking(%s, %d, %d) = %v, want %v", tc.by, tc.offset, tc.limit, got, tc.want)
+		}
+	}
+}
+
+func TestRankingUnknown(t *testing.T) {
+	if _, err := db.Ranking("nope", 0, 10); err == nil {
+		t.Errorf("Ranking(nope) succeeded, want error")
+	}
+}
+
+func TestRankOf(t *testing.T) {
+	for _, tc := range []struct {
+		by   string
+		user string
+		want int
+	}{
+		{by: RankBalance, user: "user3", want: 3},
+		{by: RankProfit, user: "user2", want: 1},
+		{by: RankSeasonProfit, user: "user2", want: 2},
+		{by: RankWinRate, user: "user3", want: 1},
+		{by: RankWinRate, user: "user2", want: 0},
+		{by: RankBiggestWin, user: "user1", want: 0},
+	} {
+		got, err := db.RankOf(tc.by, tc.user)
+		if err != nil {
+			t.Errorf("unexpected error getting %s rank: %s", tc.by, err)
+		}
+		if got != tc.want {
+			t.Errorf("RankOf(%s, %s) = %d, want %d", tc.by, tc.user, got, tc.want)
+		}
+	}
+}
+
+func TestUserStats(t *testing.T) {
+	for _, tc := range []struct {
+		user        string
+		wantWagered int
+		wantProfit  int
+		wantLargest int
+		wantCount   int
+	}{
+		{user: "user3", wantWagered: 800, wantProfit: 190, wantLargest: 250, wantCount: 4},
+		{user: "user1", wantWagered: 250, wantProfit: 0, wantLargest: 0, wantCount: 0},
+	} {
+		rows, err := db.UserStats(tc.user)
+		if err != nil {
+			t.Errorf("unexpected error loading user stats: %s", err)
+		}
+		for rows.Next() {
+			var wagered, profit, largest, count int
+			if err := rows.Scan(&wagered, &profit, &largest, &count); err != nil {
+				t.Errorf("unexpected error during scan: %s", err)
+			}
+			if wagered != tc.wantWagered || profit != tc.wantProfit || largest != tc.wantLargest || count != tc.wantCount {
+				t.Errorf("UserStats(%s) = %d,%d,%d,%d, want %d,%d,%d,%d", tc.user, wagered, profit, largest, count, tc.wantWagered, tc.wantProfit, tc.wantLargest, tc.wantCount)
+			}
+		}
+	}
+}
+
+func TestWinRates(t *testing.T) {
+	rows, err := db.WinRates("user3")
+	if err != nil {
+		t.Errorf("unexpected error loading win rates: %s", err)
+	}
+	want := []string{"item,1,0", "shiny,1,1"}
+	got := []string{}
+	for rows.Next() {
+		var eid string
+		var wins, losses int
+		if err := rows.Scan(&eid, &wins, &losses); err != nil {
+			t.Errorf("unexpected error during scan: %s", err)
+		}
+		got = append(got, fmt.Sprintf("%s,%d,%d", eid, wins, losses))
+	}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("WinRates(user3) = %v, want %v", got, want)
+	}
+}
+
+func TestFavoriteDirection(t *testing.T) {
+	rows, err := db.FavoriteDirection("user1")
+	if err != nil {
+		t.Errorf("unexpected error loading favorite direction: %s", err)
+	}
+	var found bool
+	for rows.Next() {
+		found = true
+		var direction string
+		var count int
+		if err := rows.Scan(&direction, &count); err != nil {
+			t.Errorf("unexpected error during scan: %s", err)
+		}
+		if direction != "under" || count != 2 {
+			t.Errorf("FavoriteDirection(user1) = %s,%d, want under,2", direction, count)
+		}
+	}
+	if !found {
+		t.Errorf("FavoriteDirection(user1) returned no rows")
+	}
+}
+
+func TestStreak(t *testing.T) {
+	for _, tc := range []struct {
+		user string
+		want int
+	}{
+		{user: "user3", want: 2},
+		{user: "user2", want: -1},
+		{user: "user1", want: 0},
+	} {
+		got, err := db.Streak(tc.user)
+		if err != nil {
+			t.Errorf("unexpected error getting streak: %s", err)
+		}
+		if got != tc.want {
+			t.Errorf("Streak(%s) = %d, want %d", tc.user, got, tc.want)
+		}
+	}
+}
+
+func TestWriteResult(t *testing.T) {
+	tx, err := db.OpenTransaction()
+	if err != nil {
+		t.Fatalf("error while opening transaction: %s", err)
+	}
+	placed := time.Date(2025, time.February, 5, 0, 0, 0, 0, time.UTC)
+	resolved := time.Date(2025, time.February, 6, 0, 0, 0, 0, time.UTC)
+	if err := tx.WriteResult("user2", "shiny", placed, resolved, OutcomeWin, 500); err != nil {
+		t.Errorf("error writing result: %s", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Errorf("error while commiting transaction: %s", err)
+	}
+	got, err := db.Streak("user2")
+	if err != nil {
+		t.Errorf("unexpected error getting streak: %s", err)
+	}
+	if got != 1 {
+		t.Errorf("Streak(user2) after a win = %d, want 1", got)
+	}
+}
+
+func TestPhaseHistory(t *testing.T) {
+	phases, encounters, err := db.PhaseHistory("shiny")
+	if err != nil {
+		t.Errorf("unexpected error loading phase history: %s", err)
+	}
+	if phases != 2 || encounters != 16000 {
+		t.Errorf("PhaseHistory(shiny) = %d,%d, want 2,16000", phases, encounters)
+	}
+	tx, err := db.OpenTransaction()
+	if err != nil {
+		t.Fatalf("error while opening transaction: %s", err)
+	}
+	if err := tx.WritePhase("anti", time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC), 42); err != nil {
+		t.Errorf("error writing phase: %s", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Errorf("error while commiting transaction: %s", err)
+	}
+	phases, encounters, err = db.PhaseHistory("anti")
+	if err != nil {
+		t.Errorf("unexpected error loading phase history: %s", err)
+	}
+	if phases != 1 || encounters != 42 {
+		t.Errorf("PhaseHistory(anti) = %d,%d, want 1,42", phases, encounters)
+	}
+}
+
+func TestJackpot(t *testing.T) {
+	pool, err := db.Jackpot()
+	if err != nil {
+		t.Errorf("unexpected error loading jackpot: %s", err)
+	}
+	if pool != 150 {
+		t.Errorf("Jackpot() = %d, want 150", pool)
+	}
+	tx, err := db.OpenTransaction()
+	if err != nil {
+		t.Fatalf("error while opening transaction: %s", err)
+	}
+	if err := tx.WriteJackpot("shiny", time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC), -150, JackpotWin); err != nil {
+		t.Errorf("error writing jackpot: %s", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Errorf("error while commiting transaction: %s", err)
+	}
+	pool, err = db.Jackpot()
+	if err != nil {
+		t.Errorf("unexpected error loading jackpot: %s", err)
+	}
+	if pool != 0 {
+		t.Errorf("Jackpot() after paying out = %d, want 0", pool)
+	}
+	rows, err := db.JackpotHistory(3)
+	if err != nil {
+		t.Errorf("unexpected error loading jackpot history: %s", err)
+	}
+	want := []string{"-150,win", "50,rake", "0,rollover"}
+	got := []string{}
+	for rows.Next() {
+		var ts, eid, kind string
+		var amount int
+		if err := rows.Scan(&ts, &eid, &amount, &kind); err != nil {
+			t.Errorf("unexpected error during scan: %s", err)
+		}
+		got = append(got, fmt.Sprintf("%d,%s", amount, kind))
+	}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("JackpotHistory(3) = %v, want %v", got, want)
+	}
+}
+
+func TestGrants(t *testing.T) {
+	rows, err := db.LastGrants(GrantBailout)
+	if err != nil {
+		t.Errorf("unexpected error loading last grants: %s", err)
+	}
+	got := []string{}
+	for rows.Next() {
+		var uid, ts string
+		if err := rows.Scan(&uid, &ts); err != nil {
+			t.Errorf("unexpected error during scan: %s", err)
+		}
+		got = append(got, uid+","+ts)
+	}
+	if want := "user2,2025-02-02 00:00:00"; strings.Join(got, " ") != want {
+		t.Errorf("LastGrants(bailout) = %v, want %s", got, want)
+	}
+
+	tx, err := db.OpenTransaction()
+	if err != nil {
+		t.Fatalf("error while opening transaction: %s", err)
+	}
+	if err := tx.WriteGrant("user1", GrantDaily, time.Date(2025, time.February, 3, 0, 0, 0, 0, time.UTC), 120, 3); err != nil {
+		t.Errorf("error writing grant: %s", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Errorf("error while commiting transaction: %s", err)
+	}
+	rows, err = db.LastGrant("user1", GrantDaily)
+	if err != nil {
+		t.Errorf("unexpected error loading last grant: %s", err)
+	}
+	var found bool
+	for rows.Next() {
+		found = true
+		var ts string
+		var streak int
+		if err := rows.Scan(&ts, &streak); err != nil {
+			t.Errorf("unexpected error during scan: %s", err)
+		}
+		if ts != "2025-02-03 00:00:00" || streak != 3 {
+			t.Errorf("LastGrant(user1, daily) = %s,%d, want 2025-02-03 00:00:00,3", ts, streak)
+		}
+	}
+	if !found {
+		t.Errorf("LastGrant(user1, daily) returned no rows")
+	}
+}
+
+func TestLoans(t *testing.T) {
+	tx, err := db.OpenTransaction()
+	if err != nil {
+		t.Fatalf("error while opening transaction: %s", err)
+	}
+	if err := tx.WriteLoan("user3", 150, time.Date(2025, time.February, 1, 0, 0, 0, 0, time.UTC), 3, false); err != nil {
+		t.Errorf("error writing loan: %s", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Errorf("error while commiting transaction: %s", err)
+	}
+	rows, err := db.LoadLoans()
+	if err != nil {
+		t.Errorf("unexpected error loading loans: %s", err)
+	}
+	got := []string{}
+	for rows.Next() {
+		var uid, taken string
+		var owed, charges int
+		var defaulted bool
+		if err := rows.Scan(&uid, &owed, &taken, &charges, &defaulted); err != nil {
+			t.Errorf("unexpected error during scan: %s", err)
+		}
+		got = append(got, fmt.Sprintf("%s,%d,%s,%d,%t", uid, owed, taken, charges, defaulted))
+	}
+	if want := "user2,50,2025-01-01 00:00:00,10,true user3,150,2025-02-01 00:00:00,3,false"; strings.Join(got, " ") != want {
+		t.Errorf("LoadLoans() = %v, want %s", got, want)
+	}
+}
+
+func TestIdentities(t *testing.T) {
+	tx, err := db.OpenTransaction()
+	if err != nil {
+		t.Fatalf("error while opening transaction: %s", err)
+	}
+	// Relinking an identity replaces its earlier link.
+	if err := tx.WriteIdentity("twitch:7", "user3", time.Date(2025, time.February, 3, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Errorf("error writing identity: %s", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Errorf("error while commiting transaction: %s", err)
+	}
+	rows, err := db.LoadIdentities()
+	if err != nil {
+		t.Errorf("unexpected error loading identities: %s", err)
+	}
+	got := []string{}
+	for rows.Next() {
+		var identity, uid string
+		if err := rows.Scan(&identity, &uid); err != nil {
+			t.Errorf("unexpected error during scan: %s", err)
+		}
+		got = append(got, fmt.Sprintf("%s,%s", identity, uid))
+	}
+	if want := "twitch:42,user1 twitch:7,user3"; strings.Join(got, " ") != want {
+		t.Errorf("LoadIdentities() = %v, want %s", got, want)
+	}
+}
+
+func TestAPITokens(t *testing.T) {
+	tx, err := db.OpenTransaction()
+	if err != nil {
+		t.Fatalf("error while opening transaction: %s", err)
+	}
+	if err := tx.WriteAPIToken("hash2", "user2", "frontend", time.Date(2025, time.February, 3, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Errorf("error writing api token: %s", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Errorf("error while commiting transaction: %s", err)
+	}
+	for hash, want := range map[string]string{"hash1": "user1", "hash2": "user2", "unknown": ""} {
+		got, err := db.APITokenUser(hash)
+		if err != nil {
+			t.Errorf("unexpected error looking up api token: %s", err)
+		}
+		if got != want {
+			t.Errorf("APITokenUser(%s) = %q, want %q", hash, got, want)
+		}
+	}
+}
+
+func TestDeleteBet(t *testing.T) {
+	placed := time.Date(2025, time.March, 1, 3, 0, 0, 0, time.UTC)
+	tx, err := db.OpenTransaction()
+	if err != nil {
+		t.Fatalf("error while opening transaction: %s", err)
+	}
+	if err := tx.WriteBet("user1", "shiny", placed, 50, 0.5, "true,20"); err != nil {
+		t.Errorf("error writing bet: %s", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Errorf("error while commiting transaction: %s", err)
+	}
+	tx, err = db.OpenTransaction()
+	if err != nil {
+		t.Fatalf("error while opening transaction: %s", err)
+	}
+	if err := tx.DeleteBet("user1", placed); err != nil {
+		t.Errorf("error deleting bet: %s", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Errorf("error while commiting transaction: %s", err)
+	}
+	rows, err := db.LoadBets("shiny")
+	if err != nil {
+		t.Errorf("unexpected error loading bets: %s", err)
+	}
+	var count int
+	for rows.Next() {
+		var uid, eid, placed, blob string
+		var amount int
+		var risk float64
+		if err := rows.Scan(&uid, &eid, &placed, &amount, &risk, &blob); err != nil {
+			t.Errorf("unexpected error during scan: %s", err)
+		}
+		if uid == "user1" {
+			t.Errorf("bet of user1 placed %s wasn't deleted", placed)
+		}
+		count++
+	}
+	if count != 2 {
+		t.Errorf("LoadBets() after delete returned %d bets, want 2", count)
+	}
+}
+
+func TestRecentResolutions(t *testing.T) {
+	rows, err := db.RecentResolutions(3)
+	if err != nil {
+		t.Fatalf("unexpected error loading resolutions: %s", err)
+	}
+	type resolution struct {
+		eid      string
+		resolved string
+		bets     int
+		winners  int
+		paid     int
+	}
+	var got []resolution
+	for rows.Next() {
+		var r resolution
+		if err := rows.Scan(&r.eid, &r.resolved, &r.bets, &r.winners, &r.paid); err != nil {
+			t.Errorf("unexpected error during scan: %s", err)
+		}
+		got = append(got, r)
+	}
+	want := []resolution{
+		{"shiny", "2025-02-06 00:00:00", 1, 1, 500},
+		{"item", "2025-02-04 00:00:00", 2, 1, 40},
+		{"shiny", "2025-02-02 00:00:00", 3, 1, 250},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("RecentResolutions(3) = %v, want %v", got, want)
+	}
+}
+
+func TestHouses(t *testing.T) {
+	tx, err := db.OpenTransaction()
+	if err != nil {
+		t.Fatalf("error while opening transaction: %s", err)
+	}
+	// Writing a house twice keeps a single row.
+	if err := tx.WriteHouse("bank"); err != nil {
+		t.Errorf("error writing house: %s", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Errorf("error while commiting transaction: %s", err)
+	}
+	rows, err := db.LoadHouses()
+	if err != nil {
+		t.Errorf("unexpected error loading houses: %s", err)
+	}
+	got := []string{}
+	for rows.Next() {
+		var uid string
+		if err := rows.Scan(&uid); err != nil {
+			t.Errorf("unexpected error during scan: %s", err)
+		}
+		got = append(got, uid)
+	}
+	if !slices.Equal(got, []string{"bank"}) {
+		t.Errorf("LoadHouses() = %v, want [bank]", got)
+	}
+	// The bank has the most cakes, but houses aren't ranked.
+	if rank, err := db.RankOf(RankBalance, "bank"); err != nil || rank != 0 {
+		t.Errorf("RankOf(balance, bank) = %d, %v, want 0", rank, err)
+	}
+}
+
+func TestAdjustments(t *testing.T) {
+	tx, err := db.OpenTransaction()
+	if err != nil {
+		t.Fatalf("error while opening transaction: %s", err)
+	}
+	if err := tx.WriteAdjustment("user1", time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC), 500, "console", "won the raffle"); err != nil {
+		t.Errorf("error writing adjustment: %s", err)
+	}
+	if err := tx.WriteAdjustment("user1", time.Date(2025, time.March, 2, 0, 0, 0, 0, time.UTC), -200, "socket", "refund mistake"); err != nil {
+		t.Errorf("error writing adjustment: %s", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Errorf("error while commiting transaction: %s", err)
+	}
+	rows, err := db.LoadAdjustments("user1")
+	if err != nil {
+		t.Errorf("unexpected error loading adjustments: %s", err)
+	}
+	got := []string{}
+	for rows.Next() {
+		var ts, operator, reason string
+		var amount int
+		if err := rows.Scan(&ts, &amount, &operator, &reason); err != nil {
+			t.Errorf("unexpected error during scan: %s", err)
+		}
+		got = append(got, fmt.Sprintf("%s,%d,%s,%s", ts, amount, operator, reason))
+	}
+	want := []string{
+		"2025-03-02 00:00:00,-200,socket,refund mistake",
+		"2025-03-01 00:00:00,500,console,won the raffle",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("LoadAdjustments(user1) = %v, want %v", got, want)
+	}
+}
+
+func TestSeasonStandings(t *testing.T) {
+	if got := db.LastSeason(); got != 1 {
+		t.Errorf("LastSeason() = %d, want 1", got)
+	}
+	rows, err := db.SeasonStandings(1)
+	if err != nil {
+		t.Errorf("unexpected error loading season standings: %s", err)
+	}
+	want := []string{"user2,3000", "user1,2000"}
+	got := []string{}
+	for rows.Next() {
+		var id string
+		var balance int
+		if err := rows.Scan(&id, &balance); err != nil {
+			t.Errorf("unexpected error during scan: %s", err)
+		}
+		got = append(got, fmt.Sprintf("%s,%d", id, balance))
+	}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("SeasonStandings(1) = %v, want %v", got, want)
+	}
+}
+
+// This resets every balance, so it's kept after tests that read balances.
+func TestEndSeason(t *testing.T) {
+	tx, err := db.OpenTransaction()
+	if err != nil {
+		t.Fatalf("error while opening transaction: %s", err)
+	}
+	if err := tx.ArchiveSeason(2, time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Errorf("error archiving season: %s", err)
+	}
+	if err := tx.ResetBalances(1000); err != nil {
+		t.Errorf("error resetting balances: %s", err)
+	}
+	if err := tx.ClearLoans(); err != nil {
+		t.Errorf("error clearing loans: %s", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Errorf("error while commiting transaction: %s", err)
+	}
+	if got := db.LastSeason(); got != 2 {
+		t.Errorf("LastSeason() = %d after archiving, want 2", got)
+	}
+	rows, err := db.SeasonStandings(2)
+	if err != nil {
+		t.Errorf("unexpected error loading season standings: %s", err)
+	}
+	var archived int
+	for rows.Next() {
+		var id string
+		var balance int
+		if err := rows.Scan(&id, &balance); err != nil {
+			t.Errorf("unexpected error during scan: %s", err)
+		}
+		archived++
+	}
+	if archived != 3 {
+		t.Errorf("archived %d users in season 2, want 3", archived)
+	}
+	users, err := db.db.Query("SELECT id, balance, inBets FROM users")
+	if err != nil {
+		t.Errorf("error while reading data back: %s", err)
+	}
+	defer users.Close()
+	for users.Next() {
+		var id string
+		var balance int
+		var inBets int
+		if err := users.Scan(&id, &balance, &inBets); err != nil {
+			t.Errorf("could not scan user: %s", err)
+		}
+		// Houses keep their balance.
+		want := 1000 + inBets
+		if id == "bank" {
+			want = 5000
+		}
+		if balance != want {
+			t.Errorf("%s has balance %d after reset, want %d", id, balance, want)
+		}
+	}
+	loans, err := db.LoadLoans()
+	if err != nil {
+		t.Errorf("unexpected error loading loans: %s", err)
+	}
+	var remaining int
+	for loans.Next() {
+		remaining++
+	}
+	if remaining != 0 {
+		t.Errorf("LoadLoans() returned %d loans after the season ended, want 0", remaining)
+	}
+}
+
 func TestMain(m *testing.M) {
 	d, err := setupDB()
 	db = d
@@ -1,6 +1,8 @@
 package db
 
 import (
+	"fmt"
+	"slices"
 	"time"
 )
 
@@ -11,6 +13,41 @@ func (s *EmptyScanner) Next() bool          { return false }
 func (s *EmptyScanner) NextResultSet() bool { return true }
 func (s *EmptyScanner) Scan(...any) error   { return nil }
 
+// RowScanner scans through fixed rows of strings, ints and floats.
+type RowScanner struct {
+	rows  [][]any
+	index int
+}
+
+func (s *RowScanner) Next() bool {
+	s.index++
+	return s.index <= len(s.rows)
+}
+
+func (s *RowScanner) NextResultSet() bool { return true }
+
+func (s *RowScanner) Scan(v ...any) error {
+	row := s.rows[s.index-1]
+	for i, dest := range v {
+		switch d := dest.(type) {
+		case *string:
+			*d = row[i].(string)
+		case *int:
+			*d = row[i].(int)
+		case *float64:
+			*d = row[i].(float64)
+		default:
+			return fmt.Errorf("unsupported scan type %T", dest)
+		}
+	}
+	return nil
+}
+
+type testGrant struct {
+	ts     time.Time
+	streak int
+}
+
 type testEvent struct {
 	opened  string
 	closed  string
@@ -84,18 +121,66 @@ func (s *BetScanner) Scan(v ...any) error {
 // FakeDB implements the Database interface, but does not make any writes to an
 // actual database.
 type FakeDB struct {
-	bets   []testBet
-	events map[string]testEvent
-	crons  map[string]time.Time
+	bets    []testBet
+	events  map[string]testEvent
+	crons   map[string]time.Time
+	seasons int
+	// achievements is a map from user id to the set of their achievements.
+	achievements map[string]map[string]bool
+	donated      map[string]int
+	// phases is a map from event id to the lengths of its completed phases.
+	phases  map[string][]int
+	jackpot int
+	// grants is a map from grant kind to user id to their latest grant.
+	grants map[string]map[string]testGrant
+	// loans is a map from user id to their owed amount.
+	loans map[string]int
+	// identities is a map from identity to the user id it's linked to.
+	identities map[string]string
+	// apiTokens is a map from token hash to the user id it belongs to.
+	apiTokens map[string]string
+	// results is a map from user id to the number of their resolved bets.
+	results map[string]int
+	// adjustments is a map from user id to their balance adjustments, as rows
+	// of time, amount, operator and reason.
+	adjustments map[string][][]any
+	// houses is the set of user ids that are houses.
+	houses map[string]bool
+	// users is a map from user id to their balance and amount in bets.  It's
+	// nil unless the fake was made with FakeWithUsers.
+	users map[string]*testUser
+}
+
+type testUser struct {
+	balance int
+	inBets  int
 }
 
 func Fake() Database {
 	return &FakeDB{
-		events: make(map[string]testEvent),
-		crons:  make(map[string]time.Time),
+		events:       make(map[string]testEvent),
+		crons:        make(map[string]time.Time),
+		achievements: make(map[string]map[string]bool),
+		donated:      make(map[string]int),
+		phases:       make(map[string][]int),
+		grants:       make(map[string]map[string]testGrant),
+		loans:        make(map[string]int),
+		identities:   make(map[string]string),
+		apiTokens:    make(map[string]string),
+		houses:       make(map[string]bool),
+		results:      make(map[string]int),
+		adjustments:  make(map[string][][]any),
 	}
 }
 
+// FakeWithUsers is a fake database that also stores users, so they're loaded
+// back by LoadUsers.  Fake doesn't, so every test's core starts without users.
+func FakeWithUsers() Database {
+	f := Fake().(*FakeDB)
+	f.users = make(map[string]*testUser)
+	return f
+}
+
 func (f *FakeDB) LoadEvent(eid string) (Scanner, error) {
 	e, ok := f.events[eid]
 	if !ok {
@@ -105,8 +190,14 @@ func (f *FakeDB) LoadEvent(eid string) (Scanner, error) {
 }
 
 func (f *FakeDB) LoadUsers() (Scanner, error) {
-	// TODO: actually should make a user scanner for this one.
-	return &EmptyScanner{}, nil
+	if f.users == nil {
+		return &EmptyScanner{}, nil
+	}
+	rows := make([][]any, 0)
+	for uid, u := range f.users {
+		rows = append(rows, []any{uid, u.balance, u.inBets})
+	}
+	return &RowScanner{rows: rows}, nil
 }
 
 func (f *FakeDB) LoadUser(uid string) (Scanner, error) {
@@ -125,10 +216,33 @@ func (f *FakeDB) Leaderboard() (Scanner, error) {
 	return &EmptyScanner{}, nil
 }
 
-func (f *FakeDB) LoadUserBets(uid string) (Scanner, error) {
+func (f *FakeDB) Ranking(by string, offset, limit int) (Scanner, error) {
 	return &EmptyScanner{}, nil
 }
 
+func (f *FakeDB) RankOf(by string, uid string) (int, error) {
+	return 0, nil
+}
+
+func (f *FakeDB) BetPlaced(uid string, placed time.Time) (bool, error) {
+	for _, b := range f.bets {
+		if b.uid == uid && b.placed == placed.Format(time.DateTime) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f *FakeDB) LoadUserBets(uid string) (Scanner, error) {
+	rows := make([][]any, 0)
+	for _, b := range f.bets {
+		if b.uid == uid {
+			rows = append(rows, []any{b.eid, b.amount, b.risk, b.bet})
+		}
+	}
+	return &RowScanner{rows: rows}, nil
+}
+
 func (f *FakeDB) Rank(uid string) (Scanner, error) {
 	return &EmptyScanner{}, nil
 }
@@ -141,6 +255,110 @@ func (f *FakeDB) LastRun(id string) time.Time {
 	return run
 }
 
+func (f *FakeDB) LastSeason() int {
+	return f.seasons
+}
+
+func (f *FakeDB) SeasonStandings(season int) (Scanner, error) {
+	return &EmptyScanner{}, nil
+}
+
+func (f *FakeDB) LoadAchievements(uid string) (Scanner, error) {
+	return &EmptyScanner{}, nil
+}
+
+func (f *FakeDB) TotalDonated(uid string) (int, error) {
+	return f.donated[uid], nil
+}
+
+func (f *FakeDB) PhaseHistory(eid string) (int, int, error) {
+	var encounters int
+	for _, e := range f.phases[eid] {
+		encounters += e
+	}
+	return len(f.phases[eid]), encounters, nil
+}
+
+func (f *FakeDB) Jackpot() (int, error) {
+	return f.jackpot, nil
+}
+
+func (f *FakeDB) JackpotHistory(limit int) (Scanner, error) {
+	return &EmptyScanner{}, nil
+}
+
+func (f *FakeDB) RecentResolutions(limit int) (Scanner, error) {
+	return &EmptyScanner{}, nil
+}
+
+func (f *FakeDB) LoadLoans() (Scanner, error) {
+	return &EmptyScanner{}, nil
+}
+
+func (f *FakeDB) LoadIdentities() (Scanner, error) {
+	rows := make([][]any, 0)
+	for identity, uid := range f.identities {
+		rows = append(rows, []any{identity, uid})
+	}
+	return &RowScanner{rows: rows}, nil
+}
+
+func (f *FakeDB) LoadHouses() (Scanner, error) {
+	rows := make([][]any, 0)
+	for uid := range f.houses {
+		rows = append(rows, []any{uid})
+	}
+	return &RowScanner{rows: rows}, nil
+}
+
+func (f *FakeDB) LoadAdjustments(uid string) (Scanner, error) {
+	rows := slices.Clone(f.adjustments[uid])
+	slices.Reverse(rows)
+	return &RowScanner{rows: rows}, nil
+}
+
+func (f *FakeDB) APITokenUser(hash string) (string, error) {
+	return f.apiTokens[hash], nil
+}
+
+func (f *FakeDB) LastGrants(kind string) (Scanner, error) {
+	rows := make([][]any, 0)
+	for uid, g := range f.grants[kind] {
+		rows = append(rows, []any{uid, g.ts.Format(time.DateTime)})
+	}
+	return &RowScanner{rows: rows}, nil
+}
+
+func (f *FakeDB) LastGrant(uid string, kind string) (Scanner, error) {
+	g, ok := f.grants[kind][uid]
+	if !ok {
+		return &EmptyScanner{}, nil
+	}
+	return &RowScanner{rows: [][]any{{g.ts.Format(time.DateTime), g.streak}}}, nil
+}
+
+// UserStats only counts the user's resolved bets, and has no row for users
+// without any.
+func (f *FakeDB) UserStats(uid string) (Scanner, error) {
+	n, ok := f.results[uid]
+	if !ok {
+		return &EmptyScanner{}, nil
+	}
+	return &RowScanner{rows: [][]any{{0, 0, 0, n}}}, nil
+}
+
+func (f *FakeDB) WinRates(uid string) (Scanner, error) {
+	return &EmptyScanner{}, nil
+}
+
+func (f *FakeDB) FavoriteDirection(uid string) (Scanner, error) {
+	return &EmptyScanner{}, nil
+}
+
+func (f *FakeDB) Streak(uid string) (int, error) {
+	return 0, nil
+}
+
 func (f *FakeDB) OpenTransaction() (Transaction, error) {
 	return &FakeTx{d: f}, nil
 }
@@ -156,11 +374,22 @@ func (f *FakeTx) Commit() error {
 	return nil
 }
 
+// Rollback can't undo anything, since the fake writes as it goes.
+func (f *FakeTx) Rollback() error {
+	return nil
+}
+
 func (f *FakeTx) WriteInBets(uid string, inBets int) error {
+	if u, ok := f.d.users[uid]; ok {
+		u.inBets = inBets
+	}
 	return nil
 }
 
 func (f *FakeTx) WriteBalance(uid string, balance int) error {
+	if u, ok := f.d.users[uid]; ok {
+		u.balance = balance
+	}
 	return nil
 }
 
@@ -169,6 +398,9 @@ func (f *FakeTx) WriteNewEvent(eid string, ts time.Time, details string) error {
 }
 
 func (f *FakeTx) WriteNewUser(uid string, balance int, inBets int) error {
+	if f.d.users != nil {
+		f.d.users[uid] = &testUser{balance: balance, inBets: inBets}
+	}
 	return nil
 }
 
@@ -214,7 +446,23 @@ func (f *FakeTx) WriteEventDetails(eid string, details string) error {
 	return nil
 }
 
-func (f *FakeTx) RefreshBalance() error {
+func (f *FakeTx) ResetBalances(balance int) error {
+	for uid, u := range f.d.users {
+		if f.d.houses[uid] {
+			continue
+		}
+		u.balance = balance + u.inBets
+	}
+	return nil
+}
+
+func (f *FakeTx) ClearLoans() error {
+	clear(f.d.loans)
+	return nil
+}
+
+func (f *FakeTx) ArchiveSeason(season int, ended time.Time) error {
+	f.d.seasons = season
 	return nil
 }
 
@@ -222,3 +470,78 @@ func (f *FakeTx) WriteCronRun(id string, ts time.Time) error {
 	f.d.crons[id] = ts
 	return nil
 }
+
+func (f *FakeTx) WriteAchievement(uid string, aid string, ts time.Time) (bool, error) {
+	if f.d.achievements[uid] == nil {
+		f.d.achievements[uid] = make(map[string]bool)
+	}
+	if f.d.achievements[uid][aid] {
+		return false, nil
+	}
+	f.d.achievements[uid][aid] = true
+	return true, nil
+}
+
+func (f *FakeTx) WriteDonation(giver string, receiver string, ts time.Time, amount int) error {
+	f.d.donated[giver] += amount
+	return nil
+}
+
+func (f *FakeTx) WriteResult(uid string, eid string, placed time.Time, resolved time.Time, outcome int, payout int) error {
+	f.d.results[uid]++
+	return nil
+}
+
+func (f *FakeTx) WriteJackpot(eid string, ts time.Time, amount int, kind string) error {
+	f.d.jackpot += amount
+	return nil
+}
+
+func (f *FakeTx) WriteLoan(uid string, owed int, taken time.Time, charges int, defaulted bool) error {
+	f.d.loans[uid] = owed
+	return nil
+}
+
+func (f *FakeTx) WriteIdentity(identity string, uid string, linked time.Time) error {
+	f.d.identities[identity] = uid
+	return nil
+}
+
+func (f *FakeTx) DeleteBet(uid string, placed time.Time) error {
+	bets := make([]testBet, 0, len(f.d.bets))
+	for _, b := range f.d.bets {
+		if b.uid != uid || b.placed != placed.Format(time.DateTime) {
+			bets = append(bets, b)
+		}
+	}
+	f.d.bets = bets
+	return nil
+}
+
+func (f *FakeTx) WriteAPIToken(hash string, uid string, name string, created time.Time) error {
+	f.d.apiTokens[hash] = uid
+	return nil
+}
+
+func (f *FakeTx) WriteAdjustment(uid string, ts time.Time, amount int, operator string, reason string) error {
+	f.d.adjustments[uid] = append(f.d.adjustments[uid], []any{ts.Format(time.DateTime), amount, operator, reason})
+	return nil
+}
+
+func (f *FakeTx) WriteHouse(uid string) error {
+	f.d.houses[uid] = true
+	return nil
+}
+
+func (f *FakeTx) WriteGrant(uid string, kind string, ts time.Time, amount int, streak int) error {
+	if f.d.grants[kind] == nil {
+		f.d.grants[kind] = make(map[string]testGrant)
+	}
+	f.d.grants[kind][uid] = testGrant{ts: ts, streak: streak}
+	return nil
+}
+
+func (f *FakeTx) WritePhase(eid string, ended time.Time, encounters int) error {
+	f.d.phases[eid] = append(f.d.phases[eid], encounters)
+	return nil
+}
@@ -1,6 +1,18 @@
 DROP TABLE IF EXISTS bets;
 DROP TABLE IF EXISTS users;
 DROP TABLE IF EXISTS events;
+DROP TABLE IF EXISTS seasons;
+DROP TABLE IF EXISTS achievements;
+DROP TABLE IF EXISTS donations;
+DROP TABLE IF EXISTS results;
+DROP TABLE IF EXISTS phases;
+DROP TABLE IF EXISTS jackpot;
+DROP TABLE IF EXISTS grants;
+DROP TABLE IF EXISTS loans;
+DROP TABLE IF EXISTS identities;
+DROP TABLE IF EXISTS api_tokens;
+DROP TABLE IF EXISTS houses;
+DROP TABLE IF EXISTS adjustments;
 DROP VIEW IF EXISTS leaderboard;
 
 CREATE TABLE events(
@@ -26,16 +38,105 @@ CREATE TABLE bets(
 	PRIMARY KEY (uid, placed)
 );
 
+CREATE TABLE phases(
+	eid TEXT REFERENCES events(id),
+	ended TEXT,
+	encounters INT,
+	PRIMARY KEY (eid, ended)
+);
+
+CREATE TABLE jackpot(
+	ts TEXT,
+	eid TEXT REFERENCES events(id),
+	amount INT,
+	kind TEXT
+);
+
+CREATE TABLE grants(
+	uid TEXT REFERENCES users(id),
+	ts TEXT,
+	kind TEXT,
+	amount INT,
+	streak INT
+);
+
+CREATE TABLE loans(
+	uid TEXT PRIMARY KEY REFERENCES users(id),
+	owed INT,
+	taken TEXT,
+	charges INT,
+	defaulted INT
+);
+
+CREATE TABLE identities(
+	identity TEXT PRIMARY KEY,
+	uid TEXT REFERENCES users(id),
+	linked TEXT
+);
+
+CREATE TABLE api_tokens(
+	hash TEXT PRIMARY KEY,
+	uid TEXT REFERENCES users(id),
+	name TEXT,
+	created TEXT
+);
+
+CREATE TABLE houses(
+	id TEXT PRIMARY KEY REFERENCES users(id)
+);
+
+CREATE TABLE adjustments(
+	uid TEXT REFERENCES users(id),
+	ts TEXT,
+	amount INT,
+	operator TEXT,
+	reason TEXT
+);
+
 CREATE TABLE crons(
 	id TEXT PRIMARY KEY,
 	lastRun TEXT	
 );
 
+CREATE TABLE seasons(
+	season INT,
+	ended TEXT,
+	uid TEXT REFERENCES users(id),
+	balance INT,
+	rank INT,
+	PRIMARY KEY (season, uid)
+);
+
+CREATE TABLE achievements(
+	uid TEXT REFERENCES users(id),
+	aid TEXT,
+	awarded TEXT,
+	PRIMARY KEY (uid, aid)
+);
+
+CREATE TABLE donations(
+	giver TEXT REFERENCES users(id),
+	receiver TEXT REFERENCES users(id),
+	ts TEXT,
+	amount INT
+);
+
+CREATE TABLE results(
+	uid TEXT REFERENCES users(id),
+	eid TEXT REFERENCES events(id),
+	placed TEXT,
+	resolved TEXT,
+	outcome INT,
+	payout INT,
+	PRIMARY KEY (uid, placed)
+);
+
 CREATE VIEW leaderboard(id, balance, rank) AS
 SELECT id, balance, row_number() OVER()
 FROM (
   SELECT id, balance
   FROM users
+  WHERE id NOT IN (SELECT id FROM houses)
   ORDER BY balance DESC
 );
 
@@ -44,9 +145,48 @@ INSERT OR REPLACE INTO events VALUES('item', '2025-03-01 00:00:00', '2025-03-02
 INSERT OR REPLACE INTO users VALUES('user1', 1000, 0);
 INSERT OR REPLACE INTO users VALUES('user2', 500, 100);
 INSERT OR REPLACE INTO users VALUES('user3', 400, 200);
+INSERT OR REPLACE INTO users VALUES('bank', 5000, 0);
+INSERT INTO houses VALUES('bank');
 INSERT OR REPLACE INTO bets VALUES('user2', 'shiny', '2025-03-01 01:00:00.000', 100, 0.567, 'true,10000');
 INSERT OR REPLACE INTO bets VALUES('user3', 'shiny', '2025-02-28 00:00:00.000', 500, 0.1, 'false,1');
 INSERT OR REPLACE INTO bets VALUES('user3', 'shiny', '2025-03-01 02:00:00.000', 200, 0.4, 'false,10');
 INSERT OR REPLACE INTO bets VALUES('user3', 'item', '2025-03-01 12:00:00.000', 100, 0.95, 'true');
+INSERT OR REPLACE INTO bets VALUES('user1', 'anti', '2025-01-01 00:00:00.000', 100, 0.5, '0,100');
+INSERT OR REPLACE INTO bets VALUES('user1', 'anti', '2025-01-02 00:00:00.000', 100, 0.5, '0,200');
+INSERT OR REPLACE INTO bets VALUES('user1', 'anti', '2025-01-03 00:00:00.000', 50, 0.5, '2,300');
 
 INSERT OR REPLACE INTO crons VALUES('test', '2025-03-01 12:00:00.000');
+
+INSERT OR REPLACE INTO seasons VALUES(1, '2025-02-01 00:00:00', 'user2', 3000, 1);
+INSERT OR REPLACE INTO seasons VALUES(1, '2025-02-01 00:00:00', 'user1', 2000, 2);
+
+INSERT OR REPLACE INTO achievements VALUES('user3', 'exact', '2025-03-01 00:00:00');
+INSERT OR REPLACE INTO donations VALUES('user3', 'user1', '2025-03-01 00:00:00', 250);
+INSERT OR REPLACE INTO donations VALUES('user3', 'user2', '2025-03-02 00:00:00', 50);
+
+INSERT OR REPLACE INTO results VALUES('user3', 'shiny', '2025-02-01 00:00:00', '2025-02-02 00:00:00', -1, -100);
+INSERT OR REPLACE INTO results VALUES('user3', 'shiny', '2025-02-01 01:00:00', '2025-02-02 00:00:00', 1, 250);
+INSERT OR REPLACE INTO results VALUES('user3', 'item', '2025-02-03 00:00:00', '2025-02-04 00:00:00', 0, 0);
+INSERT OR REPLACE INTO results VALUES('user3', 'item', '2025-02-03 01:00:00', '2025-02-04 00:00:00', 1, 40);
+INSERT OR REPLACE INTO results VALUES('user2', 'shiny', '2025-02-01 02:00:00', '2025-02-02 00:00:00', -1, -300);
+INSERT OR REPLACE INTO results VALUES('user2', 'shiny', '2025-01-14 00:00:00', '2025-01-15 00:00:00', 1, 1000);
+
+INSERT OR REPLACE INTO phases VALUES('shiny', '2025-01-10 00:00:00', 5000);
+INSERT OR REPLACE INTO phases VALUES('shiny', '2025-01-20 00:00:00', 11000);
+
+INSERT INTO jackpot VALUES('2025-01-10 00:00:00', 'shiny', 100, 'rake');
+INSERT INTO jackpot VALUES('2025-01-10 00:00:00', 'shiny', 0, 'rollover');
+INSERT INTO jackpot VALUES('2025-01-20 00:00:00', 'shiny', 50, 'rake');
+
+INSERT INTO grants VALUES('user1', '2025-02-01 00:00:00', 'daily', 100, 1);
+INSERT INTO grants VALUES('user1', '2025-02-02 00:00:00', 'daily', 110, 2);
+INSERT INTO grants VALUES('user2', '2025-02-02 00:00:00', 'bailout', 60, 0);
+INSERT INTO grants VALUES('user2', '2025-01-15 00:00:00', 'bailout', 20, 0);
+
+INSERT OR REPLACE INTO loans VALUES('user3', 200, '2025-02-01 00:00:00', 2, 0);
+INSERT OR REPLACE INTO loans VALUES('user2', 50, '2025-01-01 00:00:00', 10, 1);
+
+INSERT INTO identities VALUES('twitch:42', 'user1', '2025-02-01 00:00:00');
+INSERT INTO identities VALUES('twitch:7', 'user2', '2025-02-02 00:00:00');
+
+INSERT INTO api_tokens VALUES('hash1', 'user1', 'overlay', '2025-02-01 00:00:00');
@@ -46,7 +46,39 @@ type Event interface {
 	Interpret(blob string) string
 
 	// BetSummary returns a summary of all the bets placed on the event since it
-	// last opened.  The format of the return is suitable to attach to a Discord
-	// message's content. `style` is one of "risk" or "soon".
+	// last opened.  The return may be arbitrarily long, and is split into pages
+	// on line boundaries for display. `style` is one of "risk" or "soon".
 	BetsSummary(style string) (string, error)
+
+	// Status returns a snapshot of the event's state.
+	Status() EventStatus
+
+	// Cancel refunds the user's bet placed at `placed`, as long as the event
+	// is open and it's still within core's CancelWindow of being placed at
+	// `now`.
+	Cancel(uid string, placed time.Time, now time.Time) error
+}
+
+// EventState is where an event is in its lifecycle.
+type EventState string
+
+const (
+	EventOpen    EventState = "open"
+	EventClosing EventState = "closing"
+	EventClosed  EventState = "closed"
+)
+
+// EventStatus is a snapshot of an event's state, for showing outside of chat.
+type EventStatus struct {
+	ID string
+	// Name is the name of the event to show users.
+	Name string
+	// State is where the event is in its lifecycle.  Bets can only be placed
+	// while the event is open.
+	State EventState
+	// Opened is when the event last opened, or zero if it isn't known.
+	Opened time.Time
+	// Current is how far the event has progressed, which is the number of
+	// encounters in the current phase for phase events.
+	Current int
 }
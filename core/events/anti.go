@@ -2,6 +2,7 @@ package events
 
 import (
 	"bet/core"
+	"bet/env"
 	"bet/state"
 	"fmt"
 	"log/slog"
@@ -20,14 +21,20 @@ type AntiShinyEvent struct {
 	lastAntiEncounters int
 }
 
-func NewAntiShinyEvent(c *core.Core, channel string) *AntiShinyEvent {
+func NewAntiShinyEvent(c *core.Core, odds env.OddsConfig, conf env.PhaseEventConfig, channel string) *AntiShinyEvent {
 	e := &AntiShinyEvent{
 		phaseLifecycle: &phaseLifecycle{
-			eventId:     antiEventName,
-			displayName: "Anti Shiny",
-			probability: 1.0 / 8192.0,
-			core:        c,
-			channel:     channel,
+			eventId:           antiEventName,
+			displayName:       "Anti Shiny",
+			probability:       oddsProbability(odds),
+			odds:              newOddsModel(c.Database, antiEventName, conf.OddsModel),
+			bank:              newBank(c, conf.Payout),
+			rakePercent:       conf.RakePercent,
+			lockoutEncounters: conf.LockoutEncounters,
+			lockoutBefore:     conf.LockoutBefore,
+			riskDecay:         conf.RiskDecay,
+			core:              c,
+			channel:           channel,
 		},
 		c: c,
 	}
@@ -69,6 +76,7 @@ func loadAntiEvent(event *AntiShinyEvent) {
 		if !closeTs.After(openTs) {
 			event.phaseLifecycle.state = OPEN
 			event.phaseLifecycle.current = phase
+			event.phaseLifecycle.opened = openTs
 			event.lastAntiEncounters = encounters
 		}
 	}
@@ -0,0 +1,174 @@
+package events
+
+import (
+	"bet/core"
+	"bet/core/db"
+	"bet/env"
+	"fmt"
+	"log/slog"
+	"math"
+)
+
+// BankError is returned from Wager when the bank can't cover the payout of a
+// fixed-odds bet, either from its balance or under its exposure limit.
+type BankError struct{}
+
+func (e BankError) Error() string {
+	return "the bank cannot cover the payout of this bet"
+}
+
+// bank is the house in fixed-odds mode.  It pays winners at the odds of their
+// bet and keeps the stakes of losers, instead of winners splitting the losers'
+// stakes.  The potential payout of every open bet is reserved from the bank's
+// balance when the bet is placed, so the bank can always pay.
+type bank struct {
+	uid string
+	// The most the bank can have reserved across all open bets, or 0 for no
+	// limit other than its balance.
+	exposureLimit int
+}
+
+// newBank returns the bank for the payout config, or nil if the event pays out
+// pari-mutuel.  The bank is registered with core as a house, so it's funded and
+// kept apart from the players.
+func newBank(c *core.Core, conf env.PayoutConfig) *bank {
+	switch conf.Mode {
+	case "", "pari-mutuel":
+		return nil
+	case "fixed":
+		if conf.Bank == "" {
+			slog.Error("fixed-odds payout needs a bank user, using pari-mutuel")
+			return nil
+		}
+		if err := c.RegisterHouse(conf.Bank, conf.Funding); err != nil {
+			slog.Error(fmt.Sprintf("could not register bank %s, using pari-mutuel: %v", conf.Bank, err))
+			return nil
+		}
+		return &bank{uid: conf.Bank, exposureLimit: conf.ExposureLimit}
+	}
+	slog.Error(fmt.Sprintf("unknown payout mode %q, using pari-mutuel", conf.Mode))
+	return nil
+}
+
+// fixedOddsPayout is what the bank pays on top of the stake for a winning bet,
+// so the bet has no expected gain or loss at the risk it was placed with.
+func fixedOddsPayout(amount int, risk float64) int {
+	return int(math.Floor(float64(amount) * risk / (1 - risk)))
+}
+
+// cover checks the bank can pay out the bet if it wins.  This doesn't make any
+// writes, so it can be checked before opening the wager's transaction.  The
+// caller must hold core's WagerMu until the payout is reserved, since the
+// shiny and anti events can share a bank.
+func (b *bank) cover(c *core.Core, payout int) error {
+	house, err := c.GetUser(b.uid)
+	if err != nil {
+		return err
+	}
+	balance, inBets, err := house.Balance()
+	if err != nil {
+		return err
+	}
+	if payout > balance-inBets {
+		return BankError{}
+	}
+	if b.exposureLimit > 0 && inBets+payout > b.exposureLimit {
+		return BankError{}
+	}
+	return nil
+}
+
+// reserve sets aside the payout of a bet from the bank's balance.
+func (b *bank) reserve(c *core.Core, tx db.Transaction, payout int) error {
+	if payout == 0 {
+		return nil
+	}
+	house, err := c.GetUser(b.uid)
+	if err != nil {
+		return err
+	}
+	if err := house.Reserve(tx, payout); err != nil {
+		return BankError{}
+	}
+	return nil
+}
+
+// unreserve undoes reserve for a wager that couldn't be placed.  The
+// transaction is rolled back afterwards, so only the cached reservation is
+// really undone.
+func (b *bank) unreserve(c *core.Core, tx db.Transaction, payout int) {
+	if payout == 0 {
+		return
+	}
+	house, err := c.GetUser(b.uid)
+	if err != nil {
+		slog.Error(fmt.Sprintf("could not load bank %s to undo a reservation: %v", b.uid, err))
+		return
+	}
+	if err := house.Resolve(tx, payout, false); err != nil {
+		slog.Error(fmt.Sprintf("could not undo bank reservation: %v", err))
+	}
+}
+
+// release frees the payouts reserved for bets that are refunded instead of
+// settled.
+func (b *bank) release(c *core.Core, tx db.Transaction, bets []*internalPhaseBet) {
+	house, err := c.GetUser(b.uid)
+	if err != nil {
+		slog.Error(fmt.Sprintf("could not load bank %s to release bets: %v", b.uid, err))
+		return
+	}
+	for _, bet := range bets {
+		if payout := fixedOddsPayout(bet.amount, bet.risk); payout > 0 {
+			if err := house.Resolve(tx, payout, false); err != nil {
+				slog.Warn(fmt.Sprintf("could not release bank reservation: %v", err))
+			}
+		}
+	}
+}
+
+// settle pays the winners of the bets from the bank and gives the bank the
+// stakes of the losers.  The stakes themselves must already be resolved.
+// Returns the user deltas with the bank's gains and losses included.
+func (b *bank) settle(c *core.Core, tx db.Transaction, bets []*internalPhaseBet, phase int, userDelta map[string]int) map[string]int {
+	house, err := c.GetUser(b.uid)
+	if err != nil {
+		slog.Error(fmt.Sprintf("could not load bank %s to settle bets: %v", b.uid, err))
+		return userDelta
+	}
+	for _, bet := range bets {
+		payout := fixedOddsPayout(bet.amount, bet.risk)
+		if !bet.won(phase) {
+			if payout > 0 {
+				if err := house.Resolve(tx, payout, false); err != nil {
+					slog.Warn(fmt.Sprintf("could not release bank reservation: %v", err))
+				}
+			}
+			if err := house.Earn(tx, bet.amount); err != nil {
+				slog.Warn(fmt.Sprintf("could not give stake to bank: %v", err))
+				continue
+			}
+			userDelta[b.uid] += bet.amount
+			continue
+		}
+		if payout == 0 {
+			continue
+		}
+		if err := house.Resolve(tx, payout, true); err != nil {
+			slog.Warn(fmt.Sprintf("could not take payout from bank: %v", err))
+			continue
+		}
+		user, err := c.GetUser(bet.uid)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("could not load user %s to pay out: %v", bet.uid, err))
+			continue
+		}
+		if _, err := user.Payout(tx, payout); err != nil {
+			slog.Warn(fmt.Sprintf("could not pay out fixed-odds bet: %v", err))
+			continue
+		}
+		userDelta[b.uid] -= payout
+		userDelta[bet.uid] += payout
+	}
+	return userDelta
+}
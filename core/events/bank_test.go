@@ -0,0 +1,226 @@
+package events
+
+import (
+	"bet/core"
+	"bet/core/db"
+	"bet/env"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewBank(t *testing.T) {
+	c := core.New(db.Fake(), nil, nil)
+	for _, tc := range []struct {
+		conf     env.PayoutConfig
+		wantBank bool
+	}{
+		{conf: env.PayoutConfig{}, wantBank: false},
+		{conf: env.PayoutConfig{Mode: "pari-mutuel", Bank: "bank"}, wantBank: false},
+		{conf: env.PayoutConfig{Mode: "fixed", Bank: "bank"}, wantBank: true},
+		// Misconfigured banks fall back to pari-mutuel.
+		{conf: env.PayoutConfig{Mode: "fixed"}, wantBank: false},
+		{conf: env.PayoutConfig{Mode: "nope", Bank: "bank"}, wantBank: false},
+	} {
+		if got := newBank(c, tc.conf); (got != nil) != tc.wantBank {
+			t.Errorf("newBank(%+v) = %v, want bank %t", tc.conf, got, tc.wantBank)
+		}
+	}
+}
+
+func TestFixedOddsPayout(t *testing.T) {
+	for _, tc := range []struct {
+		amount int
+		risk   float64
+		want   int
+	}{
+		{amount: 100, risk: 0.5, want: 100},
+		{amount: 100, risk: 0.25, want: 33},
+		{amount: 100, risk: 0.75, want: 300},
+		{amount: 1, risk: 0.1, want: 0},
+	} {
+		if got := fixedOddsPayout(tc.amount, tc.risk); got != tc.want {
+			t.Errorf("fixedOddsPayout(%d, %v) = %d, want %d", tc.amount, tc.risk, got, tc.want)
+		}
+	}
+}
+
+func TestPhaseResolveFixedOdds(t *testing.T) {
+	d := db.Fake()
+	s := &FakeSession{}
+	c := core.New(d, s, nil)
+	l := phaseLifecycle{
+		eventId:     "test",
+		probability: 0.5,
+		bank:        &bank{uid: "bank"},
+		core:        c,
+		channel:     "not empty",
+		state:       OPEN,
+	}
+	betTime := time.Date(2020, time.January, 2, 0, 0, 0, 0, time.UTC)
+	// risk 0.25, pays 33
+	placed, err := l.Wager("user1", 100, betTime, PhaseBet{Direction: LESS, Phase: 3})
+	if err != nil {
+		t.Fatalf("Wager() returned unexpected error: %v", err)
+	}
+	if got := placed.(PlacedPhaseBet).Payout; got != 33 {
+		t.Errorf("placed bet pays %d, want 33", got)
+	}
+	// risk 0.75, pays 300
+	if _, err := l.Wager("user2", 100, betTime, PhaseBet{Direction: GREATER, Phase: 2}); err != nil {
+		t.Fatalf("Wager() returned unexpected error: %v", err)
+	}
+	house, _ := c.GetUser("bank")
+	if _, inBets, _ := house.Balance(); inBets != 333 {
+		t.Errorf("bank has %d reserved, want 333", inBets)
+	}
+
+	l.Update(2)
+	if err := l.Close(time.Date(2020, time.January, 2, 0, 0, 1, 0, time.UTC)); err != nil {
+		t.Fatalf("Close() returned unexpected error: %v", err)
+	}
+	if err := l.Resolve(); err != nil {
+		t.Fatalf("Resolve() returned unexpected error: %v", err)
+	}
+	for _, tc := range []struct {
+		uid         string
+		wantBalance int
+	}{
+		// user1 wins 33 from the bank without anyone else having to lose.
+		{uid: "user1", wantBalance: 1033},
+		{uid: "user2", wantBalance: 900},
+		{uid: "bank", wantBalance: 1067},
+	} {
+		u, _ := c.GetUser(tc.uid)
+		balance, inBets, _ := u.Balance()
+		if balance != tc.wantBalance || inBets != 0 {
+			t.Errorf("%s has %d balance and %d in bets, want %d and 0", tc.uid, balance, inBets, tc.wantBalance)
+		}
+	}
+}
+
+func TestPhaseWagerBankCannotCover(t *testing.T) {
+	d := db.Fake()
+	c := core.New(d, &FakeSession{}, nil)
+	l := phaseLifecycle{
+		eventId:     "test",
+		probability: 0.5,
+		bank:        &bank{uid: "bank", exposureLimit: 300},
+		core:        c,
+		state:       OPEN,
+	}
+	betTime := time.Date(2020, time.January, 2, 0, 0, 0, 0, time.UTC)
+	if _, err := l.Wager("user1", 100, betTime, PhaseBet{Direction: LESS, Phase: 3}); err != nil {
+		t.Fatalf("Wager() returned unexpected error: %v", err)
+	}
+	// Pays 300, but the bank already owes 33.
+	_, err := l.Wager("user2", 100, betTime, PhaseBet{Direction: GREATER, Phase: 2})
+	if !errors.Is(err, BankError{}) {
+		t.Errorf("Wager() over the exposure limit returned %v, want BankError", err)
+	}
+	u2, _ := c.GetUser("user2")
+	if _, inBets, _ := u2.Balance(); inBets != 0 {
+		t.Errorf("rejected bet left user2 with %d in bets, want 0", inBets)
+	}
+
+	// Pays 1500, more than the bank's balance.
+	l.bank.exposureLimit = 0
+	_, err = l.Wager("user3", 500, betTime, PhaseBet{Direction: GREATER, Phase: 2})
+	if !errors.Is(err, BankError{}) {
+		t.Errorf("Wager() over the bank's balance returned %v, want BankError", err)
+	}
+	house, _ := c.GetUser("bank")
+	if _, inBets, _ := house.Balance(); inBets != 33 {
+		t.Errorf("bank has %d reserved, want 33", inBets)
+	}
+}
+
+func TestPhaseWagerSharedBank(t *testing.T) {
+	d := db.Fake()
+	c := core.New(d, &FakeSession{}, nil)
+	newEvent := func(eid string) *phaseLifecycle {
+		return &phaseLifecycle{
+			eventId:     eid,
+			probability: 0.5,
+			bank:        &bank{uid: "bank"},
+			core:        c,
+			state:       OPEN,
+		}
+	}
+	shiny, anti := newEvent("shiny"), newEvent("anti")
+	betTime := time.Date(2020, time.January, 2, 0, 0, 0, 0, time.UTC)
+
+	// A stake the user can't cover reserves nothing from the bank either.
+	if _, err := shiny.Wager("user", 2000, betTime, PhaseBet{Direction: LESS, Phase: 10}); !errors.Is(err, &core.BalanceError{}) {
+		t.Errorf("Wager() over the user's balance returned %v, want BalanceError", err)
+	}
+	house, _ := c.GetUser("bank")
+	if _, inBets, _ := house.Balance(); inBets != 0 {
+		t.Errorf("bank has %d reserved after a rejected wager, want 0", inBets)
+	}
+
+	// Each bet pays 300, so only 3 fit in the bank's 1000 across both events,
+	// however the wagers interleave.
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			event := shiny
+			if i%2 == 1 {
+				event = anti
+			}
+			event.Wager(fmt.Sprintf("user%d", i), 100, betTime, PhaseBet{Direction: GREATER, Phase: 2})
+		}(i)
+	}
+	wg.Wait()
+	balance, inBets, _ := house.Balance()
+	if inBets != 900 || balance != 1000 {
+		t.Errorf("bank has %d of %d reserved, want 900 of 1000", inBets, balance)
+	}
+}
+
+// failingCommitDB is a fake database whose transactions fail to commit.
+type failingCommitDB struct {
+	db.Database
+}
+
+func (d failingCommitDB) OpenTransaction() (db.Transaction, error) {
+	tx, err := d.Database.OpenTransaction()
+	return failingCommitTx{tx}, err
+}
+
+type failingCommitTx struct {
+	db.Transaction
+}
+
+func (t failingCommitTx) Commit() error {
+	return errors.New("disk I/O error")
+}
+
+func TestWagerCommitFails(t *testing.T) {
+	c := core.New(db.Fake(), nil, nil)
+	l := phaseLifecycle{
+		eventId:     "test",
+		probability: 0.5,
+		bank:        &bank{uid: "bank"},
+		core:        c,
+		state:       OPEN,
+	}
+	user, _ := c.GetUser("user1")
+	house, _ := c.GetUser("bank")
+	c.Database = failingCommitDB{c.Database}
+	betTime := time.Date(2020, time.January, 2, 0, 0, 0, 0, time.UTC)
+	if _, err := l.Wager("user1", 100, betTime, PhaseBet{Direction: LESS, Phase: 3}); err == nil {
+		t.Fatalf("Wager() with a failing commit returned no error")
+	}
+	// Nothing was stored, so nothing stays reserved in the cache either.
+	if _, inBets, _ := user.Balance(); inBets != 0 {
+		t.Errorf("user1 has %d in bets after the failed wager, want 0", inBets)
+	}
+	if _, inBets, _ := house.Balance(); inBets != 0 {
+		t.Errorf("bank has %d reserved after the failed wager, want 0", inBets)
+	}
+}
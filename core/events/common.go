@@ -1,8 +1,10 @@
 package events
 
 import (
+	"bet/core"
 	"bet/core/db"
 	"fmt"
+	"log/slog"
 	"slices"
 	"time"
 )
@@ -16,6 +18,17 @@ const (
 	CLOSING
 )
 
+// String returns the name of the state, as used in core.EventStatus.
+func (s EventState) String() string {
+	switch s {
+	case OPEN:
+		return "open"
+	case CLOSING:
+		return "closing"
+	}
+	return "closed"
+}
+
 type StateMachineError struct {
 	expected EventState
 	actual   EventState
@@ -90,8 +103,211 @@ func sortedDeltas(userDelta map[string]int) []delta {
 	return deltas
 }
 
+// isLate returns whether a bet placed at placed falls within the lockout before
+// the event closed.  A zero lockout means no bets are late.
+func isLate(placed time.Time, closed time.Time, lockout time.Duration) bool {
+	return lockout > 0 && placed.After(closed.Add(-lockout))
+}
+
+// placedBet is a bet of any event, for splitting out the late ones.
+type placedBet interface {
+	placedAt() time.Time
+}
+
+// splitLate separates the bets placed within the lockout before the event
+// closed, which are refunded, from the bets placed in time.
+func splitLate[B placedBet](bets []B, closed time.Time, lockout time.Duration) ([]B, []B) {
+	onTime := make([]B, 0, len(bets))
+	late := make([]B, 0)
+	for _, b := range bets {
+		if isLate(b.placedAt(), closed, lockout) {
+			late = append(late, b)
+		} else {
+			onTime = append(onTime, b)
+		}
+	}
+	return onTime, late
+}
+
+// lateMessage describes the bets refunded for being placed too close to the
+// event closing, to be appended to the resolve message.
+func lateMessage(late int, lockout time.Duration) string {
+	if late == 0 {
+		return ""
+	}
+	return fmt.Sprintf("\n%d bets placed in the last %s before the event closed were refunded.", late, lockout)
+}
+
+// cancelBet refunds the user's bet on the event placed at `placed`, after
+// checking it's within core's cancel window.  release frees anything else the
+// event reserved for the bet, in the same transaction.  The caller must hold
+// the event's lock, so the event can't resolve while the bet is cancelled.
+func cancelBet(c *core.Core, eid string, uid string, placed time.Time, now time.Time, release func(tx db.Transaction, b core.Bet) error) error {
+	b, err := c.CheckCancel(eid, uid, placed, now)
+	if err != nil {
+		return err
+	}
+	c.EventMu.Lock()
+	defer c.EventMu.Unlock()
+	user, err := c.GetUser(uid)
+	if err != nil {
+		return err
+	}
+	tx, err := c.Database.OpenTransaction()
+	if err != nil {
+		return err
+	}
+	if err := tx.DeleteBet(uid, placed); err != nil {
+		return err
+	}
+	if err := user.Resolve(tx, b.Amount, false); err != nil {
+		return err
+	}
+	if err := release(tx, b); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// wager is a bet to be placed with placeWager.
+type wager struct {
+	uid    string
+	eid    string
+	amount int
+	placed time.Time
+	risk   float64
+	// blob is the bet as it's stored.
+	blob string
+}
+
+// placeWager checks the wager limits, reserves the stake from the user and
+// stores the bet.  At fixed odds house is the bank, which reserves the payout
+// of the bet first.  This is all done under core's WagerMu, so concurrent
+// wagers can't together break the limits or promise more than the bank can
+// cover.  Nothing is reserved if the wager fails, including when the user
+// already placed a bet in the same second.
+// Returns the all in unlock, if the wager unlocked it.
+func placeWager(c *core.Core, house *bank, payout int, w wager) (*core.Unlock, error) {
+	c.WagerMu.Lock()
+	defer c.WagerMu.Unlock()
+	if err := c.CheckWager(w.uid, w.eid, w.amount); err != nil {
+		return nil, err
+	}
+	duplicate, err := c.Database.BetPlaced(w.uid, w.placed)
+	if err != nil {
+		return nil, err
+	}
+	if duplicate {
+		return nil, core.DuplicateBetError{}
+	}
+	if house != nil {
+		if err := house.cover(c, payout); err != nil {
+			return nil, err
+		}
+	}
+	user, err := c.GetUser(w.uid)
+	if err != nil {
+		return nil, err
+	}
+	allIn := isAllIn(user, w.amount)
+	tx, err := c.Database.OpenTransaction()
+	if err != nil {
+		return nil, err
+	}
+	if house != nil {
+		if err := house.reserve(c, tx, payout); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+	if err := user.Reserve(tx, w.amount); err != nil {
+		if house != nil {
+			house.unreserve(c, tx, payout)
+		}
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.WriteBet(w.uid, w.eid, w.placed, w.amount, w.risk, w.blob); err != nil {
+		// The stake and payout were reserved in the cache, so undo them too.
+		unreserveWager(c, tx, house, payout, user, w.amount)
+		tx.Rollback()
+		return nil, err
+	}
+	unlock := awardAllIn(c, tx, w.uid, allIn)
+	if err := tx.Commit(); err != nil {
+		// Nothing was stored, but the cache still holds the reservations.  The
+		// failed transaction can't be written to any more, so they're undone
+		// in one that's rolled back.
+		undo, undoErr := c.Database.OpenTransaction()
+		if undoErr != nil {
+			slog.Error(fmt.Sprintf("could not undo reservations of a failed wager by %s: %v", w.uid, undoErr))
+			return nil, err
+		}
+		unreserveWager(c, undo, house, payout, user, w.amount)
+		undo.Rollback()
+		return nil, err
+	}
+	return unlock, nil
+}
+
+// resolver is the part of a user needed to undo a reservation.
+type resolver interface {
+	Resolve(t db.Transaction, amount int, loss bool) error
+}
+
+// unreserveWager undoes the cached reservations of a wager that couldn't be
+// stored.  The transaction must be rolled back afterwards.
+func unreserveWager(c *core.Core, tx db.Transaction, house *bank, payout int, user resolver, amount int) {
+	if house != nil {
+		house.unreserve(c, tx, payout)
+	}
+	if err := user.Resolve(tx, amount, false); err != nil {
+		slog.Error(fmt.Sprintf("could not undo wager reservation: %v", err))
+	}
+}
+
 type BettingClosedError struct{}
 
 func (err BettingClosedError) Error() string {
 	return "betting is closed"
 }
+
+// balancer is the part of a user needed to check for achievements on wagers.
+type balancer interface {
+	Balance() (int, int, error)
+}
+
+// isAllIn returns whether the wager is every cake the user has left to bet.
+// This must be checked before the wager is reserved.
+func isAllIn(u balancer, amount int) bool {
+	balance, inBets, err := u.Balance()
+	if err != nil {
+		return false
+	}
+	return amount == balance-inBets
+}
+
+// Awards the all in achievement as part of the wager transaction, if the wager
+// was all in.  Returns the new unlock, or nil if there wasn't one.
+func awardAllIn(c *core.Core, tx db.Transaction, uid string, allIn bool) *core.Unlock {
+	if !allIn {
+		return nil
+	}
+	unlock, err := c.Award(tx, uid, core.AchievementAllIn)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("could not award all in achievement: %v", err))
+		return nil
+	}
+	return unlock
+}
+
+// Announces a new unlock in the channel.  Does nothing for nil unlocks or an
+// empty channel.
+func announceUnlock(c *core.Core, channel string, unlock *core.Unlock) {
+	if unlock == nil || channel == "" {
+		return
+	}
+	if err := c.SendMessage(channel, fmt.Sprintf("Achievement unlocked! %s", unlock)); err != nil {
+		slog.Warn(fmt.Sprintf("error announcing achievement: %v", err))
+	}
+}
@@ -26,10 +26,13 @@ type ItemEvent struct {
 	prob     float64
 	keepOpen bool
 	cond     env.Condition
+	// Bets placed within lockout of the event closing are refunded.
+	lockout time.Duration
 	// State keeping for lifecycle
 	mu         sync.Mutex
 	state      EventState
 	resolution bool
+	closed     time.Time
 	c          *core.Core
 	channel    string
 }
@@ -47,6 +50,7 @@ func NewItemEvent(c *core.Core, conf env.ItemEventConfig, channel string) *ItemE
 		prob:     conf.Probability,
 		keepOpen: conf.KeepOpen,
 		cond:     conf.KeepOpenCondition,
+		lockout:  conf.LockoutBefore,
 		channel:  channel,
 	}
 	e = loadItemEvent(e)
@@ -59,7 +63,7 @@ func NewItemEvent(c *core.Core, conf env.ItemEventConfig, channel string) *ItemE
 func loadItemEvent(e *ItemEvent) *ItemEvent {
 	row, err := e.c.Database.LoadEvent(e.ID)
 	if err != nil {
-		slog.Error("could not load item event from db: %v", err)
+		slog.Error(fmt.Sprintf("could not load item event from db: %v", err))
 		return e
 	}
 	gotRow := false
@@ -177,8 +181,10 @@ func (e *ItemEvent) Close(t time.Time) error {
 	defer e.mu.Unlock()
 	var err error
 	e.state, err = commonClose(e.c.Database, e.ID, t, e.state)
+	if err == nil {
+		e.closed = t
+	}
 	return err
-	return nil
 }
 
 func (e *ItemEvent) Resolve() error {
@@ -195,6 +201,9 @@ func (e *ItemEvent) Resolve() error {
 	if err != nil {
 		return err
 	}
+	bets, late := splitLate(bets, e.closed, e.lockout)
+	e.resolveBets(tx, late, true)
+	e.recordResults(tx, late, true, 0, 0)
 
 	payout, winners, userContribution := e.calcPayout(bets)
 	refund := false
@@ -204,13 +213,27 @@ func (e *ItemEvent) Resolve() error {
 
 	userDelta := e.resolveBets(tx, bets, refund)
 	userDelta = e.payoutWinners(tx, payout, winners, userContribution, userDelta)
+	e.recordResults(tx, bets, refund, payout, winners)
+	unlocks := make([]core.Unlock, 0)
+	if !refund {
+		unlocks = e.awardAchievements(tx, bets)
+	}
 	if e.channel != "" {
-		e.sendMessage(userDelta)
+		e.sendMessage(userDelta, unlocks, len(late))
 	}
 
 	if err := tx.Commit(); err != nil {
 		return err
 	}
+	defaults, err := e.c.ChargeInterest()
+	if err != nil {
+		slog.Warn(fmt.Sprintf("could not charge loan interest: %v", err))
+	}
+	if message := core.DefaultsMessage(defaults); message != "" && e.channel != "" {
+		if err := e.c.SendMessage(e.channel, strings.TrimPrefix(message, "\n")); err != nil {
+			slog.Warn(fmt.Sprintf("could not send defaults: %v", err))
+		}
+	}
 	e.state = CLOSED
 	return nil
 }
@@ -218,9 +241,13 @@ func (e *ItemEvent) Resolve() error {
 type itemBet struct {
 	uid    string
 	amount int
+	risk   float64
 	guess  bool
+	placed time.Time
 }
 
+func (b itemBet) placedAt() time.Time { return b.placed }
+
 func (e *ItemEvent) loadItemBets() ([]itemBet, error) {
 	rows, err := e.c.Database.LoadBets(e.ID)
 	if err != nil {
@@ -229,11 +256,11 @@ func (e *ItemEvent) loadItemBets() ([]itemBet, error) {
 	b := make([]itemBet, 0)
 	for rows.Next() {
 		var uid string
-		var eid string    // unused
-		var placed string // unused
+		var eid string // unused
+		var placed string
 		var amount int
-		var risk float64 // unused
-		var bet string   // TODO: verify this works?  It doesn't with the fake db.
+		var risk float64
+		var bet string // TODO: verify this works?  It doesn't with the fake db.
 		if err := rows.Scan(&uid, &eid, &placed, &amount, &risk, &bet); err != nil {
 			continue
 		}
@@ -241,7 +268,11 @@ func (e *ItemEvent) loadItemBets() ([]itemBet, error) {
 		if bet == "true" {
 			guess = true
 		}
-		b = append(b, itemBet{uid: uid, amount: amount, guess: guess})
+		placedTs, err := time.Parse(time.DateTime, placed)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("unable to parse bet placed time %s: %v", placed, err))
+		}
+		b = append(b, itemBet{uid: uid, amount: amount, risk: risk, guess: guess, placed: placedTs})
 	}
 	return b, nil
 }
@@ -293,7 +324,7 @@ func (e *ItemEvent) payoutWinners(tx db.Transaction, payout int, winners int, us
 			continue
 		}
 		gain := int(math.Ceil(float64(contribution) * ratio))
-		if err := u.Earn(tx, gain); err != nil {
+		if _, err := u.Payout(tx, gain); err != nil {
 			continue
 		}
 		userDelta[uid] += gain
@@ -301,7 +332,46 @@ func (e *ItemEvent) payoutWinners(tx db.Transaction, payout int, winners int, us
 	return userDelta
 }
 
-func (e *ItemEvent) sendMessage(userDelta map[string]int) {
+// Records the outcome of every bet for lifetime stats.
+func (e *ItemEvent) recordResults(tx db.Transaction, bets []itemBet, refund bool, payout int, winners int) {
+	resolved := time.Now()
+	for _, b := range bets {
+		outcome := db.OutcomeLoss
+		net := -b.amount
+		if refund {
+			outcome = db.OutcomeRefund
+			net = 0
+		} else if b.guess == e.resolution {
+			outcome = db.OutcomeWin
+			net = int(math.Ceil(float64(b.amount) * float64(payout) / float64(winners)))
+		}
+		if err := tx.WriteResult(b.uid, e.ID, b.placed, resolved, outcome, net); err != nil {
+			slog.Warn(fmt.Sprintf("could not record bet result: %v", err))
+		}
+	}
+}
+
+// Awards achievements for winning bets as part of the resolve transaction, and
+// returns the new unlocks.
+func (e *ItemEvent) awardAchievements(tx db.Transaction, bets []itemBet) []core.Unlock {
+	unlocks := make([]core.Unlock, 0)
+	for _, b := range bets {
+		if b.guess != e.resolution || b.risk <= core.LongshotRisk {
+			continue
+		}
+		unlock, err := e.c.Award(tx, b.uid, core.AchievementLongshot)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("could not award longshot achievement: %v", err))
+			continue
+		}
+		if unlock != nil {
+			unlocks = append(unlocks, *unlock)
+		}
+	}
+	return unlocks
+}
+
+func (e *ItemEvent) sendMessage(userDelta map[string]int, unlocks []core.Unlock, late int) {
 	message := strings.Builder{}
 	dir := "was NOT holding"
 	if e.resolution {
@@ -315,7 +385,7 @@ func (e *ItemEvent) sendMessage(userDelta map[string]int) {
 	} else {
 		fmt.Fprintf(&message, "\nNet cake gain/loss:")
 	}
-	for i, d := range deltas {
+	for _, d := range deltas {
 		user, err := e.c.GetUser(d.uid)
 		if err != nil {
 			slog.Warn(fmt.Sprintf("error getting user while making message: %s", err))
@@ -325,13 +395,10 @@ func (e *ItemEvent) sendMessage(userDelta map[string]int) {
 		if err != nil {
 			slog.Warn(fmt.Sprintf("error getting users balance: %s", err))
 		}
-		nextDelta := fmt.Sprintf("\n * %s (new balance %d cakes)", d, balance)
-		if len(nextDelta)+message.Len() >= 1975 {
-			fmt.Fprintf(&message, "and %d other participants", len(deltas)-i)
-			break
-		}
-		message.WriteString(nextDelta)
+		fmt.Fprintf(&message, "\n * %s (new balance %d cakes)", d, balance)
 	}
+	message.WriteString(lateMessage(late, e.lockout))
+	message.WriteString(core.UnlocksMessage(unlocks))
 	if err := e.c.SendMessage(e.channel, message.String()); err != nil {
 		slog.Warn(fmt.Sprintf("error sending closing message: %v", err))
 	}
@@ -352,27 +419,41 @@ func (e *ItemEvent) Wager(uid string, amount int, placed time.Time, bet any) (an
 	if guess {
 		risk = 1 - e.prob
 	}
-	user, err := e.c.GetUser(uid)
-	if err != nil {
-		return 0.0, err
-	}
-	tx, err := e.c.Database.OpenTransaction()
+	unlock, err := placeWager(e.c, nil, 0, wager{
+		uid:    uid,
+		eid:    e.ID,
+		amount: amount,
+		placed: placed,
+		risk:   risk,
+		blob:   fmt.Sprintf("%t", guess),
+	})
 	if err != nil {
 		return 0.0, err
 	}
-	if err := user.Reserve(tx, amount); err != nil {
-		return 0.0, err
-	}
-	if err := tx.WriteBet(uid, e.ID, placed, amount, risk, fmt.Sprintf("%t", guess)); err != nil {
-		return 0.0, err
-	}
-	if err := tx.Commit(); err != nil {
-		return 0.0, err
-	}
+	announceUnlock(e.c, e.channel, unlock)
 	wagerSuccess.WithLabelValues(e.ID).Inc()
 	return risk, nil
 }
 
+func (e *ItemEvent) Status() core.EventStatus {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return core.EventStatus{
+		ID:    e.ID,
+		Name:  fmt.Sprintf("%s holding %s", e.species, e.item),
+		State: core.EventState(e.state.String()),
+	}
+}
+
+func (e *ItemEvent) Cancel(uid string, placed time.Time, now time.Time) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.state != OPEN {
+		return BettingClosedError{}
+	}
+	return cancelBet(e.c, e.ID, uid, placed, now, func(db.Transaction, core.Bet) error { return nil })
+}
+
 func (e *ItemEvent) Interpret(blob string) string {
 	if blob == "true" {
 		return fmt.Sprintf("%s WILL hold %s", e.species, e.item)
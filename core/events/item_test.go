@@ -5,7 +5,9 @@ import (
 	"bet/core/db"
 	"bet/state"
 	"encoding/json"
+	"errors"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -149,3 +151,146 @@ func TestItemWager(t *testing.T) {
 		t.Errorf("user3 has %d in bets, expected 0", inBets)
 	}
 }
+
+func TestItemResolveLongshot(t *testing.T) {
+	d := db.Fake()
+	s := &FakeSession{}
+	c := core.New(d, s, nil)
+	e := ItemEvent{
+		ID:      "item",
+		c:       c,
+		prob:    0.005,
+		state:   OPEN,
+		channel: "not empty",
+	}
+	e.Wager("longshot", 100, time.Now(), true)
+	e.Wager("safe", 100, time.Now(), false)
+	e.Update(true)
+	e.Close(time.Now())
+	if err := e.Resolve(); err != nil {
+		t.Fatalf("Resolve() returned unexpected error: %v", err)
+	}
+	results := s.Sent[len(s.Sent)-1]
+	if !strings.Contains(results, "<@longshot> unlocked **Longshot**") {
+		t.Errorf("results = %s, wanted the winning 99.5%% risk bet to unlock Longshot", results)
+	}
+	if strings.Contains(results, "<@safe> unlocked") {
+		t.Errorf("results = %s, wanted no unlock for the losing bet", results)
+	}
+}
+
+func TestItemResolveLateBets(t *testing.T) {
+	d := db.Fake()
+	s := &FakeSession{}
+	c := core.New(d, s, nil)
+	e := ItemEvent{
+		ID:      "item",
+		c:       c,
+		prob:    0.5,
+		state:   OPEN,
+		channel: "not empty",
+		lockout: 30 * time.Second,
+	}
+	closeTime := time.Date(2020, time.January, 2, 0, 1, 0, 0, time.UTC)
+	e.Wager("user1", 100, closeTime.Add(-time.Minute), true)
+	e.Wager("user3", 100, closeTime.Add(-time.Minute), false)  // loss
+	e.Wager("user2", 100, closeTime.Add(-5*time.Second), true) // late
+	e.Update(true)
+	if err := e.Close(closeTime); err != nil {
+		t.Errorf("Close() returned unexpected error: %v", err)
+	}
+	if err := e.Resolve(); err != nil {
+		t.Errorf("Resolve() returned unexpected error: %v", err)
+	}
+	// The late bet would have won, but is refunded instead, so user1 takes the
+	// whole payout.
+	for _, tc := range []struct {
+		uid     string
+		balance int
+	}{
+		{uid: "user1", balance: 1100},
+		{uid: "user2", balance: 1000},
+		{uid: "user3", balance: 900},
+	} {
+		u, _ := c.GetUser(tc.uid)
+		balance, inBets, _ := u.Balance()
+		if balance != tc.balance || inBets != 0 {
+			t.Errorf("%s has %d balance and %d in bets, expected %d and 0", tc.uid, balance, inBets, tc.balance)
+		}
+	}
+	if len(s.Sent) != 1 || !strings.Contains(s.Sent[0], "1 bets placed in the last 30s") {
+		t.Errorf("resolve message doesn't mention the late bet: %q", s.Sent)
+	}
+}
+
+func TestItemWagerLimits(t *testing.T) {
+	d := db.Fake()
+	c := core.New(d, &FakeSession{}, nil)
+	c.SetLimits(core.WagerLimits{MaxBet: 300, MaxEventExposure: 400})
+	e := ItemEvent{
+		ID:    "item",
+		c:     c,
+		prob:  0.05,
+		state: OPEN,
+	}
+	var limitErr core.LimitError
+	if _, err := e.Wager("user1", 500, time.Now(), true); !errors.As(err, &limitErr) || limitErr.Limit != core.LimitMaxBet {
+		t.Errorf("Wager() over the max bet returned %v, want max bet limit error", err)
+	}
+	if _, err := e.Wager("user1", 300, time.Now(), true); err != nil {
+		t.Errorf("Unexpected error in wager: %v", err)
+	}
+	if _, err := e.Wager("user1", 200, time.Now(), false); !errors.As(err, &limitErr) || limitErr.Limit != core.LimitEventExposure {
+		t.Errorf("Wager() over the event exposure returned %v, want event exposure limit error", err)
+	}
+	// Rejected wagers don't reserve anything.
+	u1, _ := c.GetUser("user1")
+	if _, inBets, _ := u1.Balance(); inBets != 300 {
+		t.Errorf("user1 has %d in bets, expected 300", inBets)
+	}
+}
+
+func TestItemWagerConcurrentLimits(t *testing.T) {
+	d := db.Fake()
+	c := core.New(d, &FakeSession{}, nil)
+	c.SetLimits(core.WagerLimits{MaxOpenBets: 3})
+	events := []*ItemEvent{
+		{ID: "item1", c: c, prob: 0.5, state: OPEN},
+		{ID: "item2", c: c, prob: 0.5, state: OPEN},
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			events[i%2].Wager("user1", 100, time.Unix(int64(i), 0), true)
+		}(i)
+	}
+	wg.Wait()
+	u1, _ := c.GetUser("user1")
+	if _, inBets, _ := u1.Balance(); inBets != 300 {
+		t.Errorf("user1 has %d in bets across concurrent wagers, want 300 (3 open bets)", inBets)
+	}
+}
+
+func TestItemWagerSameSecond(t *testing.T) {
+	d := db.Fake()
+	c := core.New(d, &FakeSession{}, nil)
+	e := &ItemEvent{ID: "item", c: c, prob: 0.5, state: OPEN}
+	placed := time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := e.Wager("user1", 100, placed, true); err != nil {
+		t.Fatalf("Wager() returned unexpected error: %v", err)
+	}
+	// Bets are stored to the second, so a second bet within it is refused
+	// before anything is reserved.
+	if _, err := e.Wager("user1", 200, placed.Add(500*time.Millisecond), false); !errors.Is(err, core.DuplicateBetError{}) {
+		t.Errorf("Wager() in the same second = %v, want DuplicateBetError", err)
+	}
+	u1, _ := c.GetUser("user1")
+	if _, inBets, _ := u1.Balance(); inBets != 100 {
+		t.Errorf("user1 has %d in bets, want 100 from the first bet", inBets)
+	}
+	if _, err := e.Wager("user1", 200, placed.Add(time.Second), false); err != nil {
+		t.Errorf("Wager() a second later returned unexpected error: %v", err)
+	}
+}
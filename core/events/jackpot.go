@@ -0,0 +1,65 @@
+package events
+
+import (
+	"bet/core"
+	"bet/core/db"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// settleJackpot adds the rake to the jackpot, then pays out the whole jackpot
+// to the winning bets on the exact phase, split by the amount bet.  The
+// jackpot rolls over when no exact bet won.  Returns each winning bet's share
+// of the jackpot, and a line to add to the closing message.
+func settleJackpot(c *core.Core, tx db.Transaction, eid string, jackpot, rake int, bets []*internalPhaseBet, phase int, refundAll bool, userDelta map[string]int) (map[*internalPhaseBet]int, string) {
+	shares := make(map[*internalPhaseBet]int)
+	now := time.Now()
+	if rake > 0 {
+		if err := tx.WriteJackpot(eid, now, rake, db.JackpotRake); err != nil {
+			slog.Warn(fmt.Sprintf("could not add rake to the jackpot: %v", err))
+		} else {
+			jackpot += rake
+		}
+	}
+	if jackpot <= 0 {
+		return shares, ""
+	}
+	winners := make([]*internalPhaseBet, 0)
+	var winnerTotal int
+	if !refundAll {
+		for _, b := range bets {
+			if b.bet.Direction == EQUAL && b.won(phase) {
+				winners = append(winners, b)
+				winnerTotal += b.amount
+			}
+		}
+	}
+	if len(winners) == 0 {
+		if err := tx.WriteJackpot(eid, now, 0, db.JackpotRollover); err != nil {
+			slog.Warn(fmt.Sprintf("could not record jackpot rollover: %v", err))
+		}
+		return shares, fmt.Sprintf("\nNobody hit the exact phase, the jackpot of %d cakes rolls over.", jackpot)
+	}
+	// Shares are rounded down, and whatever is left over stays in the jackpot.
+	var paid int
+	for _, b := range winners {
+		amount := jackpot * b.amount / winnerTotal
+		user, err := c.GetUser(b.uid)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("could not load user %s to pay jackpot: %v", b.uid, err))
+			continue
+		}
+		if _, err := user.Payout(tx, amount); err != nil {
+			slog.Warn(fmt.Sprintf("could not pay jackpot: %v", err))
+			continue
+		}
+		shares[b] = amount
+		userDelta[b.uid] += amount
+		paid += amount
+	}
+	if err := tx.WriteJackpot(eid, now, -paid, db.JackpotWin); err != nil {
+		slog.Warn(fmt.Sprintf("could not record jackpot payout: %v", err))
+	}
+	return shares, fmt.Sprintf("\nJackpot! %d cakes go to the exact phase bets.", paid)
+}
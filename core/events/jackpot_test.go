@@ -0,0 +1,117 @@
+package events
+
+import (
+	"bet/core"
+	"bet/core/db"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCalculatePayoutRake(t *testing.T) {
+	bets := []*internalPhaseBet{
+		{uid: "user1", amount: 100, risk: 0.5, bet: PhaseBet{Direction: LESS, Phase: 5}},
+		{uid: "user2", amount: 55, risk: 0.5, bet: PhaseBet{Direction: GREATER, Phase: 5}},
+	}
+	payout, rake, _, _ := calculatePayout(bets, 3, 10)
+	if payout != 50 || rake != 5 {
+		t.Errorf("calculatePayout(...) payout, rake = %d, %d, want 50, 5", payout, rake)
+	}
+	// Everything is refunded when nobody wins, so there's nothing to rake.
+	payout, rake, _, _ = calculatePayout(bets, 5, 10)
+	if payout != 155 || rake != 0 {
+		t.Errorf("calculatePayout(...) with no winners payout, rake = %d, %d, want 155, 0", payout, rake)
+	}
+}
+
+func TestPhaseResolveJackpot(t *testing.T) {
+	d := db.Fake()
+	s := &FakeSession{}
+	c := core.New(d, s, nil)
+	l := phaseLifecycle{
+		eventId:     "test",
+		probability: 0.5,
+		rakePercent: 10,
+		core:        c,
+		channel:     "not empty",
+		state:       OPEN,
+	}
+	betTime := time.Date(2020, time.January, 2, 0, 0, 0, 0, time.UTC)
+	closeTime := time.Date(2020, time.January, 2, 0, 0, 1, 0, time.UTC)
+
+	// Nobody hits the exact phase, so the rake rolls over.
+	l.Wager("user1", 100, betTime, PhaseBet{Direction: LESS, Phase: 5})
+	l.Wager("user3", 100, betTime, PhaseBet{Direction: GREATER, Phase: 4})
+	l.Wager("user2", 10, betTime, PhaseBet{Direction: EQUAL, Phase: 2})
+	l.Update(3)
+	if err := l.Close(closeTime); err != nil {
+		t.Fatalf("Close() returned unexpected error: %v", err)
+	}
+	if err := l.Resolve(); err != nil {
+		t.Fatalf("Resolve() returned unexpected error: %v", err)
+	}
+	if pool, _ := d.Jackpot(); pool != 11 {
+		t.Errorf("jackpot after first resolve = %d, want 11", pool)
+	}
+	if len(s.Sent) == 0 || !strings.Contains(s.Sent[len(s.Sent)-1], "rolls over") {
+		t.Errorf("first resolve message doesn't mention the rollover: %v", s.Sent)
+	}
+	// user1 wins the 99 cakes left after the rake.
+	u1, _ := c.GetUser("user1")
+	if bal, _, _ := u1.Balance(); bal != 1099 {
+		t.Errorf("user1 has %d balance, want 1099", bal)
+	}
+}
+
+func TestPhaseResolveJackpotWin(t *testing.T) {
+	d := db.Fake()
+	s := &FakeSession{}
+	c := core.New(d, s, nil)
+	// The jackpot has rolled over from earlier events.
+	tx, _ := d.OpenTransaction()
+	tx.WriteJackpot("test", time.Now(), 11, db.JackpotRake)
+	tx.Commit()
+	l := phaseLifecycle{
+		eventId:     "test",
+		probability: 0.5,
+		rakePercent: 10,
+		core:        c,
+		channel:     "not empty",
+		state:       OPEN,
+	}
+	betTime := time.Date(2020, time.January, 2, 0, 0, 0, 0, time.UTC)
+
+	// user2 and user3 hit the exact phase and split the whole jackpot.
+	l.Wager("user2", 30, betTime, PhaseBet{Direction: EQUAL, Phase: 3})
+	l.Wager("user3", 10, betTime, PhaseBet{Direction: EQUAL, Phase: 3})
+	l.Wager("user1", 100, betTime, PhaseBet{Direction: LESS, Phase: 2})
+	l.Update(3)
+	if err := l.Close(time.Date(2020, time.January, 2, 0, 0, 1, 0, time.UTC)); err != nil {
+		t.Fatalf("Close() returned unexpected error: %v", err)
+	}
+	if err := l.Resolve(); err != nil {
+		t.Fatalf("Resolve() returned unexpected error: %v", err)
+	}
+	// The jackpot is 21 with this event's rake, split 15/5 by amount bet with
+	// the cake left over staying in the jackpot.
+	if pool, _ := d.Jackpot(); pool != 1 {
+		t.Errorf("jackpot after exact win = %d, want 1", pool)
+	}
+	// Both have the same risk, so split the 90 left of user1's stake 68/23
+	// (rounded up).
+	for _, tc := range []struct {
+		uid  string
+		want int
+	}{
+		{uid: "user2", want: 1000 + 68 + 15},
+		{uid: "user3", want: 1000 + 23 + 5},
+	} {
+		u, _ := c.GetUser(tc.uid)
+		if bal, _, _ := u.Balance(); bal != tc.want {
+			t.Errorf("%s has %d balance, want %d", tc.uid, bal, tc.want)
+		}
+	}
+	if !strings.Contains(s.Sent[len(s.Sent)-1], "Jackpot! 20 cakes") {
+		t.Errorf("resolve message doesn't announce the jackpot: %v", s.Sent[len(s.Sent)-1])
+	}
+}
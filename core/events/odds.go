@@ -0,0 +1,82 @@
+package events
+
+import (
+	"bet/core/db"
+	"bet/env"
+	"fmt"
+	"log/slog"
+)
+
+// OddsModel estimates the per-encounter probability of a phase event, which is
+// used to price the risk of bets.
+type OddsModel interface {
+	// Probability estimates the per-encounter probability, given the
+	// configured probability and the encounters so far in the current phase.
+	Probability(configured float64, current int) float64
+	// Observe records a completed phase of the given length.
+	Observe(length int)
+}
+
+// FixedOdds always uses the configured probability.
+type FixedOdds struct{}
+
+func (FixedOdds) Probability(configured float64, _ int) float64 {
+	return configured
+}
+
+func (FixedOdds) Observe(int) {}
+
+// EmpiricalOdds estimates the probability from the recorded phase history.
+//
+// Each encounter is treated as a trial with an unknown probability p, with a
+// Beta prior centred on the configured probability and worth priorPhases
+// phases of evidence.  Every completed phase is one success and length-1
+// failures, and the current phase adds its encounters as failures since it
+// hasn't ended yet.  The estimate is the mean of the Beta posterior.
+type EmpiricalOdds struct {
+	priorPhases float64
+	// Totals over the completed phases.
+	phases     int
+	encounters int
+}
+
+func NewEmpiricalOdds(priorPhases float64, phases, encounters int) *EmpiricalOdds {
+	if priorPhases <= 0 {
+		priorPhases = 1
+	}
+	return &EmpiricalOdds{priorPhases: priorPhases, phases: phases, encounters: encounters}
+}
+
+func (e *EmpiricalOdds) Probability(configured float64, current int) float64 {
+	// A Beta(a, b) prior with mean configured, where a+b = priorPhases/configured
+	// so the prior is worth priorPhases phases of the expected length.
+	alpha := e.priorPhases
+	beta := e.priorPhases * (1/configured - 1)
+	successes := float64(e.phases)
+	failures := float64(e.encounters-e.phases) + float64(current)
+	return (alpha + successes) / (alpha + beta + successes + failures)
+}
+
+func (e *EmpiricalOdds) Observe(length int) {
+	e.phases++
+	e.encounters += length
+}
+
+// newOddsModel creates the odds model picked by the config, loading the phase
+// history of the event if it's needed.  Falls back to fixed odds when the
+// config is invalid or the history can't be loaded.
+func newOddsModel(d db.Database, eid string, conf env.OddsModelConfig) OddsModel {
+	switch conf.Model {
+	case "", "fixed":
+		return FixedOdds{}
+	case "empirical":
+		phases, encounters, err := d.PhaseHistory(eid)
+		if err != nil {
+			slog.Error(fmt.Sprintf("could not load %s phase history, using fixed odds: %v", eid, err))
+			return FixedOdds{}
+		}
+		return NewEmpiricalOdds(conf.PriorPhases, phases, encounters)
+	}
+	slog.Error(fmt.Sprintf("unknown odds model %q for %s, using fixed odds", conf.Model, eid))
+	return FixedOdds{}
+}
@@ -0,0 +1,201 @@
+package events
+
+import (
+	"bet/core"
+	"bet/core/db"
+	"bet/env"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestFixedOdds(t *testing.T) {
+	o := FixedOdds{}
+	o.Observe(10)
+	if got := o.Probability(0.25, 100); got != 0.25 {
+		t.Errorf("Probability(0.25, 100) = %v, want 0.25", got)
+	}
+}
+
+func TestEmpiricalOdds(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		priorPhases float64
+		phases      int
+		encounters  int
+		current     int
+		want        float64
+	}{
+		{
+			name:        "no history is the prior",
+			priorPhases: 1,
+			want:        0.01,
+		},
+		{
+			name:        "empty prior weight defaults to 1",
+			priorPhases: 0,
+			want:        0.01,
+		},
+		{
+			// Beta(1, 99) prior, 3 successes and 147 failures.
+			name:        "short phases raise the odds",
+			priorPhases: 1,
+			phases:      3,
+			encounters:  150,
+			want:        4.0 / 250.0,
+		},
+		{
+			name:        "current phase counts as failures",
+			priorPhases: 1,
+			phases:      3,
+			encounters:  150,
+			current:     50,
+			want:        4.0 / 300.0,
+		},
+		{
+			// Beta(1, 99) prior, 1 success and 999 failures.
+			name:        "long phases lower the odds",
+			priorPhases: 1,
+			phases:      1,
+			encounters:  1000,
+			want:        2.0 / 1100.0,
+		},
+		{
+			// Beta(10, 990) prior, 1 success and 999 failures.
+			name:        "stronger prior moves less",
+			priorPhases: 10,
+			phases:      1,
+			encounters:  1000,
+			want:        11.0 / 2000.0,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			o := NewEmpiricalOdds(tc.priorPhases, tc.phases, tc.encounters)
+			if got := o.Probability(0.01, tc.current); math.Abs(got-tc.want) > 1e-12 {
+				t.Errorf("Probability(0.01, %d) = %v, want %v", tc.current, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEmpiricalOddsObserve(t *testing.T) {
+	o := NewEmpiricalOdds(1, 0, 0)
+	o.Observe(50)
+	o.Observe(150)
+	// Beta(1, 99) prior, 2 successes and 198 failures.
+	if got, want := o.Probability(0.01, 0), 3.0/300.0; math.Abs(got-want) > 1e-12 {
+		t.Errorf("Probability(0.01, 0) after observing = %v, want %v", got, want)
+	}
+}
+
+func TestOddsModelPricing(t *testing.T) {
+	c := core.New(db.Fake(), &FakeSession{}, nil)
+	fixed := &phaseLifecycle{
+		eventId:     "fixed",
+		probability: 0.01,
+		odds:        FixedOdds{},
+		core:        c,
+		state:       OPEN,
+	}
+	// History of phases much shorter than the configured odds expect.
+	empirical := &phaseLifecycle{
+		eventId:     "empirical",
+		probability: 0.01,
+		odds:        NewEmpiricalOdds(1, 10, 200),
+		core:        c,
+		state:       OPEN,
+	}
+	for _, bet := range []PhaseBet{
+		{Direction: LESS, Phase: 50},
+		{Direction: GREATER, Phase: 50},
+		{Direction: EQUAL, Phase: 50},
+	} {
+		fixedRisk, err := fixed.risk(bet)
+		if err != nil {
+			t.Fatalf("fixed risk(%+v) returned unexpected error: %v", bet, err)
+		}
+		empiricalRisk, err := empirical.risk(bet)
+		if err != nil {
+			t.Fatalf("empirical risk(%+v) returned unexpected error: %v", bet, err)
+		}
+		switch bet.Direction {
+		case LESS:
+			// Shinies are coming sooner than expected, so short phases are
+			// less risky.
+			if empiricalRisk >= fixedRisk {
+				t.Errorf("empirical risk of %+v = %v, want less than fixed risk %v", bet, empiricalRisk, fixedRisk)
+			}
+		default:
+			if empiricalRisk <= fixedRisk {
+				t.Errorf("empirical risk of %+v = %v, want more than fixed risk %v", bet, empiricalRisk, fixedRisk)
+			}
+		}
+	}
+	// p = 11/300, so risk(<50) = (1-p)^49.
+	got, _ := empirical.risk(PhaseBet{Direction: LESS, Phase: 50})
+	if want := math.Pow(1-11.0/300.0, 49); math.Abs(got-want) > 1e-12 {
+		t.Errorf("empirical risk(<50) = %v, want %v", got, want)
+	}
+	// The fixed model prices exactly as before models existed.
+	got, _ = fixed.risk(PhaseBet{Direction: LESS, Phase: 50})
+	if want := math.Pow(0.99, 49); math.Abs(got-want) > 1e-12 {
+		t.Errorf("fixed risk(<50) = %v, want %v", got, want)
+	}
+}
+
+func TestPhaseResolveRecordsHistory(t *testing.T) {
+	d := db.Fake()
+	c := core.New(d, &FakeSession{}, nil)
+	l := &phaseLifecycle{
+		eventId:     "test",
+		probability: 0.01,
+		odds:        newOddsModel(d, "test", env.OddsModelConfig{Model: "empirical", PriorPhases: 1}),
+		core:        c,
+		state:       OPEN,
+	}
+	before := l.Probability()
+	// Nobody wins, so the bets are refunded and the phase isn't recorded.
+	l.Wager("user", 100, time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC), PhaseBet{Direction: GREATER, Phase: 50})
+	l.Update(10)
+	if err := l.Close(time.Date(2020, time.January, 1, 1, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("Close() returned unexpected error: %v", err)
+	}
+	if err := l.Resolve(); err != nil {
+		t.Fatalf("Resolve() returned unexpected error: %v", err)
+	}
+	if phases, _, _ := d.PhaseHistory("test"); phases != 0 {
+		t.Errorf("PhaseHistory(test) after a refund = %d phases, want 0", phases)
+	}
+	if err := l.Open(time.Date(2020, time.January, 1, 2, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("Open() returned unexpected error: %v", err)
+	}
+	if got := l.Probability(); got != before {
+		t.Errorf("Probability() after a refund = %v, want it unchanged at %v", got, before)
+	}
+	l.Wager("user", 100, time.Date(2020, time.January, 1, 3, 0, 0, 0, time.UTC), PhaseBet{Direction: LESS, Phase: 50})
+	l.Update(10)
+	if err := l.Close(time.Date(2020, time.January, 2, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("Close() returned unexpected error: %v", err)
+	}
+	if err := l.Resolve(); err != nil {
+		t.Fatalf("Resolve() returned unexpected error: %v", err)
+	}
+	if phases, encounters, _ := d.PhaseHistory("test"); phases != 1 || encounters != 10 {
+		t.Errorf("PhaseHistory(test) after resolve = %d,%d, want 1,10", phases, encounters)
+	}
+	if err := l.Open(time.Date(2020, time.January, 2, 0, 0, 1, 0, time.UTC)); err != nil {
+		t.Fatalf("Open() returned unexpected error: %v", err)
+	}
+	// Beta(1, 99) prior, 1 success and 9 failures.
+	if got, want := l.Probability(), 2.0/110.0; math.Abs(got-want) > 1e-12 {
+		t.Errorf("Probability() after a short phase = %v (was %v), want %v", got, before, want)
+	}
+	// A model loaded from the recorded history agrees.
+	loaded := newOddsModel(d, "test", env.OddsModelConfig{Model: "empirical", PriorPhases: 1})
+	if got, want := loaded.Probability(0.01, 0), 2.0/110.0; math.Abs(got-want) > 1e-12 {
+		t.Errorf("loaded model Probability() = %v, want %v", got, want)
+	}
+	if _, ok := newOddsModel(d, "test", env.OddsModelConfig{Model: "nope"}).(FixedOdds); !ok {
+		t.Errorf("unknown model didn't fall back to fixed odds")
+	}
+}
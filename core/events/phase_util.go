@@ -6,6 +6,7 @@ package events
 import (
 	"bet/core"
 	"bet/core/db"
+	"bet/env"
 	"fmt"
 	"log/slog"
 	"math"
@@ -56,6 +57,9 @@ const (
 type PhaseBet struct {
 	Direction int
 	Phase     int
+	// Probability is the per-encounter probability in force when the bet was
+	// placed.  Wager fills it in, so callers don't need to provide it.
+	Probability float64
 }
 
 // PlacedPhaseBet is the return from Wager(), that can be used to send a
@@ -63,6 +67,9 @@ type PhaseBet struct {
 type PlacedPhaseBet struct {
 	Amount int
 	Risk   float64
+	// Payout is what the bank pays on top of the stake if the bet wins, when
+	// the event pays out at fixed odds.
+	Payout int
 }
 
 // Creates a bet from a string loaded from storage.
@@ -83,12 +90,28 @@ func phaseBetFrom(str string) PhaseBet {
 		return ret
 	}
 	ret.Phase = phase
+	// Bets placed before the probability was stored don't have it.
+	if len(parts) > 2 {
+		prob, err := strconv.ParseFloat(parts[2], 64)
+		if err != nil {
+			return ret
+		}
+		ret.Probability = prob
+	}
 	return ret
 }
 
 // Creates a string suitable for storing this bet.
 func (b PhaseBet) storage() string {
-	return fmt.Sprintf("%d,%d", b.Direction, b.Phase)
+	if b.Probability == 0 {
+		return fmt.Sprintf("%d,%d", b.Direction, b.Phase)
+	}
+	return fmt.Sprintf("%d,%d,%g", b.Direction, b.Phase, b.Probability)
+}
+
+// String describes the bet the same way Interpret does.
+func (b PhaseBet) String() string {
+	return interpretPhaseBet(b)
 }
 
 func interpretPhaseBet(bet PhaseBet) string {
@@ -109,6 +132,17 @@ type internalPhaseBet struct {
 	bet    PhaseBet
 	risk   float64
 	uid    string
+	placed time.Time
+	// The fraction of the bet's weight in the payout lost for being placed
+	// late in the phase.
+	decay float64
+}
+
+func (b *internalPhaseBet) placedAt() time.Time { return b.placed }
+
+// weight is the bet's share of the winner's total weight, if it wins.
+func (b *internalPhaseBet) weight() float64 {
+	return float64(b.amount) * b.risk * (1 - b.decay)
 }
 
 // phaseLifecycle implements lifecycle management methods (Open, Update, and
@@ -118,8 +152,25 @@ type phaseLifecycle struct {
 	eventId string
 	// A name to display to users
 	displayName string
-	// The probability of the betting event occurring at each encounter.
+	// The configured probability of the betting event occurring at each
+	// encounter.
 	probability float64
+	// The model estimating the probability bets are priced with.  Fixed odds
+	// are used when nil.
+	odds OddsModel
+	// The bank paying out winners at fixed odds.  Winners split the losers'
+	// stakes when nil.
+	bank *bank
+	// The percentage of the losing stakes taken into the jackpot.
+	rakePercent float64
+	// Bets on a phase within lockoutEncounters of the current phase are
+	// rejected, and bets placed within lockoutBefore of the event closing are
+	// refunded.
+	lockoutEncounters int
+	lockoutBefore     time.Duration
+	// The fraction of a bet's weight in the payout lost by placing it at the
+	// very end of the phase.  Bets placed right at open keep all their weight.
+	riskDecay float64
 	// A reference to the Core to use for user and database commands.
 	core *core.Core
 	// The Discord channel to send a message in
@@ -129,6 +180,8 @@ type phaseLifecycle struct {
 	mu      sync.Mutex
 	state   EventState
 	current int
+	opened  time.Time
+	closed  time.Time
 }
 
 // Open updates the database for the open time and resets state for tracking the
@@ -142,6 +195,7 @@ func (p *phaseLifecycle) Open(open time.Time) error {
 		return err
 	}
 	p.current = 0
+	p.opened = open
 	return nil
 }
 
@@ -161,11 +215,55 @@ func (p *phaseLifecycle) Current() int {
 	return p.current
 }
 
+// Probability returns the per-encounter probability currently used to compute
+// the risk of new bets.
+func (p *phaseLifecycle) Probability() float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.estimate()
+}
+
+// estimate returns the probability the odds model prices bets with.  The
+// caller must hold p.mu.
+func (p *phaseLifecycle) estimate() float64 {
+	if p.odds == nil {
+		return p.probability
+	}
+	return p.odds.Probability(p.probability, p.current)
+}
+
+// SetProbability changes the configured per-encounter probability, e.g. when
+// the hunting method changes.  Bets already placed keep the risk they were
+// placed with.
+func (p *phaseLifecycle) SetProbability(probability float64) error {
+	if probability <= 0 || probability >= 1 {
+		return fmt.Errorf("probability must be between 0 and 1, got %v", probability)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.probability = probability
+	return nil
+}
+
+// oddsProbability returns the probability described by the config, falling back
+// to the default odds if the config is invalid.
+func oddsProbability(conf env.OddsConfig) float64 {
+	probability, err := conf.Probability()
+	if err != nil {
+		slog.Error(fmt.Sprintf("invalid odds config, using default odds: %v", err))
+		return 1.0 / env.DefaultOddsBase
+	}
+	return probability
+}
+
 func (p *phaseLifecycle) Close(close time.Time) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	var err error
 	p.state, err = commonClose(p.core.Database, p.eventId, close, p.state)
+	if err == nil {
+		p.closed = close
+	}
 	return err
 }
 
@@ -188,38 +286,98 @@ func (p *phaseLifecycle) Resolve() error {
 	if err != nil {
 		return err
 	}
+	bets, late := splitLate(bets, p.closed, p.lockoutBefore)
+	// The bank pays fixed odds, so there's no pool for the weights to split.
+	if p.bank == nil {
+		decayBets(bets, p.opened, p.closed, p.riskDecay)
+	}
+	// Reading from the database while the transaction is open would block, so
+	// load the jackpot first.
+	jackpot, err := p.core.Database.Jackpot()
+	if err != nil {
+		return err
+	}
 	tx, err := p.core.Database.OpenTransaction()
 	if err != nil {
 		return err
 	}
 
 	message := fmt.Sprintf("%s event closed! Phase was %d", p.displayName, p.current)
+	if len(late) > 0 {
+		resolveBets(p.core, tx, late, p.current, true)
+		if p.bank != nil {
+			p.bank.release(p.core, tx, late)
+		}
+		recordPhaseResults(tx, p.eventId, late, p.current, true, nil)
+		message += lateMessage(len(late), p.lockoutBefore)
+	}
 
-	payout, winnerTotal, userContribution := calculatePayout(bets, p.current)
+	// The bank keeps the losing stakes at fixed odds, so there's nothing to
+	// rake.
+	rakePercent := p.rakePercent
+	if p.bank != nil {
+		rakePercent = 0
+	}
+	payout, rake, winnerTotal, userContribution := calculatePayout(bets, p.current, rakePercent)
 	refundAll := false
-	if winnerTotal == 0.0 {
+	// At fixed odds the bank takes the stakes when nobody wins.
+	if p.bank == nil && winnerTotal == 0.0 {
 		slog.Info(fmt.Sprintf("Nobody won the %s event", p.eventId))
 		message += "\nNo winning bets!  No changes to user balances."
 		refundAll = true
 	}
 	userDelta := resolveBets(p.core, tx, bets, p.current, refundAll)
 	slog.Debug(fmt.Sprintf("userDelta after resolveBets: %+v", userDelta))
-	if winnerTotal != 0.0 {
+	share := func(b *internalPhaseBet) int {
+		return int(math.Ceil(float64(payout) * b.weight() / winnerTotal))
+	}
+	if p.bank != nil {
+		userDelta = p.bank.settle(p.core, tx, bets, p.current, userDelta)
+		share = func(b *internalPhaseBet) int {
+			return fixedOddsPayout(b.amount, b.risk)
+		}
+	} else if winnerTotal != 0.0 {
 		userDelta = distributePayout(p.core, tx, payout, winnerTotal, userContribution, userDelta)
 	}
 	slog.Debug(fmt.Sprintf("userDelta after distributePayout: %+v", userDelta))
+	jackpotShares, jackpotMessage := settleJackpot(p.core, tx, p.eventId, jackpot, rake, bets, p.current, refundAll, userDelta)
+	message += jackpotMessage
+	winnings := func(b *internalPhaseBet) int {
+		return share(b) + jackpotShares[b]
+	}
+	recordPhaseResults(tx, p.eventId, bets, p.current, refundAll, winnings)
+	unlocks := make([]core.Unlock, 0)
+	// A refunded phase isn't recorded, so it doesn't feed the odds model.
+	if !refundAll {
+		if err := tx.WritePhase(p.eventId, time.Now(), p.current); err != nil {
+			slog.Warn(fmt.Sprintf("could not record %s phase history: %v", p.eventId, err))
+		}
+		unlocks = awardPhaseAchievements(p.core, tx, bets, p.current)
+	}
 	if err := tx.Commit(); err != nil {
 		return err
 	}
+	if p.odds != nil && !refundAll {
+		p.odds.Observe(p.current)
+	}
+	// Interest is charged in its own transaction, so a failure here doesn't
+	// undo the payouts.
+	defaults, err := p.core.ChargeInterest()
+	if err != nil {
+		slog.Warn(fmt.Sprintf("could not charge loan interest: %v", err))
+	}
+	message += core.DefaultsMessage(defaults)
 
 	// In a separate transaction, refresh balances of people who got too low, so
 	// they can continue to play.
-	if err := p.core.RefreshBalance(); err != nil {
+	bailouts, err := p.core.RefreshBalance()
+	if err != nil {
 		return err
 	}
+	unlocks = append(unlocks, bailouts...)
 
 	if p.channel != "" {
-		sendMessage(p.core, p.channel, message, userDelta)
+		sendMessage(p.core, p.channel, message, userDelta, unlocks)
 	}
 	p.state = CLOSED
 	return nil
@@ -234,7 +392,6 @@ func loadPhaseBets(d db.Database, eid string) ([]*internalPhaseBet, error) {
 	for rows.Next() {
 		var uid string
 		var eid string
-		// ignored
 		var placed string
 		var amount int
 		var risk float64
@@ -243,20 +400,41 @@ func loadPhaseBets(d db.Database, eid string) ([]*internalPhaseBet, error) {
 			slog.Warn(fmt.Sprintf("unable to scan bet row: %s", err))
 			continue
 		}
+		placedTs, err := time.Parse(time.DateTime, placed)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("unable to parse bet placed time %s: %v", placed, err))
+		}
 		bs = append(bs, &internalPhaseBet{
 			amount: amount,
 			bet:    phaseBetFrom(bet),
 			risk:   risk,
 			uid:    uid,
+			placed: placedTs,
 		})
 
 	}
 	return bs, nil
 }
 
-// Returns the payout, the winner's total weight, and a map from user to weight
-// contributed to the winner's total weight.
-func calculatePayout(bets []*internalPhaseBet, phase int) (int, float64, map[string]float64) {
+// decayBets reduces the weight of bets by how late in the phase they were
+// placed, so early conviction pays more than late sniping.  The decay grows
+// linearly from nothing at open to riskDecay at close.
+func decayBets(bets []*internalPhaseBet, opened time.Time, closed time.Time, riskDecay float64) {
+	length := closed.Sub(opened)
+	if riskDecay <= 0 || length <= 0 {
+		return
+	}
+	for _, b := range bets {
+		elapsed := float64(b.placed.Sub(opened)) / float64(length)
+		b.decay = riskDecay * math.Max(0, math.Min(1, elapsed))
+	}
+}
+
+// Returns the payout, the rake taken from it for the jackpot, the winner's
+// total weight, and a map from user to weight contributed to the winner's total
+// weight.  Nothing is raked when there are no winners, since every bet is
+// refunded.
+func calculatePayout(bets []*internalPhaseBet, phase int, rakePercent float64) (int, int, float64, map[string]float64) {
 	var payout int
 	var winnerTotal float64
 	userContribution := make(map[string]float64)
@@ -264,7 +442,7 @@ func calculatePayout(bets []*internalPhaseBet, phase int) (int, float64, map[str
 		switch b.bet.Direction {
 		case LESS:
 			if phase < b.bet.Phase {
-				contribution := float64(b.amount) * b.risk
+				contribution := b.weight()
 				userContribution[b.uid] += contribution
 				winnerTotal += contribution
 			} else {
@@ -272,7 +450,7 @@ func calculatePayout(bets []*internalPhaseBet, phase int) (int, float64, map[str
 			}
 		case GREATER:
 			if phase > b.bet.Phase {
-				contribution := float64(b.amount) * b.risk
+				contribution := b.weight()
 				userContribution[b.uid] += contribution
 				winnerTotal += contribution
 			} else {
@@ -280,7 +458,7 @@ func calculatePayout(bets []*internalPhaseBet, phase int) (int, float64, map[str
 			}
 		case EQUAL:
 			if phase == b.bet.Phase {
-				contribution := float64(b.amount) * b.risk
+				contribution := b.weight()
 				userContribution[b.uid] += contribution
 				winnerTotal += contribution
 			} else {
@@ -288,7 +466,12 @@ func calculatePayout(bets []*internalPhaseBet, phase int) (int, float64, map[str
 			}
 		}
 	}
-	return payout, winnerTotal, userContribution
+	var rake int
+	if winnerTotal != 0.0 {
+		rake = int(math.Floor(float64(payout) * rakePercent / 100))
+		payout -= rake
+	}
+	return payout, rake, winnerTotal, userContribution
 }
 
 // Resolves the bets and returns a map of user ids to losses to be used in the
@@ -339,20 +522,83 @@ func distributePayout(c *core.Core, tx db.Transaction, payout int, winnerTotal f
 		}
 		amount := int(math.Ceil(fPayout * contribution / winnerTotal))
 		userDelta[uid] += amount
-		if err := user.Earn(tx, amount); err != nil {
+		if _, err := user.Payout(tx, amount); err != nil {
 			slog.Warn(fmt.Sprintf("error distributing payout: %s", err))
 		}
 	}
 	return userDelta
 }
 
-func sendMessage(c *core.Core, channel string, message string, userDelta map[string]int) {
+// won returns whether the bet won, given the phase the event ended on.
+func (b *internalPhaseBet) won(phase int) bool {
+	switch b.bet.Direction {
+	case LESS:
+		return phase < b.bet.Phase
+	case GREATER:
+		return phase > b.bet.Phase
+	case EQUAL:
+		return phase == b.bet.Phase
+	}
+	return false
+}
+
+// Records the outcome of every bet for lifetime stats.  The payout of each
+// winning bet is given by winnings.  For pari-mutuel payouts this is its share
+// of the pool, which can be off by a cake from what the user was actually paid
+// across all their bets.
+func recordPhaseResults(tx db.Transaction, eid string, bets []*internalPhaseBet, phase int, refundAll bool, winnings func(*internalPhaseBet) int) {
+	resolved := time.Now()
+	for _, b := range bets {
+		outcome := db.OutcomeLoss
+		net := -b.amount
+		if refundAll {
+			outcome = db.OutcomeRefund
+			net = 0
+		} else if b.won(phase) {
+			outcome = db.OutcomeWin
+			net = winnings(b)
+		}
+		if err := tx.WriteResult(b.uid, eid, b.placed, resolved, outcome, net); err != nil {
+			slog.Warn(fmt.Sprintf("could not record bet result: %v", err))
+		}
+	}
+}
+
+// Awards achievements for winning bets as part of the resolve transaction, and
+// returns the new unlocks.
+func awardPhaseAchievements(c *core.Core, tx db.Transaction, bets []*internalPhaseBet, phase int) []core.Unlock {
+	unlocks := make([]core.Unlock, 0)
+	award := func(uid, id string) {
+		unlock, err := c.Award(tx, uid, id)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("could not award %s achievement: %v", id, err))
+			return
+		}
+		if unlock != nil {
+			unlocks = append(unlocks, *unlock)
+		}
+	}
+	for _, b := range bets {
+		if !b.won(phase) {
+			continue
+		}
+		if b.bet.Direction == EQUAL {
+			award(b.uid, core.AchievementExact)
+		}
+		if b.risk > core.LongshotRisk {
+			award(b.uid, core.AchievementLongshot)
+		}
+	}
+	return unlocks
+}
+
+func sendMessage(c *core.Core, channel string, message string, userDelta map[string]int, unlocks []core.Unlock) {
 	// Collect user deltas into an array for sorting
 	deltas := sortedDeltas(userDelta)
 	if len(deltas) > 0 {
 		message += "\nNet cake gains/losses:"
 	}
-	for i, d := range deltas {
+	for _, d := range deltas {
 		user, err := c.GetUser(d.uid)
 		if err != nil {
 			slog.Warn(fmt.Sprintf("error getting user while making message: %s", err))
@@ -362,17 +608,10 @@ func sendMessage(c *core.Core, channel string, message string, userDelta map[str
 		if err != nil {
 			slog.Warn(fmt.Sprintf("error getting users balance: %s", err))
 		}
-		nextDelta := fmt.Sprintf("\n * %s (new balance %d cakes)", d, balance)
-		if len(message)+len(nextDelta) >= 1975 {
-			// The message will be too long for Discord, so cut it off here and
-			// add a little suffix.
-			// TODO: Could we print multiple messages instead?  This is the one
-			// place I wouldn't want to compromise.
-			message += fmt.Sprintf("and %d other participants", len(deltas)-i)
-			break
-		}
-		message += nextDelta
+		message += fmt.Sprintf("\n * %s (new balance %d cakes)", d, balance)
 	}
+	message += core.UnlocksMessage(unlocks)
+	// Every participant is listed, even if it takes several messages.
 	if err := c.SendMessage(channel, message); err != nil {
 		// don't make this error block anything
 		slog.Warn(fmt.Sprintf("error sending message on shiny event close: %v", err))
@@ -402,29 +641,68 @@ func (p *phaseLifecycle) Wager(uid string, amount int, placed time.Time, bet any
 	if err != nil {
 		return nil, err
 	}
+	if p.lockoutEncounters > 0 && b.Phase-p.current <= p.lockoutEncounters {
+		return nil, LockoutError{Encounters: p.lockoutEncounters}
+	}
 	if r == 0.0 || r == 1.0 {
 		return nil, NoRiskError{}
 	}
-	user, err := p.core.GetUser(uid)
-	if err != nil {
-		return nil, err
-	}
-	transaction, err := p.core.Database.OpenTransaction()
+	b.Probability = p.estimate()
+	var payout int
+	if p.bank != nil {
+		payout = fixedOddsPayout(amount, r)
+	}
+	unlock, err := placeWager(p.core, p.bank, payout, wager{
+		uid:    uid,
+		eid:    p.eventId,
+		amount: amount,
+		placed: placed,
+		risk:   r,
+		blob:   b.storage(),
+	})
 	if err != nil {
 		return nil, err
 	}
+	announceUnlock(p.core, p.channel, unlock)
+	wagerSuccess.WithLabelValues(p.eventId).Inc()
+	return PlacedPhaseBet{Amount: amount, Risk: r, Payout: payout}, nil
+}
 
-	if err := user.Reserve(transaction, amount); err != nil {
-		return nil, err
-	}
-	if err := transaction.WriteBet(uid, p.eventId, placed, amount, r, b.storage()); err != nil {
-		return nil, err
+func (p *phaseLifecycle) Status() core.EventStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return core.EventStatus{
+		ID:      p.eventId,
+		Name:    p.displayName,
+		State:   core.EventState(p.state.String()),
+		Opened:  p.opened,
+		Current: p.current,
 	}
-	if err := transaction.Commit(); err != nil {
-		return nil, err
+}
+
+// Cancel refunds the bet, and frees the payout the bank reserved for it in
+// fixed-odds mode.
+func (p *phaseLifecycle) Cancel(uid string, placed time.Time, now time.Time) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.state != OPEN {
+		return BettingClosedError{}
 	}
-	wagerSuccess.WithLabelValues(p.eventId).Inc()
-	return PlacedPhaseBet{Amount: amount, Risk: r}, nil
+	return cancelBet(p.core, p.eventId, uid, placed, now, func(tx db.Transaction, b core.Bet) error {
+		if p.bank != nil {
+			p.bank.release(p.core, tx, []*internalPhaseBet{{amount: b.Amount, risk: b.Risk}})
+		}
+		return nil
+	})
+}
+
+// LockoutError is returned for bets on a phase too close to the current phase.
+type LockoutError struct {
+	Encounters int
+}
+
+func (e LockoutError) Error() string {
+	return fmt.Sprintf("predicted phase must be more than %d encounters past the current phase", e.Encounters)
 }
 
 type PhaseLengthError struct {
@@ -439,7 +717,8 @@ func (p *phaseLifecycle) risk(bet PhaseBet) (float64, error) {
 		return 0.0, PhaseLengthError{}
 	}
 	length := float64(bet.Phase - p.current)
-	inverseProb := 1.0 - p.probability
+	probability := p.estimate()
+	inverseProb := 1.0 - probability
 	if bet.Direction == LESS {
 		// risk(<x) = P(>=x) = P(>x-1) = (1-p)^(x-1)
 		return math.Pow(inverseProb, length-1.0), nil
@@ -450,7 +729,7 @@ func (p *phaseLifecycle) risk(bet PhaseBet) (float64, error) {
 	}
 	if bet.Direction == EQUAL {
 		// risk(=x) = 1 - P(=x) = 1 - p*(1-p)^(x-1)
-		return 1.0 - p.probability*math.Pow(inverseProb, length-1.0), nil
+		return 1.0 - probability*math.Pow(inverseProb, length-1.0), nil
 	}
 	return 0.0, fmt.Errorf("unknown direction %d", bet.Direction)
 }
@@ -500,14 +779,7 @@ func (p *phaseLifecycle) BetsSummary(style string) (string, error) {
 		slices.SortFunc(unresolvedBets, sortByUpcoming)
 		message += "\nThe unresolved bets that will resolve next are:"
 	}
-	for i, b := range unresolvedBets {
-		// Discord has a limit of 2000 characters per message.  This limit gives
-		// us some leeway for the last append being oversized, and still enough
-		// room to write a closing message.
-		if len(message) > 1880 {
-			message += fmt.Sprintf("\nAnd %d other bets.", len(unresolvedBets)-i)
-			return message, nil
-		}
+	for _, b := range unresolvedBets {
 		message += fmt.Sprintf("\n * <@%s> placed %d cakes on %s (%.2f%% risk)", b.uid, b.amount, interpretPhaseBet(b.bet), b.risk*100)
 	}
 	return message, nil
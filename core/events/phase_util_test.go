@@ -3,12 +3,12 @@ package events
 import (
 	"bet/core"
 	"bet/core/db"
+	"errors"
+	"fmt"
 	"math"
 	"strings"
 	"testing"
 	"time"
-
-	"github.com/bwmarrin/discordgo"
 )
 
 func TestPhaseBet(t *testing.T) {
@@ -24,6 +24,9 @@ func TestPhaseBet(t *testing.T) {
 		{
 			bet: PhaseBet{Direction: EQUAL, Phase: 24242424242},
 		},
+		{
+			bet: PhaseBet{Direction: LESS, Phase: 100, Probability: 1.0 / 4096.0},
+		},
 	} {
 		got := phaseBetFrom(tc.bet.storage())
 		if got.Direction != tc.bet.Direction {
@@ -32,16 +35,63 @@ func TestPhaseBet(t *testing.T) {
 		if got.Phase != tc.bet.Phase {
 			t.Errorf("store+read phase is %d, want %d", got.Phase, tc.bet.Phase)
 		}
+		if got.Probability != tc.bet.Probability {
+			t.Errorf("store+read probability is %v, want %v", got.Probability, tc.bet.Probability)
+		}
+	}
+}
+
+func TestPhaseSetProbability(t *testing.T) {
+	d := db.Fake()
+	c := core.New(d, &FakeSession{}, nil)
+	l := phaseLifecycle{
+		eventId:     "test",
+		probability: 0.5,
+		core:        c,
+		state:       OPEN,
+	}
+	betTime := time.Date(2020, time.January, 2, 0, 0, 0, 0, time.UTC)
+	before, err := l.Wager("user1", 100, betTime, PhaseBet{Direction: LESS, Phase: 3})
+	if err != nil {
+		t.Fatalf("Wager() returned unexpected error: %v", err)
+	}
+	if err := l.SetProbability(0.1); err != nil {
+		t.Fatalf("SetProbability(0.1) returned unexpected error: %v", err)
+	}
+	after, err := l.Wager("user2", 100, betTime, PhaseBet{Direction: LESS, Phase: 3})
+	if err != nil {
+		t.Fatalf("Wager() returned unexpected error: %v", err)
+	}
+	// risk(<3) = (1-p)^2
+	if r := before.(PlacedPhaseBet).Risk; r != 0.25 {
+		t.Errorf("risk before changing odds = %v, want 0.25", r)
+	}
+	if r := after.(PlacedPhaseBet).Risk; math.Abs(r-0.81) > 1e-9 {
+		t.Errorf("risk after changing odds = %v, want 0.81", r)
+	}
+	bets, err := loadPhaseBets(d, "test")
+	if err != nil {
+		t.Fatalf("loadPhaseBets() returned unexpected error: %v", err)
+	}
+	if len(bets) != 2 || bets[0].bet.Probability != 0.5 || bets[1].bet.Probability != 0.1 {
+		t.Errorf("stored bets don't keep the probability they were placed with: %+v %+v", bets[0].bet, bets[1].bet)
+	}
+	for _, p := range []float64{0, 1, -0.5} {
+		if err := l.SetProbability(p); err == nil {
+			t.Errorf("SetProbability(%v) succeeded, want error", p)
+		}
 	}
 }
 
 type FakeSession struct {
 	SendCount int
+	Sent      []string
 }
 
-func (f *FakeSession) ChannelMessageSendComplex(string, *discordgo.MessageSend, ...discordgo.RequestOption) (*discordgo.Message, error) {
+func (f *FakeSession) Send(_ string, message string) error {
 	f.SendCount++
-	return nil, nil
+	f.Sent = append(f.Sent, message)
+	return nil
 }
 
 func TestPhaseOpen(t *testing.T) {
@@ -170,10 +220,10 @@ func TestPhaseResolve(t *testing.T) {
 	}
 
 	betTime := time.Date(2020, time.January, 2, 0, 0, 0, 0, time.UTC)
-	l.Wager("user1", 100, betTime, PhaseBet{Direction: LESS, Phase: 2})    // loss
-	l.Wager("user1", 100, betTime, PhaseBet{Direction: LESS, Phase: 5})    // risk 0.0625
-	l.Wager("user2", 10, betTime, PhaseBet{Direction: EQUAL, Phase: 3})    // risk 0.875
-	l.Wager("user3", 100, betTime, PhaseBet{Direction: GREATER, Phase: 4}) // loss
+	l.Wager("user1", 100, betTime.Add(-time.Second), PhaseBet{Direction: LESS, Phase: 2}) // loss
+	l.Wager("user1", 100, betTime, PhaseBet{Direction: LESS, Phase: 5})                   // risk 0.0625
+	l.Wager("user2", 10, betTime, PhaseBet{Direction: EQUAL, Phase: 3})                   // risk 0.875
+	l.Wager("user3", 100, betTime, PhaseBet{Direction: GREATER, Phase: 4})                // loss
 
 	l.Update(3)
 	err := l.Close(time.Date(2020, time.January, 2, 0, 0, 1, 0, time.UTC))
@@ -275,6 +325,307 @@ func TestPhaseResolveNoChannel(t *testing.T) {
 	}
 }
 
+func TestPhaseResolveLongMessage(t *testing.T) {
+	d := db.Fake()
+	s := &FakeSession{}
+	c := core.New(d, s, nil)
+	l := phaseLifecycle{
+		eventId:     "test",
+		displayName: "Test",
+		probability: 0.5,
+		core:        c,
+		channel:     "not empty",
+		state:       OPEN,
+		current:     0,
+	}
+	// Enough participants that the deltas can't fit in a single message.
+	betTime := time.Date(2020, time.January, 2, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 100; i++ {
+		l.Wager(fmt.Sprintf("user%d", i), 100, betTime.Add(time.Duration(i)*time.Second), PhaseBet{Direction: LESS, Phase: 2 + i%2})
+	}
+	l.Update(2)
+	l.Close(betTime.Add(time.Hour))
+	if err := l.Resolve(); err != nil {
+		t.Errorf("Resolve() returned unexpected error: %v", err)
+	}
+	if s.SendCount < 2 {
+		t.Errorf("Expected the results to be split over several messages, instead got %d", s.SendCount)
+	}
+	all := strings.Join(s.Sent, "\n")
+	for i := 0; i < 100; i++ {
+		if !strings.Contains(all, fmt.Sprintf("<@user%d> ", i)) {
+			t.Errorf("results did not list user%d", i)
+		}
+	}
+	for _, m := range s.Sent {
+		if len(m) > core.MaxMessageLength {
+			t.Errorf("sent message of length %d, more than the limit %d", len(m), core.MaxMessageLength)
+		}
+	}
+}
+
+func TestPhaseResolveAchievements(t *testing.T) {
+	d := db.Fake()
+	s := &FakeSession{}
+	c := core.New(d, s, nil)
+	l := phaseLifecycle{
+		eventId:     "test",
+		displayName: "Test",
+		probability: 0.5,
+		core:        c,
+		channel:     "not empty",
+		state:       OPEN,
+		current:     0,
+	}
+	betTime := time.Date(2020, time.January, 2, 0, 0, 0, 0, time.UTC)
+	l.Wager("exact", 100, betTime, PhaseBet{Direction: EQUAL, Phase: 3})
+	// A phase over 9 is a 1 in 512 chance, so the bet is well over the
+	// longshot risk.
+	l.Wager("longshot", 100, betTime.Add(time.Second), PhaseBet{Direction: GREATER, Phase: 9})
+	l.Wager("safe", 100, betTime.Add(2*time.Second), PhaseBet{Direction: GREATER, Phase: 1})
+	l.Wager("allin", 1000, betTime.Add(3*time.Second), PhaseBet{Direction: LESS, Phase: 2})
+	if s.SendCount != 1 {
+		t.Errorf("Expected the all in wager to be announced, instead got %d messages", s.SendCount)
+	}
+	l.Update(10)
+	l.Close(betTime.Add(time.Hour))
+	if err := l.Resolve(); err != nil {
+		t.Errorf("Resolve() returned unexpected error: %v", err)
+	}
+	results := s.Sent[len(s.Sent)-1]
+	for _, want := range []string{
+		"<@longshot> unlocked **Longshot**",
+		"<@allin> unlocked **Too Big To Fail**",
+	} {
+		if !strings.Contains(results, want) {
+			t.Errorf("results = %s, wanted unlock like %s", results, want)
+		}
+	}
+	// The exact bet lost, and the safe bet won without much risk.
+	for _, uid := range []string{"exact", "safe"} {
+		if strings.Contains(results, fmt.Sprintf("<@%s> unlocked", uid)) {
+			t.Errorf("results = %s, wanted no unlock for %s", results, uid)
+		}
+	}
+
+	// Bullseye is unlocked by winning an exact bet.
+	l.Open(betTime.Add(2 * time.Hour))
+	l.Wager("exact", 100, betTime.Add(2*time.Hour+time.Second), PhaseBet{Direction: EQUAL, Phase: 3})
+	l.Wager("safe", 100, betTime.Add(2*time.Hour+2*time.Second), PhaseBet{Direction: GREATER, Phase: 1})
+	l.Update(3)
+	l.Close(betTime.Add(3 * time.Hour))
+	if err := l.Resolve(); err != nil {
+		t.Errorf("Resolve() returned unexpected error: %v", err)
+	}
+	if results := s.Sent[len(s.Sent)-1]; !strings.Contains(results, "<@exact> unlocked **Bullseye**") {
+		t.Errorf("results = %s, wanted unlock like <@exact> unlocked **Bullseye**", results)
+	}
+}
+
+func TestPhaseResolveRepaysLoan(t *testing.T) {
+	d := db.Fake()
+	s := &FakeSession{}
+	c := core.New(d, s, nil)
+	c.SetLoans(core.LoanPolicy{Base: 200, Max: 200, InterestPercent: 10})
+	l := phaseLifecycle{
+		eventId:     "test",
+		probability: 0.5,
+		core:        c,
+		channel:     "not empty",
+		state:       OPEN,
+		current:     0,
+	}
+	betTime := time.Date(2020, time.January, 2, 0, 0, 0, 0, time.UTC)
+	if err := c.Borrow("user2", 150, betTime); err != nil {
+		t.Fatalf("Borrow() returned unexpected error: %v", err)
+	}
+	l.Wager("user2", 10, betTime, PhaseBet{Direction: EQUAL, Phase: 3})
+	l.Wager("user3", 100, betTime, PhaseBet{Direction: GREATER, Phase: 4}) // loss
+	l.Update(3)
+	if err := l.Close(time.Date(2020, time.January, 2, 0, 0, 1, 0, time.UTC)); err != nil {
+		t.Errorf("Close() returned unexpected error: %v", err)
+	}
+	if err := l.Resolve(); err != nil {
+		t.Errorf("Resolve() returned unexpected error: %v", err)
+	}
+	// All 100 winnings go to the loan, then 10% interest is charged on the
+	// remaining 50.
+	u2, _ := c.GetUser("user2")
+	if bal2, _, _ := u2.Balance(); bal2 != 1150 {
+		t.Errorf("user2 has %d balance, expected 1150", bal2)
+	}
+	if owed, events, _, _ := c.Loan("user2"); owed != 55 || events != 1 {
+		t.Errorf("user2 owes %d after %d events, expected 55 after 1", owed, events)
+	}
+}
+
+func TestPhaseWagerLockout(t *testing.T) {
+	c := core.New(db.Fake(), &FakeSession{}, nil)
+	l := phaseLifecycle{
+		eventId:           "test",
+		probability:       0.5,
+		core:              c,
+		state:             OPEN,
+		current:           10,
+		lockoutEncounters: 5,
+	}
+	betTime := time.Date(2020, time.January, 2, 0, 0, 0, 0, time.UTC)
+	var lockoutErr LockoutError
+	if _, err := l.Wager("user1", 100, betTime, PhaseBet{Direction: EQUAL, Phase: 15}); !errors.As(err, &lockoutErr) || lockoutErr.Encounters != 5 {
+		t.Errorf("Wager() within the lockout returned %v, want LockoutError{5}", err)
+	}
+	if _, err := l.Wager("user1", 100, betTime, PhaseBet{Direction: EQUAL, Phase: 16}); err != nil {
+		t.Errorf("Wager() past the lockout returned unexpected error: %v", err)
+	}
+}
+
+func TestPhaseCancel(t *testing.T) {
+	c := core.New(db.Fake(), &FakeSession{}, nil)
+	l := phaseLifecycle{
+		eventId:     "test",
+		probability: 0.5,
+		bank:        &bank{uid: "bank"},
+		core:        c,
+		state:       OPEN,
+	}
+	betTime := time.Date(2020, time.January, 2, 0, 0, 0, 0, time.UTC)
+	if _, err := l.Wager("user1", 100, betTime, PhaseBet{Direction: LESS, Phase: 3}); err != nil {
+		t.Fatalf("Wager() returned unexpected error: %v", err)
+	}
+	var windowErr core.CancelWindowError
+	if err := l.Cancel("user1", betTime, betTime); !errors.As(err, &windowErr) {
+		t.Errorf("Cancel() with cancelling disabled returned %v, want CancelWindowError", err)
+	}
+	c.SetCancelWindow(time.Minute)
+	if err := l.Cancel("user1", betTime, betTime.Add(2*time.Minute)); !errors.As(err, &windowErr) {
+		t.Errorf("Cancel() after the window returned %v, want CancelWindowError", err)
+	}
+	if err := l.Cancel("user2", betTime, betTime); !errors.Is(err, core.BetNotFoundError{}) {
+		t.Errorf("Cancel() of another user's bet returned %v, want BetNotFoundError", err)
+	}
+	if err := l.Cancel("user1", betTime, betTime.Add(30*time.Second)); err != nil {
+		t.Fatalf("Cancel() returned unexpected error: %v", err)
+	}
+	for _, uid := range []string{"user1", "bank"} {
+		u, _ := c.GetUser(uid)
+		if balance, inBets, _ := u.Balance(); balance != 1000 || inBets != 0 {
+			t.Errorf("%s has %d balance and %d in bets after cancelling, want 1000 and 0", uid, balance, inBets)
+		}
+	}
+	if err := l.Cancel("user1", betTime, betTime); !errors.Is(err, core.BetNotFoundError{}) {
+		t.Errorf("Cancel() of a cancelled bet returned %v, want BetNotFoundError", err)
+	}
+	if got := l.Status(); got.State != "open" || got.ID != "test" {
+		t.Errorf("Status() = %+v, want the open test event", got)
+	}
+}
+
+func TestPhaseResolveLateBets(t *testing.T) {
+	d := db.Fake()
+	s := &FakeSession{}
+	c := core.New(d, s, nil)
+	l := phaseLifecycle{
+		eventId:       "test",
+		probability:   0.5,
+		core:          c,
+		channel:       "not empty",
+		state:         OPEN,
+		lockoutBefore: 30 * time.Second,
+	}
+	closeTime := time.Date(2020, time.January, 2, 0, 1, 0, 0, time.UTC)
+	l.Wager("user1", 100, closeTime.Add(-time.Minute), PhaseBet{Direction: LESS, Phase: 5})
+	l.Wager("user3", 100, closeTime.Add(-time.Minute), PhaseBet{Direction: GREATER, Phase: 4}) // loss
+	l.Wager("user2", 100, closeTime.Add(-5*time.Second), PhaseBet{Direction: EQUAL, Phase: 3}) // late
+	l.Update(3)
+	if err := l.Close(closeTime); err != nil {
+		t.Errorf("Close() returned unexpected error: %v", err)
+	}
+	if err := l.Resolve(); err != nil {
+		t.Errorf("Resolve() returned unexpected error: %v", err)
+	}
+	// The late bet would have won, but is refunded instead, so user1 takes the
+	// whole payout.
+	for _, tc := range []struct {
+		uid     string
+		balance int
+	}{
+		{uid: "user1", balance: 1100},
+		{uid: "user2", balance: 1000},
+		{uid: "user3", balance: 900},
+	} {
+		u, _ := c.GetUser(tc.uid)
+		balance, inBets, _ := u.Balance()
+		if balance != tc.balance || inBets != 0 {
+			t.Errorf("%s has %d balance and %d in bets, expected %d and 0", tc.uid, balance, inBets, tc.balance)
+		}
+	}
+	if len(s.Sent) != 1 || !strings.Contains(s.Sent[0], "1 bets placed in the last 30s") {
+		t.Errorf("resolve message doesn't mention the late bet: %q", s.Sent)
+	}
+}
+
+func TestPhaseResolveRiskDecay(t *testing.T) {
+	c := core.New(db.Fake(), &FakeSession{}, nil)
+	l := phaseLifecycle{
+		eventId:     "test",
+		probability: 0.5,
+		core:        c,
+		riskDecay:   0.5,
+	}
+	openTime := time.Date(2020, time.January, 2, 0, 0, 0, 0, time.UTC)
+	closeTime := openTime.Add(time.Hour)
+	if err := l.Open(openTime); err != nil {
+		t.Fatalf("Open() returned unexpected error: %v", err)
+	}
+	l.Wager("user1", 100, openTime, PhaseBet{Direction: LESS, Phase: 5})
+	l.Wager("user2", 100, closeTime, PhaseBet{Direction: LESS, Phase: 5})
+	l.Wager("user3", 100, openTime, PhaseBet{Direction: GREATER, Phase: 4}) // loss
+	l.Update(3)
+	if err := l.Close(closeTime); err != nil {
+		t.Errorf("Close() returned unexpected error: %v", err)
+	}
+	if err := l.Resolve(); err != nil {
+		t.Errorf("Resolve() returned unexpected error: %v", err)
+	}
+	// The bet placed at close has half the weight of the one placed at open,
+	// so the payout of 100 is split 2:1.
+	for _, tc := range []struct {
+		uid     string
+		balance int
+	}{
+		{uid: "user1", balance: 1067},
+		{uid: "user2", balance: 1034},
+		{uid: "user3", balance: 900},
+	} {
+		u, _ := c.GetUser(tc.uid)
+		if balance, _, _ := u.Balance(); balance != tc.balance {
+			t.Errorf("%s has %d balance, expected %d", tc.uid, balance, tc.balance)
+		}
+	}
+}
+
+func TestDecayBets(t *testing.T) {
+	opened := time.Date(2020, time.January, 2, 0, 0, 0, 0, time.UTC)
+	closed := opened.Add(100 * time.Minute)
+	for _, tc := range []struct {
+		placed time.Time
+		want   float64
+	}{
+		{placed: opened, want: 0},
+		{placed: opened.Add(25 * time.Minute), want: 0.2},
+		{placed: closed, want: 0.8},
+		// Bets loaded from before the open time known in memory don't get
+		// negative decay.
+		{placed: opened.Add(-time.Hour), want: 0},
+	} {
+		b := &internalPhaseBet{amount: 100, risk: 0.5, placed: tc.placed}
+		decayBets([]*internalPhaseBet{b}, opened, closed, 0.8)
+		if math.Abs(b.decay-tc.want) > 1e-9 {
+			t.Errorf("bet placed %s after open has decay %v, want %v", tc.placed.Sub(opened), b.decay, tc.want)
+		}
+	}
+}
+
 func TestInterpretPhaseBet(t *testing.T) {
 	l := phaseLifecycle{}
 	for _, tc := range []struct {
@@ -319,8 +670,8 @@ func TestPhaseBetSummary(t *testing.T) {
 	l.Wager("user3", 100, time.Now(), PhaseBet{Direction: EQUAL, Phase: 1})   // loss
 	l.Wager("user4", 100, time.Now(), PhaseBet{Direction: LESS, Phase: 2})    // loss
 	// Unresolved wagers
-	l.Wager("user1", 100, time.Now(), PhaseBet{Direction: LESS, Phase: 5})    // risk ~0.0625
-	l.Wager("user5", 100, time.Now(), PhaseBet{Direction: GREATER, Phase: 6}) // risk ~0.984375
+	l.Wager("user1", 100, time.Now().Add(time.Second), PhaseBet{Direction: LESS, Phase: 5}) // risk ~0.0625
+	l.Wager("user5", 100, time.Now(), PhaseBet{Direction: GREATER, Phase: 6})               // risk ~0.984375
 	l.Update(4)
 
 	summary, err := l.BetsSummary("risk")
@@ -2,6 +2,7 @@ package events
 
 import (
 	"bet/core"
+	"bet/env"
 	"bet/state"
 	"fmt"
 	"log/slog"
@@ -18,14 +19,20 @@ type ShinyEvent struct {
 	lastEncounterWasShiny bool
 }
 
-func NewShinyEvent(c *core.Core, channel string) *ShinyEvent {
+func NewShinyEvent(c *core.Core, odds env.OddsConfig, conf env.PhaseEventConfig, channel string) *ShinyEvent {
 	e := &ShinyEvent{
 		phaseLifecycle: &phaseLifecycle{
-			eventId:     shinyEventName,
-			displayName: "Shiny",
-			probability: 1.0 / 8192.0,
-			core:        c,
-			channel:     channel,
+			eventId:           shinyEventName,
+			displayName:       "Shiny",
+			probability:       oddsProbability(odds),
+			odds:              newOddsModel(c.Database, shinyEventName, conf.OddsModel),
+			bank:              newBank(c, conf.Payout),
+			rakePercent:       conf.RakePercent,
+			lockoutEncounters: conf.LockoutEncounters,
+			lockoutBefore:     conf.LockoutBefore,
+			riskDecay:         conf.RiskDecay,
+			core:              c,
+			channel:           channel,
 		},
 		core: c,
 	}
@@ -74,6 +81,7 @@ func loadEvent(event *ShinyEvent) {
 		if !closeTs.After(openTs) {
 			event.phaseLifecycle.state = OPEN
 			event.phaseLifecycle.current = phase
+			event.phaseLifecycle.opened = openTs
 		}
 	}
 	if !gotRow {
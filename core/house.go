@@ -0,0 +1,100 @@
+package core
+
+import (
+	"bet/core/db"
+	"fmt"
+	"log/slog"
+	"maps"
+	"time"
+)
+
+// Houses are the accounts that pay out fixed-odds bets, like the bank.  They
+// aren't players, so they're left out of the leaderboard, keep their balance
+// when a season ends, and can't be bailed out, claim the daily allowance or
+// borrow.  Instead, each house is topped up to its funding.
+
+// HouseError is returned when a house tries to do something only players can.
+type HouseError struct{}
+
+func (e HouseError) Error() string {
+	return "the house can't do that"
+}
+
+func (c *Core) loadHouses() {
+	rows, err := c.Database.LoadHouses()
+	if err != nil {
+		slog.Error(fmt.Sprintf("error loading houses: %v", err))
+		return
+	}
+	for rows.Next() {
+		var uid string
+		if err := rows.Scan(&uid); err != nil {
+			slog.Warn(fmt.Sprintf("error loading house: %v", err))
+			continue
+		}
+		c.houses[uid] = 0
+	}
+}
+
+// RegisterHouse makes the user a house, and tops it up to the funding straight
+// away.  Houses shared by several events are funded to the most any of them
+// asks for.  A funding of 0 leaves the house to earn its cakes from bets.  This
+// locks EventMu, so must not be called while resolving events.
+func (c *Core) RegisterHouse(uid string, funding int) error {
+	uid = c.Account(uid)
+	u, err := c.GetUser(uid)
+	if err != nil {
+		return err
+	}
+	c.houseMu.Lock()
+	funding = max(funding, c.houses[uid])
+	c.houses[uid] = funding
+	c.houseMu.Unlock()
+	c.EventMu.Lock()
+	defer c.EventMu.Unlock()
+	tx, err := c.Database.OpenTransaction()
+	if err != nil {
+		return err
+	}
+	if err := tx.WriteHouse(uid); err != nil {
+		return err
+	}
+	if _, err := fundHouse(tx, u, funding, time.Now()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// IsHouse returns whether the identity's account is a house.
+func (c *Core) IsHouse(uid string) bool {
+	uid = c.Account(uid)
+	c.houseMu.Lock()
+	defer c.houseMu.Unlock()
+	_, ok := c.houses[uid]
+	return ok
+}
+
+// fundings returns the funding of every house, keyed by the house's id.
+func (c *Core) fundings() map[string]int {
+	c.houseMu.Lock()
+	defer c.houseMu.Unlock()
+	return maps.Clone(c.houses)
+}
+
+// fundHouse tops up the house so the cakes it has outside of bets come to the
+// funding, and returns how much it was given.
+func fundHouse(tx db.Transaction, house *user, funding int, now time.Time) (int, error) {
+	balance, inBets, _ := house.Balance()
+	amount := funding - (balance - inBets)
+	if amount <= 0 {
+		return 0, nil
+	}
+	if err := house.Earn(tx, amount); err != nil {
+		return 0, err
+	}
+	if err := tx.WriteGrant(house.id, db.GrantFunding, now, amount, 0); err != nil {
+		return 0, err
+	}
+	slog.Info(fmt.Sprintf("funded house %s with %d cakes", house.id, amount))
+	return amount, nil
+}
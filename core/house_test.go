@@ -0,0 +1,95 @@
+package core
+
+import (
+	"bet/core/db"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRegisterHouseFunds(t *testing.T) {
+	c := New(db.Fake(), nil, nil)
+	if err := c.RegisterHouse("bank", 5000); err != nil {
+		t.Fatalf("RegisterHouse() returned unexpected error: %v", err)
+	}
+	if got := balanceOf(c, "bank"); got != 5000 {
+		t.Errorf("bank has %d balance after registering, want 5000", got)
+	}
+	// A second event sharing the bank with less funding doesn't lower it.
+	if err := c.RegisterHouse("bank", 2000); err != nil {
+		t.Fatalf("RegisterHouse() returned unexpected error: %v", err)
+	}
+	spend(t, c, "bank", 4000)
+	if _, err := c.RefreshBalance(); err != nil {
+		t.Fatalf("RefreshBalance() returned unexpected error: %v", err)
+	}
+	if got := balanceOf(c, "bank"); got != 5000 {
+		t.Errorf("bank has %d balance after refresh, want 5000", got)
+	}
+	if !c.IsHouse("bank") || c.IsHouse("player") {
+		t.Errorf("IsHouse() only wants the bank to be a house")
+	}
+}
+
+func TestRefreshBalanceSkipsHouse(t *testing.T) {
+	c := New(db.Fake(), nil, nil)
+	c.SetAllowance(AllowancePolicy{Floor: 200})
+	if err := c.RegisterHouse("bank", 0); err != nil {
+		t.Fatalf("RegisterHouse() returned unexpected error: %v", err)
+	}
+	spend(t, c, "bank", 950)
+	unlocks, err := c.RefreshBalance()
+	if err != nil {
+		t.Fatalf("RefreshBalance() returned unexpected error: %v", err)
+	}
+	if got := balanceOf(c, "bank"); got != 50 {
+		t.Errorf("unfunded bank has %d balance after refresh, want 50", got)
+	}
+	if len(unlocks) != 0 {
+		t.Errorf("RefreshBalance() unlocks = %v, want none", unlocks)
+	}
+}
+
+func TestEndSeasonKeepsHouse(t *testing.T) {
+	d := db.FakeWithUsers()
+	c := New(d, nil, nil)
+	if err := c.RegisterHouse("bank", 3000); err != nil {
+		t.Fatalf("RegisterHouse() returned unexpected error: %v", err)
+	}
+	spend(t, c, "player", 500)
+	if err := c.EndSeason(1, time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("EndSeason() returned unexpected error: %v", err)
+	}
+	if got := balanceOf(c, "bank"); got != 3000 {
+		t.Errorf("bank has %d balance after the season, want 3000", got)
+	}
+	if got := balanceOf(c, "player"); got != StartingBalance {
+		t.Errorf("player has %d balance after the season, want %d", got, StartingBalance)
+	}
+	// The database agrees with the cache.
+	users, err := c.CompareUsers()
+	if err != nil {
+		t.Fatalf("CompareUsers() returned unexpected error: %v", err)
+	}
+	for _, u := range users {
+		if !u.Matches() {
+			t.Errorf("%s is cached as %+v but stored as %+v", u.UID, u.Cached, u.Stored)
+		}
+	}
+}
+
+func TestHouseCannotPlay(t *testing.T) {
+	c := New(db.Fake(), nil, nil)
+	c.SetAllowance(AllowancePolicy{Daily: 50})
+	c.SetLoans(LoanPolicy{Base: 100, Max: 100})
+	if err := c.RegisterHouse("bank", 0); err != nil {
+		t.Fatalf("RegisterHouse() returned unexpected error: %v", err)
+	}
+	now := time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)
+	if _, _, err := c.ClaimDaily("bank", now); !errors.Is(err, HouseError{}) {
+		t.Errorf("ClaimDaily(bank) = %v, want HouseError", err)
+	}
+	if err := c.Borrow("bank", 100, now); !errors.Is(err, HouseError{}) {
+		t.Errorf("Borrow(bank) = %v, want HouseError", err)
+	}
+}
@@ -0,0 +1,195 @@
+package core
+
+import (
+	"crypto/rand"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// Identities are the ids users are known by on each chat platform.  Discord
+// ids are used as is, and so are the ids of the accounts created for them,
+// while identities from other platforms are prefixed with the platform, e.g.
+// "twitch:1234".  Any identity can be linked to another user's account, after
+// which everything that identity does is done with that account.
+
+// LinkCodeTTL is how long a link code can be redeemed for after it's made.
+const LinkCodeTTL = 10 * time.Minute
+
+// linkCodeAlphabet leaves out characters that are easily mistaken for each
+// other, since codes are typed in by hand.
+const linkCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+const linkCodeLength = 6
+
+type linkCode struct {
+	uid     string
+	expires time.Time
+}
+
+// InvalidLinkCodeError is returned when linking with a code that doesn't exist
+// or has expired.
+type InvalidLinkCodeError struct{}
+
+func (e InvalidLinkCodeError) Error() string {
+	return "link code is invalid or has expired"
+}
+
+// AlreadyLinkedError is returned when linking an identity that's already
+// linked to an account.
+type AlreadyLinkedError struct {
+	UID string
+}
+
+func (e AlreadyLinkedError) Error() string {
+	return fmt.Sprintf("identity is already linked to %s", e.UID)
+}
+
+// LinkedAccountError is returned when linking an identity that other
+// identities are linked to, since they would lose their account.
+type LinkedAccountError struct{}
+
+func (e LinkedAccountError) Error() string {
+	return "other identities are linked to this identity's account"
+}
+
+// LinkOpenBetsError is returned when linking an identity that has open bets of
+// its own, since they couldn't be paid out once it's linked.
+type LinkOpenBetsError struct{}
+
+func (e LinkOpenBetsError) Error() string {
+	return "cannot link an identity with open bets"
+}
+
+// LinkHistoryError is returned when linking an identity whose own account has
+// been played with, since its balance, loan and bet history would be lost.
+type LinkHistoryError struct{}
+
+func (e LinkHistoryError) Error() string {
+	return "cannot link an identity with its own balance, loan or bet history"
+}
+
+func (c *Core) loadIdentities() {
+	rows, err := c.Database.LoadIdentities()
+	if err != nil {
+		slog.Error(fmt.Sprintf("error loading identities: %v", err))
+		return
+	}
+	for rows.Next() {
+		var identity, uid string
+		if err := rows.Scan(&identity, &uid); err != nil {
+			slog.Warn(fmt.Sprintf("error loading identity: %v", err))
+			continue
+		}
+		c.identities[identity] = uid
+	}
+}
+
+// Account returns the id of the account the identity bets with.  Identities
+// that aren't linked have an account of their own with the same id.
+func (c *Core) Account(identity string) string {
+	c.identityMu.Lock()
+	defer c.identityMu.Unlock()
+	if uid, ok := c.identities[identity]; ok {
+		return uid
+	}
+	return identity
+}
+
+// Identities returns the identities linked to the user's account, not
+// including the account's own id.
+func (c *Core) Identities(uid string) []string {
+	c.identityMu.Lock()
+	defer c.identityMu.Unlock()
+	identities := make([]string, 0)
+	for identity, linked := range c.identities {
+		if linked == uid {
+			identities = append(identities, identity)
+		}
+	}
+	return identities
+}
+
+// LinkCode makes a one-time code that links another identity to the user's
+// account when redeemed with Link before LinkCodeTTL has passed.
+func (c *Core) LinkCode(uid string, now time.Time) (string, error) {
+	b := make([]byte, linkCodeLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = linkCodeAlphabet[int(b[i])%len(linkCodeAlphabet)]
+	}
+	code := string(b)
+	c.identityMu.Lock()
+	defer c.identityMu.Unlock()
+	for k, v := range c.linkCodes {
+		if !now.Before(v.expires) {
+			delete(c.linkCodes, k)
+		}
+	}
+	c.linkCodes[code] = linkCode{uid: uid, expires: now.Add(LinkCodeTTL)}
+	return code, nil
+}
+
+// Link redeems the code to link the identity to the account the code was made
+// for, and returns the id of that account.  The identity's own account is left
+// behind, so it can't have open bets, and mustn't have been played with at
+// all: a balance other than the starting balance, a loan or resolved bets
+// would be lost.
+func (c *Core) Link(identity, code string, now time.Time) (string, error) {
+	c.identityMu.Lock()
+	defer c.identityMu.Unlock()
+	code = strings.ToUpper(strings.TrimSpace(code))
+	lc, ok := c.linkCodes[code]
+	if !ok || !now.Before(lc.expires) {
+		return "", InvalidLinkCodeError{}
+	}
+	if uid, ok := c.identities[identity]; ok {
+		return "", AlreadyLinkedError{UID: uid}
+	}
+	if identity == lc.uid {
+		return "", AlreadyLinkedError{UID: lc.uid}
+	}
+	for _, uid := range c.identities {
+		if uid == identity {
+			return "", LinkedAccountError{}
+		}
+	}
+	if u, ok := c.users[identity]; ok {
+		balance, inBets, err := u.Balance()
+		if err != nil {
+			return "", err
+		}
+		if inBets > 0 {
+			return "", LinkOpenBetsError{}
+		}
+		u.mu.Lock()
+		l := u.loan
+		u.mu.Unlock()
+		if balance != StartingBalance || l.owed > 0 || l.defaulted || !l.taken.IsZero() {
+			return "", LinkHistoryError{}
+		}
+	}
+	resolved, err := c.resolvedBets(identity)
+	if err != nil {
+		return "", err
+	}
+	if resolved > 0 {
+		return "", LinkHistoryError{}
+	}
+	tx, err := c.Database.OpenTransaction()
+	if err != nil {
+		return "", err
+	}
+	if err := tx.WriteIdentity(identity, lc.uid, now); err != nil {
+		return "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+	delete(c.linkCodes, code)
+	c.identities[identity] = lc.uid
+	return lc.uid, nil
+}
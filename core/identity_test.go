@@ -0,0 +1,117 @@
+package core
+
+import (
+	"bet/core/db"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLink(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	c := New(db.Fake(), nil, nil)
+	account, _ := c.GetUser("discord")
+	code, err := c.LinkCode("discord", now)
+	if err != nil {
+		t.Fatalf("LinkCode() returned unexpected error: %v", err)
+	}
+	if _, err := c.Link("twitch:1", "WRONG1", now); !errors.Is(err, InvalidLinkCodeError{}) {
+		t.Errorf("Link() with an unknown code returned %v, want InvalidLinkCodeError", err)
+	}
+	uid, err := c.Link("twitch:1", code, now)
+	if err != nil {
+		t.Fatalf("Link() returned unexpected error: %v", err)
+	}
+	if uid != "discord" {
+		t.Errorf("Link() linked to %s, want discord", uid)
+	}
+	if got := c.Account("twitch:1"); got != "discord" {
+		t.Errorf("Account(twitch:1) = %s, want discord", got)
+	}
+	if got := c.Account("twitch:2"); got != "twitch:2" {
+		t.Errorf("Account(twitch:2) = %s, want twitch:2", got)
+	}
+	if u, _ := c.GetUser("twitch:1"); u != account {
+		t.Errorf("GetUser(twitch:1) didn't return the linked account")
+	}
+	if got := c.Identities("discord"); len(got) != 1 || got[0] != "twitch:1" {
+		t.Errorf("Identities(discord) = %v, want [twitch:1]", got)
+	}
+	// Codes can only be used once.
+	if _, err := c.Link("twitch:2", code, now); !errors.Is(err, InvalidLinkCodeError{}) {
+		t.Errorf("Link() with a used code returned %v, want InvalidLinkCodeError", err)
+	}
+	// Links survive a restart.
+	if got := New(c.Database, nil, nil).Account("twitch:1"); got != "discord" {
+		t.Errorf("Account(twitch:1) after reloading = %s, want discord", got)
+	}
+}
+
+func TestLinkErrors(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	c := New(db.Fake(), nil, nil)
+	link := func(identity, uid string, at time.Time) error {
+		code, err := c.LinkCode(uid, now)
+		if err != nil {
+			t.Fatalf("LinkCode() returned unexpected error: %v", err)
+		}
+		_, err = c.Link(identity, code, at)
+		return err
+	}
+	if err := link("twitch:1", "discord", now.Add(LinkCodeTTL)); !errors.Is(err, InvalidLinkCodeError{}) {
+		t.Errorf("Link() with an expired code returned %v, want InvalidLinkCodeError", err)
+	}
+	if err := link("twitch:1", "discord", now); err != nil {
+		t.Fatalf("Link() returned unexpected error: %v", err)
+	}
+	var linkedErr AlreadyLinkedError
+	if err := link("twitch:1", "other", now); !errors.As(err, &linkedErr) || linkedErr.UID != "discord" {
+		t.Errorf("Link() of a linked identity returned %v, want AlreadyLinkedError{discord}", err)
+	}
+	if err := link("discord", "discord", now); !errors.As(err, &linkedErr) {
+		t.Errorf("Link() to its own account returned %v, want AlreadyLinkedError", err)
+	}
+	if err := link("discord", "other", now); !errors.Is(err, LinkedAccountError{}) {
+		t.Errorf("Link() of an account with identities returned %v, want LinkedAccountError", err)
+	}
+	u, _ := c.GetUser("twitch:2")
+	tx, _ := c.Database.OpenTransaction()
+	if err := u.Reserve(tx, 100); err != nil {
+		t.Fatalf("Reserve() returned unexpected error: %v", err)
+	}
+	if err := link("twitch:2", "discord", now); !errors.Is(err, LinkOpenBetsError{}) {
+		t.Errorf("Link() with open bets returned %v, want LinkOpenBetsError", err)
+	}
+}
+
+func TestLinkHistory(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	c := New(db.Fake(), nil, nil)
+	c.SetLoans(LoanPolicy{Base: 100, Max: 100})
+	link := func(identity string) error {
+		code, err := c.LinkCode("discord", now)
+		if err != nil {
+			t.Fatalf("LinkCode() returned unexpected error: %v", err)
+		}
+		_, err = c.Link(identity, code, now)
+		return err
+	}
+	// Whatever the identity did with its own account would be lost.
+	spend(t, c, "twitch:1", 100)
+	if err := c.Borrow("twitch:2", 100, now); err != nil {
+		t.Fatalf("Borrow() returned unexpected error: %v", err)
+	}
+	tx, _ := c.Database.OpenTransaction()
+	tx.WriteResult("twitch:3", "shiny", now, now, db.OutcomeRefund, 0)
+	tx.Commit()
+	for _, identity := range []string{"twitch:1", "twitch:2", "twitch:3"} {
+		if err := link(identity); !errors.Is(err, LinkHistoryError{}) {
+			t.Errorf("Link(%s) returned %v, want LinkHistoryError", identity, err)
+		}
+	}
+	// An identity that has only looked at its balance has nothing to lose.
+	c.GetUser("twitch:4")
+	if err := link("twitch:4"); err != nil {
+		t.Errorf("Link() of an unplayed identity returned unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package core
+
+import "fmt"
+
+// WagerLimits bounds how much users can bet, so a single user can't swamp an
+// event's pool.  Limits that are 0 aren't enforced.
+type WagerLimits struct {
+	// MinBet and MaxBet bound the amount of a single bet.
+	MinBet int
+	MaxBet int
+	// MaxEventExposure is the most a user can have bet on one event in total.
+	MaxEventExposure int
+	// MaxOpenBets is the most bets a user can have open across all events.
+	MaxOpenBets int
+}
+
+const (
+	LimitMinBet        = "min bet"
+	LimitMaxBet        = "max bet"
+	LimitEventExposure = "event exposure"
+	LimitOpenBets      = "open bets"
+)
+
+// LimitError is returned when a wager would break one of the wager limits.
+type LimitError struct {
+	// Limit is which limit was broken, one of the Limit constants.
+	Limit string
+	// Value is what the limit is set to.
+	Value int
+}
+
+func (e LimitError) Error() string {
+	switch e.Limit {
+	case LimitMinBet:
+		return fmt.Sprintf("bets must be at least %d cakes", e.Value)
+	case LimitMaxBet:
+		return fmt.Sprintf("bets can be at most %d cakes", e.Value)
+	case LimitEventExposure:
+		return fmt.Sprintf("cannot bet more than %d cakes on one event", e.Value)
+	case LimitOpenBets:
+		return fmt.Sprintf("cannot have more than %d open bets", e.Value)
+	}
+	return fmt.Sprintf("%s limit of %d exceeded", e.Limit, e.Value)
+}
+
+// CheckWager returns a LimitError if the user betting amount on the event
+// would break the wager limits.  This reads from the database, so must be
+// called before opening the wager's transaction, and the caller must hold
+// WagerMu until the wager is stored so concurrent wagers see each other.
+func (c *Core) CheckWager(uid string, eid string, amount int) error {
+	limits := c.Limits()
+	if limits.MinBet > 0 && amount < limits.MinBet {
+		return LimitError{Limit: LimitMinBet, Value: limits.MinBet}
+	}
+	if limits.MaxBet > 0 && amount > limits.MaxBet {
+		return LimitError{Limit: LimitMaxBet, Value: limits.MaxBet}
+	}
+	if limits.MaxEventExposure <= 0 && limits.MaxOpenBets <= 0 {
+		return nil
+	}
+	rows, err := c.Database.LoadUserBets(uid)
+	if err != nil {
+		return err
+	}
+	var open, exposure int
+	for rows.Next() {
+		var betEid, blob string
+		var betAmount int
+		var risk float64
+		if err := rows.Scan(&betEid, &betAmount, &risk, &blob); err != nil {
+			return err
+		}
+		open++
+		if betEid == eid {
+			exposure += betAmount
+		}
+	}
+	if limits.MaxEventExposure > 0 && exposure+amount > limits.MaxEventExposure {
+		return LimitError{Limit: LimitEventExposure, Value: limits.MaxEventExposure}
+	}
+	if limits.MaxOpenBets > 0 && open >= limits.MaxOpenBets {
+		return LimitError{Limit: LimitOpenBets, Value: limits.MaxOpenBets}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package core
+
+import (
+	"bet/core/db"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCheckWager(t *testing.T) {
+	d := db.Fake()
+	c := New(d, nil, nil)
+	c.SetLimits(WagerLimits{MinBet: 10, MaxBet: 500, MaxEventExposure: 600, MaxOpenBets: 3})
+	tx, _ := d.OpenTransaction()
+	now := time.Now()
+	tx.WriteBet("user", "shiny", now, 400, 0.5, "")
+	tx.WriteBet("user", "anti", now, 100, 0.5, "")
+	tx.WriteBet("other", "shiny", now, 500, 0.5, "")
+	tx.Commit()
+	for _, tc := range []struct {
+		name   string
+		eid    string
+		amount int
+		limit  string
+	}{
+		{name: "within limits", eid: "shiny", amount: 200},
+		{name: "too small", eid: "shiny", amount: 5, limit: LimitMinBet},
+		{name: "too large", eid: "item", amount: 501, limit: LimitMaxBet},
+		{name: "too much on one event", eid: "shiny", amount: 201, limit: LimitEventExposure},
+		{name: "other events count separately", eid: "anti", amount: 500},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := c.CheckWager("user", tc.eid, tc.amount)
+			var limitErr LimitError
+			if tc.limit == "" {
+				if err != nil {
+					t.Errorf("CheckWager(%s, %d) returned unexpected error: %v", tc.eid, tc.amount, err)
+				}
+			} else if !errors.As(err, &limitErr) || limitErr.Limit != tc.limit {
+				t.Errorf("CheckWager(%s, %d) returned %v, want %s limit error", tc.eid, tc.amount, err, tc.limit)
+			}
+		})
+	}
+	// A third open bet is allowed, a fourth isn't.
+	tx, _ = d.OpenTransaction()
+	tx.WriteBet("user", "item", now, 10, 0.5, "")
+	tx.Commit()
+	var limitErr LimitError
+	if err := c.CheckWager("user", "item", 10); !errors.As(err, &limitErr) || limitErr.Limit != LimitOpenBets {
+		t.Errorf("CheckWager() with 3 open bets returned %v, want open bets limit error", err)
+	}
+}
@@ -0,0 +1,238 @@
+package core
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+)
+
+// loan is what a user owes from borrowing cakes.  Loans are repaid from the
+// user's winnings before they're credited to their balance.
+type loan struct {
+	owed  int
+	taken time.Time
+	// The number of events resolved since the loan was taken.
+	events int
+	// Defaulted users can never borrow again.
+	defaulted bool
+}
+
+// LoanPolicy decides how much users can borrow and on what terms.
+type LoanPolicy struct {
+	// Base is how much any user can borrow.
+	Base int
+	// PerBet is how much more users can borrow for every bet they've had
+	// resolved, so users with more history are trusted with more.
+	PerBet int
+	// Max caps how much any user can borrow, or 0 to disable loans.
+	Max int
+	// InterestPercent is added to what's owed every time an event resolves.
+	InterestPercent float64
+	// Term is how many events can resolve before an unpaid loan is defaulted
+	// on, or 0 for loans to never default.
+	Term int
+}
+
+// NoLoansError is returned when borrowing while loans are disabled.
+type NoLoansError struct{}
+
+func (e NoLoansError) Error() string {
+	return "loans are disabled"
+}
+
+// LoanDefaultedError is returned when a user who defaulted tries to borrow.
+type LoanDefaultedError struct{}
+
+func (e LoanDefaultedError) Error() string {
+	return "cannot borrow after defaulting on a loan"
+}
+
+// LoanOutstandingError is returned when a user borrows while they still owe on
+// an earlier loan.
+type LoanOutstandingError struct {
+	Owed int
+}
+
+func (e LoanOutstandingError) Error() string {
+	return fmt.Sprintf("cannot borrow while owing %d cakes", e.Owed)
+}
+
+// LoanLimitError is returned when a user borrows more than they're allowed.
+type LoanLimitError struct {
+	Max int
+}
+
+func (e LoanLimitError) Error() string {
+	return fmt.Sprintf("cannot borrow more than %d cakes", e.Max)
+}
+
+func (c *Core) loadLoans() {
+	rows, err := c.Database.LoadLoans()
+	if err != nil {
+		slog.Error(fmt.Sprintf("error loading loans: %v", err))
+		return
+	}
+	for rows.Next() {
+		var uid, taken string
+		var l loan
+		if err := rows.Scan(&uid, &l.owed, &taken, &l.events, &l.defaulted); err != nil {
+			slog.Warn(fmt.Sprintf("error loading loan: %v", err))
+			continue
+		}
+		l.taken, err = time.Parse(time.DateTime, taken)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("could not parse loan time %s: %v", taken, err))
+		}
+		u, ok := c.users[uid]
+		if !ok {
+			slog.Warn(fmt.Sprintf("loan for unknown user %s", uid))
+			continue
+		}
+		u.loan = l
+	}
+}
+
+// Loan returns what the user owes, the number of events resolved since they
+// borrowed, and whether they've defaulted.
+func (c *Core) Loan(uid string) (int, int, bool, error) {
+	u, err := c.GetUser(uid)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.loan.owed, u.loan.events, u.loan.defaulted, nil
+}
+
+// MaxLoan returns the most the user can borrow, based on how many of their
+// bets have been resolved.
+func (c *Core) MaxLoan(uid string) (int, error) {
+	policy := c.Loans()
+	if policy.Max <= 0 {
+		return 0, nil
+	}
+	resolved, err := c.resolvedBets(uid)
+	if err != nil {
+		return 0, err
+	}
+	return min(policy.Base+policy.PerBet*resolved, policy.Max), nil
+}
+
+// resolvedBets returns how many of the user's bets have been resolved.
+func (c *Core) resolvedBets(uid string) (int, error) {
+	rows, err := c.Database.UserStats(uid)
+	if err != nil {
+		return 0, err
+	}
+	var wagered, profit, largest, resolved int
+	for rows.Next() {
+		if err := rows.Scan(&wagered, &profit, &largest, &resolved); err != nil {
+			return 0, err
+		}
+	}
+	return resolved, nil
+}
+
+// Borrow lends the user the amount, which is added to their balance and must
+// be repaid with interest from their winnings.
+func (c *Core) Borrow(uid string, amount int, now time.Time) error {
+	if c.Loans().Max <= 0 {
+		return NoLoansError{}
+	}
+	if amount <= 0 {
+		return fmt.Errorf("must borrow a positive amount")
+	}
+	if c.IsHouse(uid) {
+		return HouseError{}
+	}
+	u, err := c.GetUser(uid)
+	if err != nil {
+		return err
+	}
+	max, err := c.MaxLoan(uid)
+	if err != nil {
+		return err
+	}
+	// Hold the event lock so interest isn't charged partway through.
+	c.EventMu.Lock()
+	defer c.EventMu.Unlock()
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.loan.defaulted {
+		return LoanDefaultedError{}
+	}
+	if u.loan.owed > 0 {
+		return LoanOutstandingError{Owed: u.loan.owed}
+	}
+	if amount > max {
+		return LoanLimitError{Max: max}
+	}
+	tx, err := c.Database.OpenTransaction()
+	if err != nil {
+		return err
+	}
+	if err := tx.WriteLoan(uid, amount, now, 0, false); err != nil {
+		return err
+	}
+	if err := tx.WriteBalance(uid, u.balance+amount); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	u.loan = loan{owed: amount, taken: now}
+	u.balance += amount
+	return nil
+}
+
+// ChargeInterest adds interest to every outstanding loan after an event has
+// resolved, and defaults loans that have gone unpaid for the whole term.
+// Returns the users that defaulted.  The caller must hold EventMu.
+func (c *Core) ChargeInterest() ([]string, error) {
+	policy := c.Loans()
+	tx, err := c.Database.OpenTransaction()
+	if err != nil {
+		return nil, err
+	}
+	defaulted := make([]string, 0)
+	changed := make(map[*user]loan)
+	for _, u := range c.users {
+		u.mu.Lock()
+		l := u.loan
+		u.mu.Unlock()
+		if l.owed <= 0 || l.defaulted {
+			continue
+		}
+		l.owed += int(math.Ceil(float64(l.owed) * policy.InterestPercent / 100))
+		l.events++
+		if policy.Term > 0 && l.events >= policy.Term {
+			l.defaulted = true
+			defaulted = append(defaulted, u.id)
+		}
+		if err := tx.WriteLoan(u.id, l.owed, l.taken, l.events, l.defaulted); err != nil {
+			slog.Warn(fmt.Sprintf("could not charge interest to %s: %v", u.id, err))
+			continue
+		}
+		changed[u] = l
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	for u, l := range changed {
+		u.mu.Lock()
+		u.loan = l
+		u.mu.Unlock()
+	}
+	return defaulted, nil
+}
+
+// DefaultsMessage formats the users that defaulted on their loans to be
+// appended to a message.  Returns an empty string if nobody defaulted.
+func DefaultsMessage(uids []string) string {
+	message := ""
+	for _, uid := range uids {
+		message += fmt.Sprintf("\n<@%s> defaulted on their loan and can't borrow again.", uid)
+	}
+	return message
+}
@@ -0,0 +1,122 @@
+package core
+
+import (
+	"bet/core/db"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBorrow(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	c := New(db.Fake(), nil, nil)
+	if err := c.Borrow("user", 100, now); !errors.Is(err, NoLoansError{}) {
+		t.Errorf("Borrow() with loans disabled returned %v, want NoLoansError", err)
+	}
+	c.SetLoans(LoanPolicy{Base: 200, PerBet: 10, Max: 500})
+	var limitErr LoanLimitError
+	if err := c.Borrow("user", 300, now); !errors.As(err, &limitErr) || limitErr.Max != 200 {
+		t.Errorf("Borrow() over the limit returned %v, want LoanLimitError{200}", err)
+	}
+	if err := c.Borrow("user", 150, now); err != nil {
+		t.Fatalf("Borrow() returned unexpected error: %v", err)
+	}
+	if got := balanceOf(c, "user"); got != 1150 {
+		t.Errorf("user has %d balance after borrowing, want 1150", got)
+	}
+	var outstandingErr LoanOutstandingError
+	if err := c.Borrow("user", 10, now); !errors.As(err, &outstandingErr) || outstandingErr.Owed != 150 {
+		t.Errorf("Borrow() while owing returned %v, want LoanOutstandingError{150}", err)
+	}
+}
+
+func TestPayoutRepaysLoan(t *testing.T) {
+	c := New(db.Fake(), nil, nil)
+	c.SetLoans(LoanPolicy{Base: 200, Max: 200})
+	if err := c.Borrow("user", 200, time.Now()); err != nil {
+		t.Fatalf("Borrow() returned unexpected error: %v", err)
+	}
+	u, _ := c.GetUser("user")
+	tx, _ := c.Database.OpenTransaction()
+	// The first payout only goes to the loan, the second pays the rest of the
+	// loan and credits the remainder.
+	for _, tc := range []struct {
+		amount, repaid, owed, balance int
+	}{
+		{amount: 150, repaid: 150, owed: 50, balance: 1200},
+		{amount: 100, repaid: 50, owed: 0, balance: 1250},
+	} {
+		repaid, err := u.Payout(tx, tc.amount)
+		if err != nil {
+			t.Fatalf("Payout(%d) returned unexpected error: %v", tc.amount, err)
+		}
+		if repaid != tc.repaid {
+			t.Errorf("Payout(%d) repaid %d, want %d", tc.amount, repaid, tc.repaid)
+		}
+		if owed, _, _, _ := c.Loan("user"); owed != tc.owed {
+			t.Errorf("user owes %d after Payout(%d), want %d", owed, tc.amount, tc.owed)
+		}
+		if got := balanceOf(c, "user"); got != tc.balance {
+			t.Errorf("user has %d balance after Payout(%d), want %d", got, tc.amount, tc.balance)
+		}
+	}
+	tx.Commit()
+}
+
+func TestChargeInterest(t *testing.T) {
+	c := New(db.Fake(), nil, nil)
+	c.SetLoans(LoanPolicy{Base: 200, Max: 200, InterestPercent: 5, Term: 2})
+	if err := c.Borrow("user", 101, time.Now()); err != nil {
+		t.Fatalf("Borrow() returned unexpected error: %v", err)
+	}
+	defaults, err := c.ChargeInterest()
+	if err != nil {
+		t.Fatalf("ChargeInterest() returned unexpected error: %v", err)
+	}
+	// Interest is rounded up.
+	owed, events, defaulted, _ := c.Loan("user")
+	if owed != 107 || events != 1 || defaulted || len(defaults) != 0 {
+		t.Errorf("after one event Loan() = (%d, %d, %t), want (107, 1, false)", owed, events, defaulted)
+	}
+	defaults, err = c.ChargeInterest()
+	if err != nil {
+		t.Fatalf("ChargeInterest() returned unexpected error: %v", err)
+	}
+	owed, events, defaulted, _ = c.Loan("user")
+	if owed != 113 || events != 2 || !defaulted {
+		t.Errorf("after the term Loan() = (%d, %d, %t), want (113, 2, true)", owed, events, defaulted)
+	}
+	if len(defaults) != 1 || defaults[0] != "user" {
+		t.Errorf("ChargeInterest() defaults = %v, want [user]", defaults)
+	}
+	// Defaulted loans stop accruing interest and block borrowing.
+	if _, err := c.ChargeInterest(); err != nil {
+		t.Fatalf("ChargeInterest() returned unexpected error: %v", err)
+	}
+	if owed, _, _, _ := c.Loan("user"); owed != 113 {
+		t.Errorf("defaulted loan grew to %d, want 113", owed)
+	}
+	if err := c.Borrow("user", 10, time.Now()); !errors.Is(err, LoanDefaultedError{}) {
+		t.Errorf("Borrow() after defaulting returned %v, want LoanDefaultedError", err)
+	}
+}
+
+func TestEndSeasonForgivesLoans(t *testing.T) {
+	c := New(db.Fake(), nil, nil)
+	c.SetLoans(LoanPolicy{Base: 200, Max: 200, Term: 1})
+	if err := c.Borrow("user", 200, time.Now()); err != nil {
+		t.Fatalf("Borrow() returned unexpected error: %v", err)
+	}
+	if _, err := c.ChargeInterest(); err != nil {
+		t.Fatalf("ChargeInterest() returned unexpected error: %v", err)
+	}
+	if err := c.EndSeason(1, time.Now()); err != nil {
+		t.Fatalf("EndSeason() returned unexpected error: %v", err)
+	}
+	if owed, events, defaulted, _ := c.Loan("user"); owed != 0 || events != 0 || defaulted {
+		t.Errorf("after the season Loan() = (%d, %d, %t), want nothing owed", owed, events, defaulted)
+	}
+	if err := c.Borrow("user", 100, time.Now()); err != nil {
+		t.Errorf("Borrow() in the new season returned unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,129 @@
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxPageLength is the most characters put on a single page.  Embed
+// descriptions can be longer, but this keeps pages readable on mobile.
+const MaxPageLength = 1900
+
+// pageExpiry is how long paged messages can be paged through before they're
+// forgotten.  Pages are only kept in memory, so a restart also forgets them.
+const pageExpiry = 24 * time.Hour
+
+type pagedMessage struct {
+	title   string
+	pages   []string
+	created time.Time
+}
+
+// pageStore keeps the content of paged messages so that button presses can
+// render any page of them later.
+type pageStore struct {
+	mu       sync.Mutex
+	messages map[string]*pagedMessage
+	nextID   int
+}
+
+func newPageStore() *pageStore {
+	return &pageStore{messages: make(map[string]*pagedMessage)}
+}
+
+func (p *pageStore) add(title string, pages []string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	for id, m := range p.messages {
+		if now.Sub(m.created) > pageExpiry {
+			delete(p.messages, id)
+		}
+	}
+	p.nextID++
+	id := strconv.Itoa(p.nextID)
+	p.messages[id] = &pagedMessage{title: title, pages: pages, created: now}
+	return id
+}
+
+func (p *pageStore) get(id string) (*pagedMessage, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	m, ok := p.messages[id]
+	return m, ok
+}
+
+// SplitLines splits content into chunks of at most limit characters, only
+// breaking between lines.  A single line longer than limit is broken wherever
+// it needs to be, but never inside a character.
+func SplitLines(content string, limit int) []string {
+	chunks := make([]string, 0)
+	current := strings.Builder{}
+	// length is the number of characters in current, which can be fewer than
+	// its bytes.
+	length := 0
+	for _, line := range strings.Split(content, "\n") {
+		runes := []rune(line)
+		for len(runes) > limit {
+			if length > 0 {
+				chunks = append(chunks, current.String())
+				current.Reset()
+				length = 0
+			}
+			chunks = append(chunks, string(runes[:limit]))
+			runes = runes[limit:]
+		}
+		if length > 0 && length+1+len(runes) > limit {
+			chunks = append(chunks, current.String())
+			current.Reset()
+			length = 0
+		}
+		if length > 0 {
+			current.WriteString("\n")
+			length++
+		}
+		current.WriteString(string(runes))
+		length += len(runes)
+	}
+	if current.Len() > 0 || len(chunks) == 0 {
+		chunks = append(chunks, current.String())
+	}
+	return chunks
+}
+
+// Page is one page of a paged message.
+type Page struct {
+	// ID identifies the paged message, so the other pages can be rendered
+	// later.  It's empty when everything fits on one page.
+	ID      string
+	Title   string
+	Content string
+	// Index is the position of this page, out of Total pages.
+	Index int
+	Total int
+}
+
+// Paginate stores content as a paged message and returns its first page.
+func (c *Core) Paginate(title, content string) Page {
+	pages := SplitLines(content, MaxPageLength)
+	page := Page{Title: title, Content: pages[0], Total: len(pages)}
+	if len(pages) > 1 {
+		page.ID = c.pages.add(title, pages)
+	}
+	return page
+}
+
+// Page renders the requested page of a paged message.  Indexes out of range
+// are clamped to the first or last page.  Returns an error if the message is
+// no longer stored.
+func (c *Core) Page(id string, index int) (Page, error) {
+	m, ok := c.pages.get(id)
+	if !ok {
+		return Page{}, fmt.Errorf("paged message %s has expired", id)
+	}
+	index = max(0, min(index, len(m.pages)-1))
+	return Page{ID: id, Title: m.title, Content: m.pages[index], Index: index, Total: len(m.pages)}, nil
+}
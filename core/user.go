@@ -16,15 +16,21 @@ type user struct {
 	// Total amount the user has already placed on bets.  Can't bet more than
 	// balance - inBets.
 	inBets int
+	// The user's loan, if they've ever borrowed.
+	loan loan
 }
 
+// StartingBalance is the balance every user starts with, and what balances are
+// reset to at the start of a new season.
+const StartingBalance = 1000
+
 func newUser(id string, t db.Transaction) (*user, error) {
-	if err := t.WriteNewUser(id, 1000, 0); err != nil {
+	if err := t.WriteNewUser(id, StartingBalance, 0); err != nil {
 		return nil, err
 	}
 	return &user{
 		id:      id,
-		balance: 1000,
+		balance: StartingBalance,
 	}, nil
 }
 
@@ -92,8 +98,10 @@ func (u *user) Resolve(t db.Transaction, amount int, loss bool) error {
 	if err := t.WriteInBets(u.id, u.inBets-amount); err != nil {
 		return err
 	}
-	if err := t.WriteBalance(u.id, u.balance-amount); err != nil {
-		return err
+	if loss {
+		if err := t.WriteBalance(u.id, u.balance-amount); err != nil {
+			return err
+		}
 	}
 	u.inBets -= amount
 	if loss {
@@ -112,3 +120,22 @@ func (u *user) Earn(t db.Transaction, amount int) error {
 	u.balance += amount
 	return nil
 }
+
+// Payout credits the user with winnings, first repaying any loan they owe.
+// Returns the amount that went to repaying the loan.  This is thread-safe.
+func (u *user) Payout(t db.Transaction, amount int) (int, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	repaid := min(amount, u.loan.owed)
+	if repaid > 0 {
+		if err := t.WriteLoan(u.id, u.loan.owed-repaid, u.loan.taken, u.loan.events, u.loan.defaulted); err != nil {
+			return 0, err
+		}
+	}
+	if err := t.WriteBalance(u.id, u.balance+amount-repaid); err != nil {
+		return 0, err
+	}
+	u.loan.owed -= repaid
+	u.balance += amount - repaid
+	return repaid, nil
+}
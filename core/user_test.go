@@ -0,0 +1,38 @@
+package core
+
+import (
+	"bet/core/db"
+	"testing"
+)
+
+func TestResolveWinKeepsStoredBalance(t *testing.T) {
+	c := New(db.FakeWithUsers(), nil, nil)
+	u, _ := c.GetUser("user")
+	tx, _ := c.Database.OpenTransaction()
+	if err := u.Reserve(tx, 100); err != nil {
+		t.Fatalf("Reserve() returned unexpected error: %v", err)
+	}
+	// A bet that isn't lost only frees the reservation, so the stored
+	// balance must not be deducted either.
+	if err := u.Resolve(tx, 100, false); err != nil {
+		t.Fatalf("Resolve() returned unexpected error: %v", err)
+	}
+	tx.Commit()
+	users, err := c.CompareUsers()
+	if err != nil {
+		t.Fatalf("CompareUsers() returned unexpected error: %v", err)
+	}
+	if len(users) != 1 || !users[0].Matches() || users[0].Stored.Balance != 1000 {
+		t.Errorf("CompareUsers() = %+v, want the stored balance to stay 1000", users)
+	}
+
+	// A lost bet deducts the balance in both.
+	tx, _ = c.Database.OpenTransaction()
+	u.Reserve(tx, 100)
+	u.Resolve(tx, 100, true)
+	tx.Commit()
+	users, _ = c.CompareUsers()
+	if len(users) != 1 || !users[0].Matches() || users[0].Stored.Balance != 900 {
+		t.Errorf("CompareUsers() = %+v, want the stored balance to be 900", users)
+	}
+}
@@ -0,0 +1,207 @@
+// Package dashboard serves a page showing what the bot is up to: the state of
+// each event and the bets on it, recent resolutions and the leaderboard.  The
+// page is rendered on the server with no external assets, so it works anywhere
+// the listener can be reached.
+package dashboard
+
+import (
+	"bet/core"
+	"bet/core/db"
+	"bytes"
+	_ "embed"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+)
+
+const (
+	// leaderboardSize is how many users are shown on the leaderboard.
+	leaderboardSize = 10
+	// resolutionsShown is how many of the most recent resolutions are shown.
+	resolutionsShown = 10
+	// distributionBars is the most bars shown in an event's bet distribution.
+	// Bets on anything else are grouped into one last bar.
+	distributionBars = 10
+)
+
+//go:embed dashboard.html
+var page string
+
+var tmpl = template.Must(template.New("dashboard").Parse(page))
+
+// Dashboard is everything shown on the page.
+type Dashboard struct {
+	Generated   time.Time
+	Events      []Event
+	Resolutions []Resolution
+	Leaderboard []Rank
+}
+
+// Event is an event and the bets placed on it since it last opened.
+type Event struct {
+	core.EventStatus
+	Bets int
+	// Pool is the total amount bet on the event.
+	Pool int
+	// Distribution is how much is bet on each outcome, biggest first.
+	Distribution []Bar
+}
+
+// Bar is one bar of the bet distribution histogram.
+type Bar struct {
+	Label  string
+	Amount int
+	Bets   int
+	// Width is the bar's width as a percentage of the biggest bar.
+	Width int
+}
+
+// Resolution is one time an event resolved.
+type Resolution struct {
+	Event    string
+	Resolved time.Time
+	Bets     int
+	Winners  int
+	// Paid is the total paid out to the winners.
+	Paid int
+}
+
+// Rank is a user's place on the leaderboard by balance.
+type Rank struct {
+	Rank    int
+	UID     string
+	Balance int
+}
+
+// Handler returns the handler that renders the dashboard.
+func Handler(c *core.Core) http.Handler {
+	return &handler{core: c}
+}
+
+type handler struct {
+	core *core.Core
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d, err := h.load(time.Now())
+	if err != nil {
+		slog.Warn(fmt.Sprintf("could not load dashboard: %v", err))
+		http.Error(w, "could not load the dashboard", http.StatusInternalServerError)
+		return
+	}
+	// Render to a buffer first so a template error doesn't leave half a page.
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, d); err != nil {
+		slog.Warn(fmt.Sprintf("could not render dashboard: %v", err))
+		http.Error(w, "could not render the dashboard", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	buf.WriteTo(w)
+}
+
+func (h *handler) load(now time.Time) (Dashboard, error) {
+	d := Dashboard{Generated: now.UTC()}
+	for _, eid := range h.core.Events() {
+		event, err := h.core.GetEvent(eid)
+		if err != nil {
+			return d, err
+		}
+		bets, err := h.core.Bets(eid)
+		if err != nil {
+			return d, err
+		}
+		e := Event{EventStatus: event.Status(), Bets: len(bets), Distribution: distribution(bets)}
+		for _, b := range bets {
+			e.Pool += b.Amount
+		}
+		d.Events = append(d.Events, e)
+	}
+	var err error
+	if d.Resolutions, err = h.resolutions(); err != nil {
+		return d, err
+	}
+	if d.Leaderboard, err = h.leaderboard(); err != nil {
+		return d, err
+	}
+	return d, nil
+}
+
+func (h *handler) resolutions() ([]Resolution, error) {
+	rows, err := h.core.Database.RecentResolutions(resolutionsShown)
+	if err != nil {
+		return nil, err
+	}
+	var rs []Resolution
+	for rows.Next() {
+		var r Resolution
+		var resolved string
+		if err := rows.Scan(&r.Event, &resolved, &r.Bets, &r.Winners, &r.Paid); err != nil {
+			return nil, err
+		}
+		r.Resolved, err = time.Parse(time.DateTime, resolved)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse resolved time %s: %v", resolved, err)
+		}
+		rs = append(rs, r)
+	}
+	return rs, nil
+}
+
+func (h *handler) leaderboard() ([]Rank, error) {
+	rows, err := h.core.Database.Ranking(db.RankBalance, 0, leaderboardSize)
+	if err != nil {
+		return nil, err
+	}
+	var ranks []Rank
+	for rows.Next() {
+		var r Rank
+		var balance float64
+		if err := rows.Scan(&r.Rank, &r.UID, &balance); err != nil {
+			return nil, err
+		}
+		r.Balance = int(balance)
+		ranks = append(ranks, r)
+	}
+	return ranks, nil
+}
+
+// distribution groups the bets by what they're on, biggest amount first.
+func distribution(bets []core.Bet) []Bar {
+	index := make(map[string]int)
+	bars := make([]Bar, 0)
+	for _, b := range bets {
+		i, ok := index[b.Bet]
+		if !ok {
+			i = len(bars)
+			index[b.Bet] = i
+			bars = append(bars, Bar{Label: b.Bet})
+		}
+		bars[i].Amount += b.Amount
+		bars[i].Bets++
+	}
+	slices.SortStableFunc(bars, func(a, b Bar) int {
+		return b.Amount - a.Amount
+	})
+	if len(bars) > distributionBars {
+		other := Bar{Label: "everything else"}
+		for _, b := range bars[distributionBars-1:] {
+			other.Amount += b.Amount
+			other.Bets += b.Bets
+		}
+		bars = append(bars[:distributionBars-1], other)
+	}
+	biggest := 0
+	for _, b := range bars {
+		biggest = max(biggest, b.Amount)
+	}
+	for i := range bars {
+		if biggest > 0 {
+			bars[i].Width = bars[i].Amount * 100 / biggest
+		}
+	}
+	return bars
+}
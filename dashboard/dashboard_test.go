@@ -0,0 +1,79 @@
+package dashboard
+
+import (
+	"bet/core"
+	"bet/core/db"
+	"bet/core/events"
+	"bet/env"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDashboard(t *testing.T) {
+	c := core.New(db.Fake(), nil, nil)
+	shiny := events.NewShinyEvent(c, env.OddsConfig{Base: 2}, env.PhaseEventConfig{}, "")
+	if err := c.RegisterEvent("shiny", shiny); err != nil {
+		t.Fatalf("RegisterEvent() returned unexpected error: %v", err)
+	}
+	now := time.Now()
+	for i, uid := range []string{"user1", "user2", "user3"} {
+		bet := events.PhaseBet{Direction: events.LESS, Phase: 3}
+		if uid == "user3" {
+			bet.Direction = events.GREATER
+		}
+		if _, err := shiny.Wager(uid, 100, now.Add(time.Duration(i)*time.Second), bet); err != nil {
+			t.Fatalf("Wager() returned unexpected error: %v", err)
+		}
+	}
+
+	s := httptest.NewServer(Handler(c))
+	defer s.Close()
+	resp, err := s.Client().Get(s.URL)
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("could not read body: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		t.Fatalf("GET = %d %s, want an html page", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	for _, want := range []string{
+		`<span class="state open">open</span>`,
+		"3 bets with 300 cakes in the pool.",
+		// Bets are escaped.
+		`<tr><td>phase &lt; 3</td><td class="number">2</td><td class="number">200</td><td><div class="bar" style="width: 100%"></div></td></tr>`,
+		`<tr><td>phase &gt; 3</td><td class="number">1</td><td class="number">100</td><td><div class="bar" style="width: 50%"></div></td></tr>`,
+		"Nothing has resolved yet.",
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("dashboard doesn't contain %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestDistribution(t *testing.T) {
+	var bets []core.Bet
+	for i := 0; i < distributionBars+2; i++ {
+		bets = append(bets, core.Bet{Bet: fmt.Sprintf("phase > %d", i), Amount: 100 + i})
+	}
+	got := distribution(bets)
+	if len(got) != distributionBars {
+		t.Fatalf("distribution() has %d bars, want %d", len(got), distributionBars)
+	}
+	if got[0].Label != "phase > 11" || got[0].Width != 36 {
+		t.Errorf("distribution() first bar = %+v, want phase > 11 at 36%% width", got[0])
+	}
+	// The grouped bar is the widest, since it's bigger than any single bet.
+	last := got[len(got)-1]
+	if last.Label != "everything else" || last.Bets != 3 || last.Amount != 303 || last.Width != 100 {
+		t.Errorf("distribution() last bar = %+v, want the 3 smallest bets grouped", last)
+	}
+}
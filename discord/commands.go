@@ -0,0 +1,376 @@
+package discord
+
+import (
+	"bet/core/db"
+	"bet/env"
+	"fmt"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// y tho.
+var integerOptionMinValue = 1.0
+
+// ApplicationCommands returns the definitions of every slash command, for the
+// events enabled in the config.
+func ApplicationCommands(conf env.EventConfig) []*discordgo.ApplicationCommand {
+	return []*discordgo.ApplicationCommand{
+		balanceCommand(),
+		betCommand(conf),
+		leaderboardCommand(),
+		betsCommand(),
+		donateCommand(),
+		ledgerCommand(conf),
+		soonCommand(conf),
+		seasonCommand(),
+		profileCommand(),
+		jackpotCommand(),
+		dailyCommand(),
+		loanCommand(),
+		linkCommand(),
+	}
+}
+
+func balanceCommand() *discordgo.ApplicationCommand {
+	return &discordgo.ApplicationCommand{
+		Name:        "balance",
+		Description: "See your current balance",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Name:        "user",
+				Description: "User's balance to view, leave empty to see your own",
+				Type:        discordgo.ApplicationCommandOptionUser,
+				Required:    false,
+			},
+		},
+	}
+}
+
+func betsCommand() *discordgo.ApplicationCommand {
+	return &discordgo.ApplicationCommand{
+		Name:        "bets",
+		Description: "See which bets you've placed",
+	}
+}
+
+func dailyCommand() *discordgo.ApplicationCommand {
+	return &discordgo.ApplicationCommand{
+		Name:        "daily",
+		Description: "Claim your daily allowance of cakes",
+	}
+}
+
+func jackpotCommand() *discordgo.ApplicationCommand {
+	return &discordgo.ApplicationCommand{
+		Name:        "jackpot",
+		Description: "See the jackpot paid out to exact phase bets, and its history",
+	}
+}
+
+func ledgerCommand(conf env.EventConfig) *discordgo.ApplicationCommand {
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, 0)
+	if conf.EnableShiny {
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  "shiny",
+			Value: "shiny",
+		})
+	}
+	if conf.EnableAnti {
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  "anti",
+			Value: "anti",
+		})
+	}
+	for _, itemConf := range conf.ItemEvent {
+		if itemConf.Enable {
+			name := itemConf.ID
+			if name == "" {
+				name = "item"
+			}
+			choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+				Name:  name,
+				Value: name,
+			})
+		}
+	}
+	return &discordgo.ApplicationCommand{
+		Name:        "ledger",
+		Description: "See a summary of bets focusing on impactful bets.",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Name:        "event",
+				Description: "Which event to see the bets for.",
+				Type:        discordgo.ApplicationCommandOptionString,
+				Required:    true,
+				Choices:     choices,
+			},
+		},
+	}
+}
+
+func soonCommand(conf env.EventConfig) *discordgo.ApplicationCommand {
+	// Bool events don't make sense with /soon, so "item" events are not set.
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, 0)
+	if conf.EnableShiny {
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  "shiny",
+			Value: "shiny",
+		})
+	}
+	if conf.EnableAnti {
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  "anti",
+			Value: "anti",
+		})
+	}
+	return &discordgo.ApplicationCommand{
+		Name:        "soon",
+		Description: "See a summary of bets focusing on upcoming bets.",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Name:        "event",
+				Description: "Which event to see the bets for.",
+				Type:        discordgo.ApplicationCommandOptionString,
+				Required:    true,
+				Choices:     choices,
+			},
+		},
+	}
+}
+
+func loanCommand() *discordgo.ApplicationCommand {
+	return &discordgo.ApplicationCommand{
+		Name:        "loan",
+		Description: "Borrow cakes against your future winnings",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Name:        "amount",
+				Description: "Cakes to borrow, leave empty to see your loan",
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Required:    false,
+				MinValue:    &integerOptionMinValue,
+			},
+		},
+	}
+}
+
+func linkCommand() *discordgo.ApplicationCommand {
+	return &discordgo.ApplicationCommand{
+		Name:        "link",
+		Description: "Bet with the same cakes from other chats, like Twitch",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Name:        "code",
+				Description: "Code from another chat to link here, leave empty to get a code",
+				Type:        discordgo.ApplicationCommandOptionString,
+				Required:    false,
+			},
+		},
+	}
+}
+
+func leaderboardCommand() *discordgo.ApplicationCommand {
+	return &discordgo.ApplicationCommand{
+		Name:        "leaderboard",
+		Description: "See the users with the most cakes",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Name:        "by",
+				Description: "What to rank users by, defaults to balance",
+				Type:        discordgo.ApplicationCommandOptionString,
+				Required:    false,
+				Choices: []*discordgo.ApplicationCommandOptionChoice{
+					{Name: "balance", Value: db.RankBalance},
+					{Name: "net profit", Value: db.RankProfit},
+					{Name: "season profit", Value: db.RankSeasonProfit},
+					{Name: "win rate", Value: db.RankWinRate},
+					{Name: "biggest win", Value: db.RankBiggestWin},
+				},
+			},
+			{
+				Name:        "page",
+				Description: "Which page of the leaderboard to see",
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Required:    false,
+				MinValue:    &integerOptionMinValue,
+			},
+			{
+				Name:        "around-me",
+				Description: "See the ranks around your own instead of a page",
+				Type:        discordgo.ApplicationCommandOptionBoolean,
+				Required:    false,
+			},
+		},
+	}
+}
+
+func donateCommand() *discordgo.ApplicationCommand {
+	return &discordgo.ApplicationCommand{
+		Name:        "donate",
+		Description: "Donate cakes to another user",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Name:        "amount",
+				Description: "How many cakes to give",
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Required:    true,
+			},
+			{
+				Name:        "to",
+				Description: "User to give cakes to",
+				Type:        discordgo.ApplicationCommandOptionUser,
+				Required:    true,
+			},
+		},
+	}
+}
+
+func profileCommand() *discordgo.ApplicationCommand {
+	return &discordgo.ApplicationCommand{
+		Name:        "profile",
+		Description: "See a user's balance, lifetime stats and achievements",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Name:        "user",
+				Description: "User's profile to view, leave empty to see your own",
+				Type:        discordgo.ApplicationCommandOptionUser,
+				Required:    false,
+			},
+		},
+	}
+}
+
+func seasonCommand() *discordgo.ApplicationCommand {
+	return &discordgo.ApplicationCommand{
+		Name:        "season",
+		Description: "See the standings of the current or a past season",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Name:        "number",
+				Description: "Which past season to see, leave empty to see the current season",
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Required:    false,
+				MinValue:    &integerOptionMinValue,
+			},
+		},
+	}
+}
+
+func betCommand(conf env.EventConfig) *discordgo.ApplicationCommand {
+	options := make([]*discordgo.ApplicationCommandOption, 0)
+	if conf.EnableShiny {
+		options = append(options, &discordgo.ApplicationCommandOption{
+			Name:        "shiny",
+			Description: "Place a bet on the phase length of this shiny encounter",
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Options:     phaseOptions(),
+		})
+	}
+	if conf.EnableAnti {
+		options = append(options, &discordgo.ApplicationCommandOption{
+			Name:        "anti",
+			Description: "Place a bet on the phase length of this anti shiny encounter",
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Options:     phaseOptions(),
+		})
+	}
+	for _, itemConf := range conf.ItemEvent {
+		if itemConf.Enable {
+			name := itemConf.ID
+			if name == "" {
+				name = "item"
+			}
+			options = append(options, &discordgo.ApplicationCommandOption{
+				Name:        name,
+				Description: fmt.Sprintf("Place a bet on whether %s will hold %s", itemConf.Species, itemConf.Item),
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Options:     boolOptions(),
+			})
+		}
+	}
+	return &discordgo.ApplicationCommand{
+		Name:        "bet",
+		Description: "Place a bet on when an event will happen",
+		Options:     options,
+	}
+}
+
+func phaseOptions() []*discordgo.ApplicationCommandOption {
+	return []*discordgo.ApplicationCommandOption{
+		{
+			Name:        "amount",
+			Description: "How many cakes to wager",
+			Type:        discordgo.ApplicationCommandOptionInteger,
+			Required:    true,
+			MinValue:    &integerOptionMinValue,
+		},
+		{
+			Name:        "over-under",
+			Description: "Whether to bet over or under the phase lenth",
+			Type:        discordgo.ApplicationCommandOptionString,
+			Required:    true,
+			Choices: []*discordgo.ApplicationCommandOptionChoice{
+				{
+					Name:  "over",
+					Value: ">",
+				},
+				{
+					Name:  ">",
+					Value: ">",
+				},
+				{
+					Name:  "greater than",
+					Value: ">",
+				},
+				{
+					Name:  "under",
+					Value: "<",
+				},
+				{
+					Name:  "<",
+					Value: "<",
+				},
+				{
+					Name:  "less than",
+					Value: "<",
+				},
+				{
+					Name:  "equal",
+					Value: "=",
+				},
+				{
+					Name:  "exact",
+					Value: "=",
+				},
+				{
+					Name:  "=",
+					Value: "=",
+				},
+			},
+		},
+		{
+			Name:        "phase",
+			Description: "Phase length",
+			Type:        discordgo.ApplicationCommandOptionInteger,
+			Required:    true,
+		},
+	}
+}
+
+func boolOptions() []*discordgo.ApplicationCommandOption {
+	return []*discordgo.ApplicationCommandOption{
+		{
+			Name:        "amount",
+			Description: "How many cakes to wager",
+			Type:        discordgo.ApplicationCommandOptionInteger,
+			Required:    true,
+			MinValue:    &integerOptionMinValue,
+		},
+		{
+			Name:        "guess",
+			Description: "Will it hold the item?",
+			Type:        discordgo.ApplicationCommandOptionBoolean,
+			Required:    true,
+		},
+	}
+}
@@ -0,0 +1,229 @@
+// Package discord adapts the platform-neutral commands to Discord's slash
+// commands, and sends core's messages to Discord channels.
+package discord
+
+import (
+	"bet/core"
+	"bet/core/commands"
+	"bet/env"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// pageButtonPrefix prefixes the custom id of every Prev/Next button on paged
+// messages, so button presses can be routed to the right page.
+const pageButtonPrefix = "page"
+
+// noMentions lets users be tagged by ID so their name appears, without pinging
+// them every time a command mentions them.
+var noMentions = &discordgo.MessageAllowedMentions{
+	Parse: []discordgo.AllowedMentionType{},
+}
+
+// Messenger sends core's messages to Discord channels.
+type Messenger struct {
+	Session *discordgo.Session
+}
+
+func (m Messenger) Send(channel, message string) error {
+	_, err := m.Session.ChannelMessageSendComplex(channel, &discordgo.MessageSend{
+		Content: message,
+		// By default, we don't allow mentions so there's so the bot doesn't
+		// ping people awake.
+		AllowedMentions: noMentions,
+	})
+	return err
+}
+
+// Bot runs commands from Discord interactions.
+type Bot struct {
+	session    *discordgo.Session
+	core       *core.Core
+	commands   map[string]commands.Command
+	registered []*discordgo.ApplicationCommand
+}
+
+// NewBot handles the interactions of the session with the commands, which are
+// keyed by their slash command name.
+func NewBot(s *discordgo.Session, c *core.Core, cs map[string]commands.Command) *Bot {
+	b := &Bot{session: s, core: c, commands: cs}
+	s.AddHandler(b.interaction)
+	return b
+}
+
+// Register registers the slash commands that have handlers with Discord.
+func (b *Bot) Register(appID, guildID string, conf env.EventConfig) error {
+	definitions := make([]*discordgo.ApplicationCommand, 0, len(b.commands))
+	for _, d := range ApplicationCommands(conf) {
+		if _, ok := b.commands[d.Name]; ok {
+			definitions = append(definitions, d)
+		}
+	}
+	registered, err := b.session.ApplicationCommandBulkOverwrite(appID, guildID, definitions)
+	if err != nil {
+		return err
+	}
+	b.registered = registered
+	return nil
+}
+
+// Unregister removes the slash commands registered by Register.
+func (b *Bot) Unregister(appID, guildID string) {
+	for _, c := range b.registered {
+		if err := b.session.ApplicationCommandDelete(appID, guildID, c.ID); err != nil {
+			slog.Error(fmt.Sprintf("error removing command: %s", err))
+		}
+	}
+}
+
+func (b *Bot) interaction(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error(fmt.Sprintf("recovering from panic in discord command handler: %s", r))
+		}
+	}()
+	switch i.Type {
+	case discordgo.InteractionApplicationCommand:
+		if h, ok := b.commands[i.ApplicationCommandData().Name]; ok {
+			respond(s, i, h.Handle(b.request(i)))
+		}
+	case discordgo.InteractionMessageComponent:
+		if strings.HasPrefix(i.MessageComponentData().CustomID, pageButtonPrefix+":") {
+			b.page(s, i)
+		}
+	}
+}
+
+// request converts the interaction into a platform-neutral request.  Discord
+// users are resolved to the accounts their ids are linked to.
+func (b *Bot) request(i *discordgo.InteractionCreate) commands.Request {
+	identity := i.Interaction.Member.User.ID
+	r := commands.Request{
+		UID:      b.core.Account(identity),
+		Identity: identity,
+		Options:  make(map[string]any),
+	}
+	var err error
+	r.Time, err = discordgo.SnowflakeTimestamp(i.ID)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("could not get timestamp from id: %v", err))
+		// We at least have a fallback for this one
+		r.Time = time.Now()
+	}
+	options := i.ApplicationCommandData().Options
+	if len(options) > 0 && options[0].Type == discordgo.ApplicationCommandOptionSubCommand {
+		r.Subcommand = options[0].Name
+		options = options[0].Options
+	}
+	for _, o := range options {
+		switch o.Type {
+		case discordgo.ApplicationCommandOptionInteger:
+			r.Options[o.Name] = int(o.IntValue())
+		case discordgo.ApplicationCommandOptionBoolean:
+			r.Options[o.Name] = o.BoolValue()
+		case discordgo.ApplicationCommandOptionString:
+			r.Options[o.Name] = o.StringValue()
+		case discordgo.ApplicationCommandOptionUser:
+			// The value of a user option is the user's id.
+			r.Options[o.Name] = b.core.Account(o.Value.(string))
+		}
+	}
+	return r
+}
+
+// respond renders the response to the interaction.
+func respond(s *discordgo.Session, i *discordgo.InteractionCreate, r commands.Response) {
+	data := &discordgo.InteractionResponseData{
+		Content:         r.Content,
+		AllowedMentions: noMentions,
+	}
+	if r.Private {
+		data.Flags = discordgo.MessageFlagsEphemeral
+	}
+	if r.Page != nil {
+		data.Content = ""
+		data.Embeds = []*discordgo.MessageEmbed{pageEmbed(*r.Page)}
+		data.Components = pageButtons(*r.Page)
+	}
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: data,
+	})
+}
+
+// page handles the Prev/Next buttons on paged messages by rendering the page
+// in place.
+func (b *Bot) page(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	customID := i.MessageComponentData().CustomID
+	slog.Debug("page interaction started", "id", customID)
+	page, err := b.pageFor(customID)
+	if err != nil {
+		slog.Info(fmt.Sprintf("could not page message: %v", err))
+		respond(s, i, commands.Response{Content: "This message can no longer be paged through.", Private: true})
+		return
+	}
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Embeds:     []*discordgo.MessageEmbed{pageEmbed(page)},
+			Components: pageButtons(page),
+		},
+	})
+}
+
+// pageFor renders the page a button's custom id points to.
+func (b *Bot) pageFor(customID string) (core.Page, error) {
+	parts := strings.Split(customID, ":")
+	if len(parts) != 3 || parts[0] != pageButtonPrefix {
+		return core.Page{}, fmt.Errorf("malformed page button id %q", customID)
+	}
+	index, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return core.Page{}, fmt.Errorf("malformed page index in %q: %v", customID, err)
+	}
+	return b.core.Page(parts[1], index)
+}
+
+func pageEmbed(page core.Page) *discordgo.MessageEmbed {
+	embed := &discordgo.MessageEmbed{
+		Title:       page.Title,
+		Description: page.Content,
+	}
+	if page.Total > 1 {
+		embed.Footer = &discordgo.MessageEmbedFooter{
+			Text: fmt.Sprintf("Page %d/%d", page.Index+1, page.Total),
+		}
+	}
+	return embed
+}
+
+// pageButtons returns the buttons to page through the message, or nil when
+// everything fits on one page.
+func pageButtons(page core.Page) []discordgo.MessageComponent {
+	if page.ID == "" {
+		return nil
+	}
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    "Prev",
+					Style:    discordgo.SecondaryButton,
+					CustomID: fmt.Sprintf("%s:%s:%d", pageButtonPrefix, page.ID, page.Index-1),
+					Disabled: page.Index == 0,
+				},
+				discordgo.Button{
+					Label:    "Next",
+					Style:    discordgo.SecondaryButton,
+					CustomID: fmt.Sprintf("%s:%s:%d", pageButtonPrefix, page.ID, page.Index+1),
+					Disabled: page.Index == page.Total-1,
+				},
+			},
+		},
+	}
+}
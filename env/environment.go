@@ -1,6 +1,8 @@
 package env
 
 import (
+	"fmt"
+	"math"
 	"os"
 	"time"
 
@@ -23,6 +25,14 @@ type Environment struct {
 	// and address that a pokebot can send HTTP POST requests to to track when
 	// shinies happen.
 	Port int
+	// ConsoleSocket is the path of the Unix socket to serve the operator
+	// console on, for the console client to connect to.  When empty, the
+	// console is only on stdin.
+	ConsoleSocket string
+	// EnableDashboard serves a page at /dashboard on the same address showing
+	// the events, recent resolutions and the leaderboard.  Only the addresses
+	// in PostAcl can see it, so PostAcl is required with it.
+	EnableDashboard bool
 	// PostAcl is a list of IP Addresses to accept POST messages from.  When the
 	// list is empty ALL requests will be accepted..
 	PostAcl []string
@@ -35,6 +45,18 @@ type Environment struct {
 	Events EventConfig
 	// Crons contains all the configuration for cron jobs.
 	Crons CronConfig
+	// Allowance configures how users are given cakes so they can keep playing.
+	Allowance AllowanceConfig
+	// Loans configures how users can borrow cakes against future winnings.
+	Loans LoanConfig
+	// Limits configures how much users can bet.
+	Limits LimitsConfig
+	// Twitch configures taking commands from Twitch chat.
+	Twitch TwitchConfig
+	// API configures the HTTP API used by overlays and web frontends.
+	API APIConfig
+	// Admin configures the admin endpoints served next to /metrics.
+	Admin AdminConfig
 }
 
 // EventConfig contains all the ways to configure which events are run.
@@ -43,6 +65,13 @@ type EventConfig struct {
 	EnableShiny bool
 	// Enables the anti shiny event
 	EnableAnti bool
+	// Odds configures the per-encounter probability of a shiny, used by both
+	// the shiny and anti shiny events.
+	Odds OddsConfig
+	// Shiny and Anti configure how the shiny and anti shiny events price and
+	// pay out bets.
+	Shiny PhaseEventConfig
+	Anti  PhaseEventConfig
 	// Configures the held item event
 	ItemEvent []ItemEventConfig
 }
@@ -66,6 +95,107 @@ type ItemEventConfig struct {
 	// is true.
 	KeepOpen          bool
 	KeepOpenCondition Condition
+	// LockoutBefore refunds bets placed within this long before the event
+	// closed, since viewers may have seen the shiny on stream before the bot
+	// closed the event.
+	LockoutBefore time.Duration
+}
+
+// OddsConfig describes the hunt, from which the per-encounter probability of a
+// shiny is computed.
+type OddsConfig struct {
+	// Base is the denominator of the base shiny odds, e.g. 8192 for Gen 3 or
+	// 4096 for Gen 6 onwards.  Defaults to 8192 when empty.
+	Base int
+	// ShinyCharm gives 2 extra rolls at a shiny on each encounter.
+	ShinyCharm bool
+	// Method is the hunting method.  Methods other than "random" give extra
+	// rolls on each encounter, see HuntMethodRolls.
+	Method string
+}
+
+// DefaultOddsBase is the base shiny odds denominator when none is configured.
+const DefaultOddsBase = 8192
+
+// HuntMethodRolls is how many extra rolls at a shiny each hunting method gives
+// on every encounter.
+var HuntMethodRolls = map[string]int{
+	"":       0,
+	"random": 0,
+	// The Masuda method as of Gen 5.
+	"masuda": 5,
+}
+
+// Probability returns the probability of each encounter being shiny.
+func (o OddsConfig) Probability() (float64, error) {
+	base := o.Base
+	if base == 0 {
+		base = DefaultOddsBase
+	}
+	if base < 0 {
+		return 0, fmt.Errorf("base odds must be positive, got %d", base)
+	}
+	extra, ok := HuntMethodRolls[o.Method]
+	if !ok {
+		return 0, fmt.Errorf("unknown hunt method %q", o.Method)
+	}
+	rolls := 1 + extra
+	if o.ShinyCharm {
+		rolls += 2
+	}
+	// Each roll is independent, so the encounter is shiny unless every roll
+	// fails.
+	return 1 - math.Pow(1-1/float64(base), float64(rolls)), nil
+}
+
+// PhaseEventConfig configures how a phase event prices and pays out bets.
+type PhaseEventConfig struct {
+	OddsModel OddsModelConfig
+	Payout    PayoutConfig
+	// RakePercent is the percentage of the losing stakes taken into the
+	// jackpot when bets pay out pari-mutuel.  The jackpot is paid out to
+	// winning bets on the exact phase.
+	RakePercent float64
+	// LockoutEncounters rejects bets on a phase within this many encounters of
+	// the current phase, since the stream lags behind the bot.
+	LockoutEncounters int
+	// LockoutBefore refunds bets placed within this long before the event
+	// closed, since viewers may have seen the shiny on stream before the bot
+	// closed the event.
+	LockoutBefore time.Duration
+	// RiskDecay is the fraction of a bet's weight in the payout lost by
+	// placing it at the very end of the phase, falling linearly from nothing
+	// for bets placed at open.  Only used when bets pay out pari-mutuel.
+	RiskDecay float64
+}
+
+// OddsModelConfig picks how an event estimates the per-encounter probability
+// from the configured odds.
+type OddsModelConfig struct {
+	// Model is either "fixed" (the default), which always uses the configured
+	// odds, or "empirical", which learns the odds from the recorded phase
+	// history using the configured odds as a prior.
+	Model string
+	// PriorPhases is how many phases worth of evidence the configured odds
+	// count for in the empirical model.  Defaults to 1.
+	PriorPhases float64
+}
+
+// PayoutConfig picks how the winners of an event are paid.
+type PayoutConfig struct {
+	// Mode is either "pari-mutuel" (the default), where winners split the
+	// stakes of the losers, or "fixed", where the bank pays each winner
+	// amount * risk/(1-risk) and keeps the stakes of the losers.
+	Mode string
+	// Bank is the user id of the house paying winners in fixed mode.
+	Bank string
+	// ExposureLimit is the most the bank can owe across all open bets in fixed
+	// mode.  When zero, the bank is only limited by its balance.
+	ExposureLimit int
+	// Funding is what the bank is topped up to, on top of what's reserved for
+	// open bets, when the event starts and after every resolve.  When zero,
+	// the bank only has the cakes it wins.
+	Funding int
 }
 
 // This would be a whole bag of worms to try to do generically, so just making a
@@ -76,6 +206,7 @@ type Condition struct {
 
 type CronConfig struct {
 	SelfBet SelfBetConfig
+	Season  SeasonConfig
 }
 
 type SelfBetConfig struct {
@@ -84,6 +215,92 @@ type SelfBetConfig struct {
 	Every time.Duration
 }
 
+type SeasonConfig struct {
+	Enable bool
+	// Length is how long each season lasts.  At the end of a season the final
+	// leaderboard is archived and every balance is reset.
+	Length time.Duration
+}
+
+type AllowanceConfig struct {
+	// Floor is the balance users are bailed out to after an event resolves
+	// when they've gone below it.  Defaults to 100 when empty.
+	Floor int
+	// BailoutCooldown is the least time between two bailouts of the same
+	// user.  When empty, users can be bailed out after every event.
+	BailoutCooldown time.Duration
+	// Daily is the allowance users can claim once a day with /daily.  When
+	// empty, /daily is disabled.
+	Daily int
+	// StreakBonus is added to the daily allowance for every day in a row it's
+	// been claimed, up to MaxStreak days.
+	StreakBonus int
+	MaxStreak   int
+}
+
+type LoanConfig struct {
+	// Base is how much any user can borrow, and PerBet is how much more they
+	// can borrow for every one of their bets that has resolved.
+	Base   int
+	PerBet int
+	// Max caps how much any user can borrow.  When empty, loans are disabled.
+	Max int
+	// InterestPercent is added to what's owed every time an event resolves.
+	InterestPercent float64
+	// Term is how many events can resolve before an unpaid loan defaults.
+	// When empty, loans never default.
+	Term int
+}
+
+// LimitsConfig bounds how much users can bet.  Limits left empty aren't
+// enforced.
+type LimitsConfig struct {
+	// MinBet and MaxBet bound the amount of a single bet.
+	MinBet int
+	MaxBet int
+	// MaxEventExposure is the most a user can have bet on one event in total.
+	MaxEventExposure int
+	// MaxOpenBets is the most bets a user can have open across all events.
+	MaxOpenBets int
+}
+
+// TwitchConfig configures the Twitch chat bot, which takes commands from stream
+// chat and posts event messages there.
+type TwitchConfig struct {
+	Enable bool
+	// Server is the address of the chat's IRC server.  Defaults to Twitch's
+	// own when empty.
+	Server string
+	// Nick is the login of the bot's Twitch account, and Token is its OAuth
+	// token with the chat:read and chat:edit scopes.
+	Nick  string
+	Token string
+	// Channel is the chat to join, which is the streamer's login.
+	Channel string
+}
+
+// APIConfig configures the HTTP API, which is served separately from the
+// listener on Host and Port.  Clients authenticate with tokens made with the
+// `token` cli command.
+type APIConfig struct {
+	Enable bool
+	Host   string
+	Port   int
+	// CancelWindow is how long after placing a bet it can be cancelled through
+	// the API.  When empty, bets can't be cancelled.
+	CancelWindow time.Duration
+}
+
+// AdminConfig configures the admin endpoints, which are served under /admin/
+// on the metrics port.
+type AdminConfig struct {
+	// Token must be given as a bearer token with every admin request.  When
+	// empty, the admin endpoints aren't served.
+	Token string
+}
+
+// LoadEnvironemnt loads the environment from .env, and returns every problem
+// found validating it.
 func LoadEnvironemnt() (*Environment, error) {
 	e := &Environment{}
 	data, err := os.ReadFile(".env")
@@ -93,5 +310,8 @@ func LoadEnvironemnt() (*Environment, error) {
 	if err := yaml.Unmarshal(data, e); err != nil {
 		return nil, err
 	}
+	if err := e.Validate(); err != nil {
+		return nil, err
+	}
 	return e, nil
 }
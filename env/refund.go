@@ -29,6 +29,8 @@ type RefundEvent struct {
 	// The final state to use
 	Actual      bool
 	ActualPhase int
+	// The odds the event was run with, only used for phase events.
+	Odds OddsConfig
 }
 
 func LoadRefundEnvironment() (*RefundEnv, error) {
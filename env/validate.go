@@ -0,0 +1,148 @@
+package env
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// ConfigError is a problem with a single field of the environment.
+type ConfigError struct {
+	// Field is the path of the field in the .env file, e.g.
+	// "Events.ItemEvent[1].Species".
+	Field   string
+	Problem string
+}
+
+func (e ConfigError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Problem)
+}
+
+// commandName is what Discord accepts as the name of a slash command or
+// subcommand.  Letters must also be lowercase.
+var commandName = regexp.MustCompile(`^[-_\p{L}\p{N}]{1,32}$`)
+
+const (
+	// maxCommandOptions is the most subcommands or choices Discord allows on a
+	// single option.
+	maxCommandOptions = 25
+	// maxDescription is the longest description Discord allows.
+	maxDescription = 100
+)
+
+// Validate checks the environment for problems that would stop the bot from
+// starting properly, and returns all of them joined together, so they can be
+// fixed at once.
+func (e *Environment) Validate() error {
+	errs := make([]error, 0)
+	problem := func(field, format string, args ...any) {
+		errs = append(errs, ConfigError{Field: field, Problem: fmt.Sprintf(format, args...)})
+	}
+
+	if e.Token == "" {
+		problem("Token", "is required to log in to discord")
+	}
+	if e.AppId == "" {
+		problem("AppId", "is required to register commands")
+	}
+	if err := validateDbName(e.DbName); err != nil {
+		problem("DbName", "%v", err)
+	}
+	if e.EnableDashboard && len(e.PostAcl) == 0 {
+		problem("PostAcl", "is required with the dashboard enabled, since the dashboard shows everyone's balances and bets")
+	}
+
+	events := e.Events
+	if events.EnableShiny || events.EnableAnti {
+		if _, err := events.Odds.Probability(); err != nil {
+			problem("Events.Odds", "%v", err)
+		}
+	}
+	// Phase events are bet on with the subcommands "shiny" and "anti", so
+	// item events can't use those ids.
+	seen := map[string]string{"shiny": "the shiny event", "anti": "the anti shiny event"}
+	subcommands := 0
+	if events.EnableShiny {
+		subcommands++
+	}
+	if events.EnableAnti {
+		subcommands++
+	}
+	for i, itemConf := range events.ItemEvent {
+		field := fmt.Sprintf("Events.ItemEvent[%d]", i)
+		id := itemConf.ID
+		if id == "" {
+			id = "item"
+		}
+		if other, ok := seen[id]; ok {
+			problem(field+".ID", "%q is already used by %s", id, other)
+		} else {
+			seen[id] = field
+		}
+		if !itemConf.Enable {
+			continue
+		}
+		subcommands++
+		if !commandName.MatchString(id) || strings.ToLower(id) != id {
+			problem(field+".ID", "%q isn't a valid discord subcommand name, which is 1 to 32 lowercase letters, numbers, - or _", id)
+		}
+		if itemConf.Species == "" {
+			problem(field+".Species", "is required")
+		}
+		if itemConf.Item == "" {
+			problem(field+".Item", "is required")
+		}
+		if itemConf.Probability <= 0 || itemConf.Probability >= 1 {
+			problem(field+".Probability", "must be between 0 and 1, got %v", itemConf.Probability)
+		}
+		description := fmt.Sprintf("Place a bet on whether %s will hold %s", itemConf.Species, itemConf.Item)
+		if utf8.RuneCountInString(description) > maxDescription {
+			problem(field, "species and item are too long for the discord command description, which is at most %d characters", maxDescription)
+		}
+	}
+	if subcommands > maxCommandOptions {
+		problem("Events", "%d events are enabled, but discord allows at most %d subcommands of /bet", subcommands, maxCommandOptions)
+	}
+
+	if e.Crons.SelfBet.Enable && e.Crons.SelfBet.Every <= 0 {
+		problem("Crons.SelfBet.Every", "must be positive when the self bet cron is enabled")
+	}
+	if e.Crons.Season.Enable && e.Crons.Season.Length <= 0 {
+		problem("Crons.Season.Length", "must be positive when the season cron is enabled")
+	}
+	return errors.Join(errs...)
+}
+
+// validateDbName checks the database name for the problems the sqlite driver
+// refuses it for when opening.  Anything after the first "?" is parsed as
+// query parameters, of which the driver checks vfs, _time_format and _txlock.
+func validateDbName(name string) error {
+	if name == "" {
+		return fmt.Errorf("is required")
+	}
+	pos := strings.IndexRune(name, '?')
+	if pos < 1 {
+		return nil
+	}
+	q, err := url.ParseQuery(name[pos+1:])
+	if err != nil {
+		return fmt.Errorf("%q has invalid parameters: %v", name, err)
+	}
+	for _, vfs := range q["vfs"] {
+		if vfs != q.Get("vfs") {
+			return fmt.Errorf("%q has conflicting vfs parameters %v", name, q["vfs"])
+		}
+	}
+	if v := q.Get("_time_format"); v != "" && v != "sqlite" {
+		return fmt.Errorf("%q has unknown _time_format %q, the only one is \"sqlite\"", name, v)
+	}
+	switch v := q.Get("_txlock"); strings.ToLower(v) {
+	case "", "deferred", "immediate", "exclusive":
+	default:
+		return fmt.Errorf("%q has unknown _txlock %q, want deferred, immediate or exclusive", name, v)
+	}
+	return nil
+}
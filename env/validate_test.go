@@ -0,0 +1,87 @@
+package env
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func validEnvironment() *Environment {
+	return &Environment{
+		Token:  "token",
+		AppId:  "app",
+		DbName: "bet.db",
+		Events: EventConfig{
+			EnableShiny: true,
+			ItemEvent: []ItemEventConfig{
+				{Enable: true, Species: "Zigzagoon", Item: "Potion", ID: "potion", Probability: 0.5},
+			},
+		},
+		Crons: CronConfig{SelfBet: SelfBetConfig{Enable: true, Every: time.Hour}},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := validEnvironment().Validate(); err != nil {
+		t.Fatalf("Validate() of a valid environment returned unexpected error: %v", err)
+	}
+
+	e := validEnvironment()
+	e.Token = ""
+	e.DbName = "bet.db?mode=%zz"
+	e.EnableDashboard = true
+	e.Events.ItemEvent = append(e.Events.ItemEvent,
+		ItemEventConfig{Enable: true, Species: "Zigzagoon", ID: "potion", Probability: 0.5},
+		ItemEventConfig{Enable: true, Species: "Zigzagoon", Item: "Potion", ID: "Big Potion", Probability: 1},
+		ItemEventConfig{ID: "shiny"},
+	)
+	e.Crons.SelfBet.Every = 0
+	err := e.Validate()
+	want := []ConfigError{
+		{Field: "Token"},
+		{Field: "DbName"},
+		{Field: "PostAcl"},
+		{Field: "Events.ItemEvent[1].ID"},
+		{Field: "Events.ItemEvent[1].Item"},
+		{Field: "Events.ItemEvent[2].ID"},
+		{Field: "Events.ItemEvent[2].Probability"},
+		{Field: "Events.ItemEvent[3].ID"},
+		{Field: "Crons.SelfBet.Every"},
+	}
+	var got []ConfigError
+	for _, err := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var configErr ConfigError
+		if !errors.As(err, &configErr) {
+			t.Fatalf("Validate() returned %v, want only ConfigErrors", err)
+		}
+		got = append(got, ConfigError{Field: configErr.Field})
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Validate() = %v, want problems with %v", err, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Validate() problem %d is with %s, want %s", i, got[i].Field, want[i].Field)
+		}
+	}
+}
+
+func TestValidateDbName(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		wantErr bool
+	}{
+		{name: "bet.db"},
+		{name: "file:bet.db?_txlock=IMMEDIATE&_time_format=sqlite"},
+		{name: "bet.db?_pragma=foreign_keys(1)&vfs=a&vfs=a"},
+		{name: "", wantErr: true},
+		{name: "bet.db?mode=%zz", wantErr: true},
+		{name: "bet.db?vfs=a&vfs=b", wantErr: true},
+		{name: "bet.db?_time_format=rfc3339", wantErr: true},
+		{name: "file:bet.db?_txlock=later", wantErr: true},
+	} {
+		if err := validateDbName(tc.name); (err != nil) != tc.wantErr {
+			t.Errorf("validateDbName(%q) = %v, want error %t", tc.name, err, tc.wantErr)
+		}
+	}
+}
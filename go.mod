@@ -5,6 +5,7 @@ go 1.22.3
 require (
 	github.com/bwmarrin/discordgo v0.28.1
 	github.com/prometheus/client_golang v1.21.1
+	gopkg.in/yaml.v2 v2.4.0
 	modernc.org/sqlite v1.36.0
 )
 
@@ -26,7 +27,6 @@ require (
 	golang.org/x/exp v0.0.0-20230315142452-642cacee5cc0 // indirect
 	golang.org/x/sys v0.30.0 // indirect
 	google.golang.org/protobuf v1.36.1 // indirect
-	gopkg.in/yaml.v2 v2.4.0 // indirect
 	modernc.org/libc v1.61.13 // indirect
 	modernc.org/mathutil v1.7.1 // indirect
 	modernc.org/memory v1.8.2 // indirect
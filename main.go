@@ -1,30 +1,30 @@
 package main
 
 import (
+	"bet/admin"
+	"bet/api"
 	"bet/cli"
 	"bet/core"
 	"bet/core/commands"
 	"bet/core/crons"
 	"bet/core/db"
-	"bet/core/events"
+	"bet/dashboard"
+	"bet/discord"
 	"bet/env"
 	"bet/state"
+	"bet/twitch"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-type Command interface {
-	Command() *discordgo.ApplicationCommand
-	Interaction(s *discordgo.Session, i *discordgo.InteractionCreate)
-}
-
 type realClock struct{}
 
 func (realClock) Now() time.Time                         { return time.Now() }
@@ -35,7 +35,7 @@ func main() {
 	nowStr := time.Now().Format("060102_150405")
 	logFile, err := os.Create(fmt.Sprintf("bet_%s.log", nowStr))
 	if err != nil {
-		fmt.Println("error creating a log file: %v", err)
+		fmt.Printf("error creating a log file: %v\n", err)
 		return
 	}
 	logger := slog.New(slog.NewTextHandler(logFile, &slog.HandlerOptions{Level: cli.LogLevel}))
@@ -69,13 +69,22 @@ func main() {
 	}
 	defer database.Close()
 
+	// Messages are sent to Discord, and to Twitch chat when it's enabled.
+	var messenger core.Messenger = discord.Messenger{Session: dg}
+	var chat *twitch.Client
+	if environment.Twitch.Enable {
+		chat = twitch.New(environment.Twitch)
+		messenger = core.Messengers{messenger, chat}
+	}
+
 	// Create the core.
-	core := core.New(database, dg, realClock{})
+	core := core.New(database, messenger, realClock{})
 	if core == nil {
 		slog.Error("could not create core, exiting")
 		return
 	}
 	defer core.Close()
+	ApplyPolicies(core, environment)
 
 	// Create Events/Updaters/State objects.
 	// _ = updater.NewShinyUpdater(core, dg)
@@ -85,38 +94,47 @@ func main() {
 		return
 	}
 	defer l.Close()
+	if environment.EnableDashboard {
+		l.Handle("GET /dashboard", dashboard.Handler(core))
+	}
 	if err := StartEvents(core, l, environment.DiscordChannel, environment.Events); err != nil {
 		return
 	}
 
+	if environment.API.Enable {
+		apiServer, err := api.NewServer(core, fmt.Sprintf("%s:%d", environment.API.Host, environment.API.Port))
+		if err != nil {
+			slog.Error(fmt.Sprintf("err creating api server: %s", err))
+			return
+		}
+		defer apiServer.Close()
+	}
+
 	// Command initialization and registration.
-	cs := map[string]Command{
+	betCommand := commands.NewBetCommand(core, environment.Events)
+	cs := map[string]commands.Command{
 		"balance":     &commands.BalanceCommand{Core: core},
-		"bet":         commands.NewBetCommand(core, environment.Events),
+		"bet":         betCommand,
 		"leaderboard": &commands.LeaderboardCommand{Core: core},
 		"bets":        &commands.ListBetsCommand{Core: core},
 		"donate":      &commands.DonateCommand{Core: core},
-		"ledger":      commands.NewLedgerCommand(core, environment.Events),
-		"soon":        commands.NewSoonCommand(core, environment.Events),
-	}
-	dg.AddHandler(func(s *discordgo.Session, i *discordgo.InteractionCreate) {
-		defer func() {
-			if r := recover(); r != nil {
-				slog.Error(fmt.Sprintf("recovering from panic in discord command handler: %s", r))
-			}
-		}()
-		if h, ok := cs[i.ApplicationCommandData().Name]; ok {
-			h.Interaction(s, i)
-		}
-	})
-	commandList := make([]*discordgo.ApplicationCommand, 0, len(cs))
-	for _, c := range cs {
-		commandList = append(commandList, c.Command())
-	}
-	registeredCommands, err := dg.ApplicationCommandBulkOverwrite(environment.AppId, environment.DiscordServer, commandList)
-	if err != nil {
+		"ledger":      commands.NewLedgerCommand(core),
+		"soon":        commands.NewSoonCommand(core),
+		"season":      commands.NewSeasonCommand(core, environment.Crons.Season),
+		"profile":     &commands.ProfileCommand{Core: core},
+		"jackpot":     &commands.JackpotCommand{Core: core},
+		"daily":       &commands.DailyCommand{Core: core},
+		"loan":        &commands.LoanCommand{Core: core},
+		"link":        &commands.LinkCommand{Core: core},
+	}
+	bot := discord.NewBot(dg, core, cs)
+	if err := bot.Register(environment.AppId, environment.DiscordServer, environment.Events); err != nil {
 		slog.Error(fmt.Sprintf("Failed to bulk register commands: %v", err))
 	}
+	if chat != nil {
+		go chat.Serve(core, cs)
+		defer chat.Close()
+	}
 
 	// Open the session to start the bot running.
 	err = dg.Open()
@@ -128,55 +146,47 @@ func main() {
 
 	AddCrons(core, environment)
 
-	go cli.Loop()
+	reload := NewReloader(core, l, bot, betCommand, environment).Reload
+	console := cli.NewConsole(core, os.Stdin, os.Stdout)
+	console.Reload = reload
+	go console.Loop()
+	if environment.ConsoleSocket != "" {
+		socket, err := cli.Listen(core, environment.ConsoleSocket)
+		if err != nil {
+			slog.Error(fmt.Sprintf("err creating console socket: %s", err))
+			return
+		}
+		socket.Reload = reload
+		go socket.Serve()
+		defer socket.Close()
+	}
 
 	http.Handle("/metrics", promhttp.Handler())
+	if environment.Admin.Token != "" {
+		http.Handle("/admin/", admin.Handler(core, environment.Admin.Token, cli.LogLevel))
+	}
 	go http.ListenAndServe(":2112", nil)
 
+	// SIGHUP reloads the environment, and an interrupt shuts down.
 	sigch := make(chan os.Signal, 1)
-	signal.Notify(sigch, os.Interrupt)
-	<-sigch
-
-	for _, c := range registeredCommands {
-		if err := dg.ApplicationCommandDelete(environment.AppId, environment.DiscordServer, c.ID); err != nil {
-			slog.Error(fmt.Sprintf("error removing command: %s", err))
+	signal.Notify(sigch, os.Interrupt, syscall.SIGHUP)
+	for sig := range sigch {
+		if sig != syscall.SIGHUP {
+			break
+		}
+		if err := reload(); err != nil {
+			slog.Error(fmt.Sprintf("err reloading environment: %v", err))
 		}
 	}
+
+	bot.Unregister(environment.AppId, environment.DiscordServer)
 }
 
 func StartEvents(c *core.Core, l *state.Listener, channel string, conf env.EventConfig) error {
-	if conf.EnableShiny {
-		shinyEvent := events.NewShinyEvent(c, channel)
-		if err := c.RegisterEvent("shiny", shinyEvent); err != nil {
-			slog.Error(fmt.Sprintf("err registering event: %s", err))
+	for _, spec := range eventSpecs(conf) {
+		if _, err := startEvent(c, l, channel, spec); err != nil {
 			return err
 		}
-		l.Register(shinyEvent)
-	}
-	if conf.EnableAnti {
-		antiEvent := events.NewAntiShinyEvent(c, channel)
-		if err := c.RegisterEvent("anti", antiEvent); err != nil {
-			slog.Error(fmt.Sprintf("err registering event: %s", err))
-			return err
-		}
-		l.Register(antiEvent)
-	}
-	for _, itemConf := range conf.ItemEvent {
-		if itemConf.Enable {
-			itemEvent := events.NewItemEvent(c, itemConf, channel)
-			if err := c.RegisterEvent(itemEvent.ID, itemEvent); err != nil {
-				slog.Error(fmt.Sprintf("err registering event: %s", err))
-				return err
-			}
-			if itemConf.ReopenOnStart {
-				if err := itemEvent.Open(time.Now()); err != nil {
-					// This should be expected when the itemEvent is already open,
-					// so don't fail out here.
-					slog.Warn(fmt.Sprintf("err re-opening event on start: %v", err))
-				}
-			}
-			l.Register(itemEvent)
-		}
 	}
 	return nil
 }
@@ -187,4 +197,51 @@ func AddCrons(core *core.Core, environment *env.Environment) {
 		cron := crons.NewSelfBetCron(core, environment.AppId, conf.SelfBet.Every, environment.DiscordChannel)
 		core.AddCron(cron)
 	}
+	if conf.Season.Enable {
+		cron := crons.NewSeasonCron(core, conf.Season.Length, environment.DiscordChannel)
+		core.AddCron(cron)
+	}
+}
+
+// ApplyPolicies sets core's policies from the environment.  These can be
+// changed while running, unlike the rest of the environment.
+func ApplyPolicies(c *core.Core, environment *env.Environment) {
+	c.SetAllowance(AllowancePolicy(environment.Allowance))
+	c.SetLoans(LoanPolicy(environment.Loans))
+	c.SetLimits(WagerLimits(environment.Limits))
+	c.SetCancelWindow(environment.API.CancelWindow)
+}
+
+// AllowancePolicy converts the allowance config to the policy used by core.
+func AllowancePolicy(conf env.AllowanceConfig) core.AllowancePolicy {
+	policy := core.DefaultAllowancePolicy
+	if conf.Floor > 0 {
+		policy.Floor = conf.Floor
+	}
+	policy.BailoutCooldown = conf.BailoutCooldown
+	policy.Daily = conf.Daily
+	policy.StreakBonus = conf.StreakBonus
+	policy.MaxStreak = conf.MaxStreak
+	return policy
+}
+
+// LoanPolicy converts the loan config to the policy used by core.
+func LoanPolicy(conf env.LoanConfig) core.LoanPolicy {
+	return core.LoanPolicy{
+		Base:            conf.Base,
+		PerBet:          conf.PerBet,
+		Max:             conf.Max,
+		InterestPercent: conf.InterestPercent,
+		Term:            conf.Term,
+	}
+}
+
+// WagerLimits converts the limits config to the limits used by core.
+func WagerLimits(conf env.LimitsConfig) core.WagerLimits {
+	return core.WagerLimits{
+		MinBet:           conf.MinBet,
+		MaxBet:           conf.MaxBet,
+		MaxEventExposure: conf.MaxEventExposure,
+		MaxOpenBets:      conf.MaxOpenBets,
+	}
 }
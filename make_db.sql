@@ -21,15 +21,104 @@ CREATE TABLE bets(
 	PRIMARY KEY (uid, placed)
 );
 
+CREATE TABLE phases(
+	eid TEXT REFERENCES events(id),
+	ended TEXT,
+	encounters INT,
+	PRIMARY KEY (eid, ended)
+);
+
+CREATE TABLE jackpot(
+	ts TEXT,
+	eid TEXT REFERENCES events(id),
+	amount INT,
+	kind TEXT
+);
+
+CREATE TABLE grants(
+	uid TEXT REFERENCES users(id),
+	ts TEXT,
+	kind TEXT,
+	amount INT,
+	streak INT
+);
+
+CREATE TABLE loans(
+	uid TEXT PRIMARY KEY REFERENCES users(id),
+	owed INT,
+	taken TEXT,
+	charges INT,
+	defaulted INT
+);
+
+CREATE TABLE identities(
+	identity TEXT PRIMARY KEY,
+	uid TEXT REFERENCES users(id),
+	linked TEXT
+);
+
+CREATE TABLE api_tokens(
+	hash TEXT PRIMARY KEY,
+	uid TEXT REFERENCES users(id),
+	name TEXT,
+	created TEXT
+);
+
+CREATE TABLE houses(
+	id TEXT PRIMARY KEY REFERENCES users(id)
+);
+
+CREATE TABLE adjustments(
+	uid TEXT REFERENCES users(id),
+	ts TEXT,
+	amount INT,
+	operator TEXT,
+	reason TEXT
+);
+
 CREATE TABLE crons(
 	id TEXT PRIMARY KEY,
 	lastRun TEXT	
 );
 
+CREATE TABLE seasons(
+	season INT,
+	ended TEXT,
+	uid TEXT REFERENCES users(id),
+	balance INT,
+	rank INT,
+	PRIMARY KEY (season, uid)
+);
+
+CREATE TABLE achievements(
+	uid TEXT REFERENCES users(id),
+	aid TEXT,
+	awarded TEXT,
+	PRIMARY KEY (uid, aid)
+);
+
+CREATE TABLE donations(
+	giver TEXT REFERENCES users(id),
+	receiver TEXT REFERENCES users(id),
+	ts TEXT,
+	amount INT
+);
+
+CREATE TABLE results(
+	uid TEXT REFERENCES users(id),
+	eid TEXT REFERENCES events(id),
+	placed TEXT,
+	resolved TEXT,
+	outcome INT,
+	payout INT,
+	PRIMARY KEY (uid, placed)
+);
+
 CREATE VIEW leaderboard(id, balance, rank) AS
 SELECT id, balance, row_number() OVER()
 FROM (
   SELECT id, balance
   FROM users
+  WHERE id NOT IN (SELECT id FROM houses)
   ORDER BY balance DESC
 );
@@ -6,6 +6,7 @@ import (
 	"bet/core"
 	"bet/core/db"
 	"bet/core/events"
+	"bet/discord"
 	"bet/env"
 	"fmt"
 	"log/slog"
@@ -26,7 +27,7 @@ func main() {
 	nowStr := time.Now().Format("060102_150405")
 	logFile, err := os.Create(fmt.Sprintf("refund_%s.log", nowStr))
 	if err != nil {
-		fmt.Println("error creating a log file: %v", err)
+		fmt.Printf("error creating a log file: %v\n", err)
 		return
 	}
 	logger := slog.New(slog.NewTextHandler(logFile, &slog.HandlerOptions{Level: slog.LevelDebug}))
@@ -61,7 +62,7 @@ func main() {
 	defer database.Close()
 
 	// Create the core.
-	core := core.New(database, dg, realClock{})
+	core := core.New(database, discord.Messenger{Session: dg}, realClock{})
 	if core == nil {
 		slog.Error("could not create core, exiting")
 		return
@@ -180,7 +181,7 @@ func refund(c *core.Core, environment *env.RefundEnv) {
 	if environment.Event.ActualPhase == 0 {
 		event = events.NewItemEvent(c, env.ItemEventConfig{ID: EventID}, environment.DiscordChannel)
 	} else {
-		event = events.NewShinyEvent(c, environment.DiscordChannel)
+		event = events.NewShinyEvent(c, environment.Event.Odds, env.PhaseEventConfig{}, environment.DiscordChannel)
 	}
 	if err := event.Open(OpenTS); err != nil {
 		slog.Error(fmt.Sprintf("on open: %v", err))
@@ -223,7 +224,7 @@ func refund(c *core.Core, environment *env.RefundEnv) {
 	// 	slog.Error(fmt.Sprintf("tx commit #2: %v", err))
 	// 	return
 	// }
-	prevOpen = prevOpen
-	prevClose = prevClose
-	prevDetails = prevDetails
+	_ = prevOpen
+	_ = prevClose
+	_ = prevDetails
 }
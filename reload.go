@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bet/core"
+	"bet/core/commands"
+	"bet/core/crons"
+	"bet/core/events"
+	"bet/discord"
+	"bet/env"
+	"bet/state"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// eventSpec is the configuration of a single event.  Specs are compared on
+// reload to find the events that were added, removed or changed.
+type eventSpec struct {
+	ID string
+	// Exactly one of Phase and Item is set.
+	Phase *phaseSpec
+	Item  *env.ItemEventConfig
+}
+
+// phaseSpec is the configuration of the shiny or anti-shiny event.
+type phaseSpec struct {
+	Anti bool
+	Odds env.OddsConfig
+	Conf env.PhaseEventConfig
+}
+
+// eventSpecs returns the specs of the events enabled in the config, in the
+// order they're started.
+func eventSpecs(conf env.EventConfig) []eventSpec {
+	specs := make([]eventSpec, 0)
+	if conf.EnableShiny {
+		specs = append(specs, eventSpec{ID: "shiny", Phase: &phaseSpec{Odds: conf.Odds, Conf: conf.Shiny}})
+	}
+	if conf.EnableAnti {
+		specs = append(specs, eventSpec{ID: "anti", Phase: &phaseSpec{Anti: true, Odds: conf.Odds, Conf: conf.Anti}})
+	}
+	ids := commands.ItemEventIDs(conf)
+	i := 0
+	for _, itemConf := range conf.ItemEvent {
+		if itemConf.Enable {
+			itemConf := itemConf
+			specs = append(specs, eventSpec{ID: ids[i], Item: &itemConf})
+			i++
+		}
+	}
+	return specs
+}
+
+// commandConfig returns the event config that slash commands are built from,
+// for the events that are running.
+func commandConfig(specs []eventSpec) env.EventConfig {
+	var conf env.EventConfig
+	for _, spec := range specs {
+		switch {
+		case spec.Item != nil:
+			conf.ItemEvent = append(conf.ItemEvent, *spec.Item)
+		case spec.Phase.Anti:
+			conf.EnableAnti = true
+			conf.Anti = spec.Phase.Conf
+			conf.Odds = spec.Phase.Odds
+		default:
+			conf.EnableShiny = true
+			conf.Shiny = spec.Phase.Conf
+			conf.Odds = spec.Phase.Odds
+		}
+	}
+	return conf
+}
+
+// startEvent creates the event, registers it with core and the listener, and
+// reopens item events that are configured to.
+func startEvent(c *core.Core, l *state.Listener, channel string, spec eventSpec) (core.Event, error) {
+	var event interface {
+		core.Event
+		state.Observer
+	}
+	switch {
+	case spec.Item != nil:
+		event = events.NewItemEvent(c, *spec.Item, channel)
+	case spec.Phase.Anti:
+		event = events.NewAntiShinyEvent(c, spec.Phase.Odds, spec.Phase.Conf, channel)
+	default:
+		event = events.NewShinyEvent(c, spec.Phase.Odds, spec.Phase.Conf, channel)
+	}
+	if err := c.RegisterEvent(spec.ID, event); err != nil {
+		slog.Error(fmt.Sprintf("err registering event: %s", err))
+		return nil, err
+	}
+	if spec.Item != nil && spec.Item.ReopenOnStart {
+		if err := event.Open(time.Now()); err != nil {
+			// This should be expected when the itemEvent is already open,
+			// so don't fail out here.
+			slog.Warn(fmt.Sprintf("err re-opening event on start: %v", err))
+		}
+	}
+	l.Register(event)
+	return event, nil
+}
+
+// Reloader applies a reloaded environment to the running bot.  Events and
+// crons whose config changed are replaced, and the slash commands are
+// registered again so their choices match the events.
+type Reloader struct {
+	core     *core.Core
+	listener *state.Listener
+	bot      *discord.Bot
+	bet      *commands.BetCommand
+
+	mu          sync.Mutex
+	environment *env.Environment
+	// specs are the configurations of the running events, which may differ
+	// from the environment when an event with open bets was kept.
+	specs []eventSpec
+}
+
+func NewReloader(c *core.Core, l *state.Listener, bot *discord.Bot, bet *commands.BetCommand, environment *env.Environment) *Reloader {
+	return &Reloader{
+		core:        c,
+		listener:    l,
+		bot:         bot,
+		bet:         bet,
+		environment: environment,
+		specs:       eventSpecs(environment.Events),
+	}
+}
+
+// Reload re-reads the environment and applies it.  Events with open bets are
+// never dropped; they keep running with their old config until a later
+// reload finds them without bets.
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	reloaded, err := env.LoadEnvironemnt()
+	if err != nil {
+		return err
+	}
+	ApplyPolicies(r.core, reloaded)
+
+	errs := make([]error, 0)
+	if err := r.reloadEvents(reloaded); err != nil {
+		errs = append(errs, err)
+	}
+	r.reloadCrons(reloaded)
+
+	conf := commandConfig(r.specs)
+	r.bet.Configure(conf)
+	if err := r.bot.Register(reloaded.AppId, reloaded.DiscordServer, conf); err != nil {
+		errs = append(errs, fmt.Errorf("could not register commands: %w", err))
+	}
+	r.environment = reloaded
+	slog.Info("reloaded environment")
+	return errors.Join(errs...)
+}
+
+func (r *Reloader) reloadEvents(reloaded *env.Environment) error {
+	running := make(map[string]eventSpec)
+	for _, spec := range r.specs {
+		running[spec.ID] = spec
+	}
+	wanted := make(map[string]bool)
+	for _, spec := range eventSpecs(reloaded.Events) {
+		wanted[spec.ID] = true
+	}
+
+	specs := make([]eventSpec, 0)
+	// Stop the events that were removed or changed.
+	for _, spec := range r.specs {
+		if !wanted[spec.ID] {
+			if r.stopEvent(spec.ID) {
+				slog.Info(fmt.Sprintf("removed event %s", spec.ID))
+			} else {
+				specs = append(specs, spec)
+			}
+		}
+	}
+	errs := make([]error, 0)
+	for _, spec := range eventSpecs(reloaded.Events) {
+		old, ok := running[spec.ID]
+		if ok && reflect.DeepEqual(old, spec) {
+			specs = append(specs, old)
+			continue
+		}
+		if ok && !r.stopEvent(spec.ID) {
+			specs = append(specs, old)
+			continue
+		}
+		if _, err := startEvent(r.core, r.listener, reloaded.DiscordChannel, spec); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		slog.Info(fmt.Sprintf("started event %s", spec.ID))
+		specs = append(specs, spec)
+	}
+	r.specs = specs
+	return errors.Join(errs...)
+}
+
+// stopEvent unregisters the event from core and the listener, and returns
+// whether it was stopped.  Events with open bets keep running.
+func (r *Reloader) stopEvent(id string) bool {
+	event, err := r.core.GetEvent(id)
+	if err != nil {
+		slog.Error(fmt.Sprintf("err getting event %s to stop: %v", id, err))
+		return false
+	}
+	if err := r.core.UnregisterEvent(id); err != nil {
+		var openErr core.EventOpenBetsError
+		if errors.As(err, &openErr) {
+			slog.Warn(fmt.Sprintf("keeping event %s until its %d bets resolve", id, openErr.Bets))
+		} else {
+			slog.Error(fmt.Sprintf("err unregistering event %s: %v", id, err))
+		}
+		return false
+	}
+	if o, ok := event.(state.Observer); ok {
+		r.listener.Unregister(o)
+	}
+	return true
+}
+
+// reloadCrons replaces the crons whose config changed, and stops the crons
+// that were disabled.
+func (r *Reloader) reloadCrons(reloaded *env.Environment) {
+	old, conf := r.environment.Crons, reloaded.Crons
+	channelChanged := r.environment.DiscordChannel != reloaded.DiscordChannel
+	if old.SelfBet != conf.SelfBet || channelChanged || r.environment.AppId != reloaded.AppId {
+		r.removeCron("self-bet")
+		if conf.SelfBet.Enable {
+			r.core.AddCron(crons.NewSelfBetCron(r.core, reloaded.AppId, conf.SelfBet.Every, reloaded.DiscordChannel))
+		}
+	}
+	if old.Season != conf.Season || channelChanged {
+		r.removeCron("season")
+		if conf.Season.Enable {
+			r.core.AddCron(crons.NewSeasonCron(r.core, conf.Season.Length, reloaded.DiscordChannel))
+		}
+	}
+}
+
+func (r *Reloader) removeCron(id string) {
+	if err := r.core.RemoveCron(id); err != nil && !errors.Is(err, core.CronNotFoundError{ID: id}) {
+		slog.Error(fmt.Sprintf("err removing cron %s: %v", id, err))
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bet/core"
+	"bet/core/db"
+	"bet/env"
+	"bet/state"
+	"slices"
+	"testing"
+	"time"
+)
+
+func newTestReloader(t *testing.T, environment *env.Environment) *Reloader {
+	t.Helper()
+	c := core.New(db.Fake(), nil, nil)
+	l, err := state.NewListener("localhost:0", nil)
+	if err != nil {
+		t.Fatalf("could not start listener: %v", err)
+	}
+	t.Cleanup(l.Close)
+	r := &Reloader{core: c, listener: l, environment: environment, specs: eventSpecs(environment.Events)}
+	for _, spec := range r.specs {
+		if _, err := startEvent(c, l, environment.DiscordChannel, spec); err != nil {
+			t.Fatalf("startEvent(%s) returned unexpected error: %v", spec.ID, err)
+		}
+	}
+	return r
+}
+
+func itemConfig(id string, probability float64) env.ItemEventConfig {
+	return env.ItemEventConfig{Enable: true, ID: id, Species: "Pikachu", Item: "Light Ball", Probability: probability}
+}
+
+func TestReloadEvents(t *testing.T) {
+	r := newTestReloader(t, &env.Environment{Events: env.EventConfig{ItemEvent: []env.ItemEventConfig{
+		itemConfig("same", 0.5),
+		itemConfig("changed", 0.5),
+		itemConfig("removed", 0.5),
+		itemConfig("kept", 0.5),
+	}}})
+	// kept has an open bet, so it outlives its config.
+	kept, _ := r.core.GetEvent("kept")
+	now := time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)
+	if err := kept.Open(now); err != nil {
+		t.Fatalf("Open(kept) returned unexpected error: %v", err)
+	}
+	if _, err := r.core.Wager("kept", "user", 100, now, true); err != nil {
+		t.Fatalf("Wager(kept) returned unexpected error: %v", err)
+	}
+	same, _ := r.core.GetEvent("same")
+	changed, _ := r.core.GetEvent("changed")
+
+	reloaded := &env.Environment{Events: env.EventConfig{ItemEvent: []env.ItemEventConfig{
+		itemConfig("same", 0.5),
+		itemConfig("changed", 0.25),
+		itemConfig("added", 0.5),
+	}}}
+	if err := r.reloadEvents(reloaded); err != nil {
+		t.Fatalf("reloadEvents() returned unexpected error: %v", err)
+	}
+	if got, want := r.core.Events(), []string{"added", "changed", "kept", "same"}; !slices.Equal(got, want) {
+		t.Errorf("Events() = %v, want %v", got, want)
+	}
+	if e, _ := r.core.GetEvent("same"); e != same {
+		t.Errorf("the unchanged event was replaced")
+	}
+	if e, _ := r.core.GetEvent("changed"); e == changed {
+		t.Errorf("the changed event wasn't replaced")
+	}
+	if e, _ := r.core.GetEvent("kept"); e != kept {
+		t.Errorf("the event with open bets was replaced")
+	}
+	ids := make([]string, 0)
+	for _, spec := range r.specs {
+		ids = append(ids, spec.ID)
+		if spec.ID == "changed" && spec.Item.Probability != 0.25 {
+			t.Errorf("changed is running with probability %v, want 0.25", spec.Item.Probability)
+		}
+	}
+	// Commands are built from the specs, so the kept event can still be bet on.
+	if want := []string{"kept", "same", "changed", "added"}; !slices.Equal(ids, want) {
+		t.Errorf("specs are for %v, want %v", ids, want)
+	}
+}
+
+func TestReloadCrons(t *testing.T) {
+	r := newTestReloader(t, &env.Environment{})
+	selfBet := &env.Environment{
+		AppId: "app",
+		Crons: env.CronConfig{SelfBet: env.SelfBetConfig{Enable: true, Every: time.Hour}},
+	}
+	r.reloadCrons(selfBet)
+	if got, want := r.core.Crons(), []string{"self-bet"}; !slices.Equal(got, want) {
+		t.Errorf("Crons() = %v, want %v", got, want)
+	}
+
+	r.environment = selfBet
+	season := &env.Environment{
+		AppId: "app",
+		Crons: env.CronConfig{Season: env.SeasonConfig{Enable: true, Length: 24 * time.Hour}},
+	}
+	r.reloadCrons(season)
+	if got, want := r.core.Crons(), []string{"season"}; !slices.Equal(got, want) {
+		t.Errorf("Crons() after disabling self bets = %v, want %v", got, want)
+	}
+}
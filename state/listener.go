@@ -7,7 +7,9 @@ import (
 	"log/slog"
 	"net"
 	"net/http"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -25,9 +27,12 @@ var maxTimeBetweenEncounters = promauto.NewGauge(prometheus.GaugeOpts{
 })
 
 // Listener creates an HTTP server and listens for POST messages to update the
-// current state, and notifies registered events of state changes.
+// current state, and notifies registered events of state changes.  Other pages
+// can be served alongside with Handle.
 type Listener struct {
-	server          http.Server
+	server          *http.Server
+	mux             *http.ServeMux
+	observersMu     sync.Mutex
 	observers       []Observer
 	acl             []string
 	lastReceiveTime time.Time
@@ -45,19 +50,21 @@ type Observer interface {
 }
 
 func NewListener(address string, acl []string) (*Listener, error) {
-	server := http.Server{}
 	l, err := net.Listen("tcp", address)
 	if err != nil {
 		return nil, err
 	}
 
+	mux := http.NewServeMux()
+	server := &http.Server{Handler: mux}
 	listener := &Listener{
 		server:          server,
+		mux:             mux,
 		observers:       make([]Observer, 0),
 		acl:             acl,
 		lastReceiveTime: time.Now(),
 	}
-	http.Handle("/", listener)
+	mux.Handle("/", listener)
 
 	go server.Serve(l)
 	slog.Info(fmt.Sprintf("listening on %s", l.Addr()))
@@ -89,8 +96,11 @@ func (l *Listener) ServeHTTP(out http.ResponseWriter, in *http.Request) {
 	}
 	slog.Debug(fmt.Sprintf("parsed state: %+v", state))
 
+	l.observersMu.Lock()
+	observers := slices.Clone(l.observers)
+	l.observersMu.Unlock()
 	go func() {
-		for _, o := range l.observers {
+		for _, o := range observers {
 			// Intentionally serial to prevent database lock contention.
 			o.Notify(state)
 		}
@@ -118,6 +128,29 @@ func (l *Listener) Close() {
 	l.server.Shutdown(context.Background())
 }
 
+// Handle serves the handler for the pattern on the listener's address, next to
+// the state updates.  Only addresses in the ACL are served, like state updates.
+func (l *Listener) Handle(pattern string, h http.Handler) {
+	l.mux.Handle(pattern, http.HandlerFunc(func(out http.ResponseWriter, in *http.Request) {
+		if !l.checkAcl(in.RemoteAddr) {
+			out.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(out, in)
+	}))
+}
+
 func (l *Listener) Register(o Observer) {
+	l.observersMu.Lock()
+	defer l.observersMu.Unlock()
 	l.observers = append(l.observers, o)
 }
+
+// Unregister stops notifying the observer of state changes.
+func (l *Listener) Unregister(o Observer) {
+	l.observersMu.Lock()
+	defer l.observersMu.Unlock()
+	l.observers = slices.DeleteFunc(l.observers, func(r Observer) bool {
+		return r == o
+	})
+}
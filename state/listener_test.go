@@ -2,6 +2,7 @@ package state
 
 import (
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 )
@@ -74,3 +75,45 @@ func TestDecode(t *testing.T) {
 		t.Errorf("Decoded held item %s, want None", o.s.Encounter.HeldItem.Name)
 	}
 }
+
+func TestUnregister(t *testing.T) {
+	l, _ := NewListener("localhost:0", []string{})
+	defer l.Close()
+	kept := TestObserver{recv: make(chan struct{}, 1)}
+	removed := TestObserver{recv: make(chan struct{}, 1)}
+	l.Register(&removed)
+	l.Register(&kept)
+	l.Unregister(&removed)
+	in := http.Request{
+		Body:   &StringReadCloser{s: strings.NewReader(`{"encounter": {}}`)},
+		Method: "POST",
+	}
+	l.ServeHTTP(NoResponseWriter{}, &in)
+	<-kept.recv
+	if removed.s != nil {
+		t.Errorf("unregistered observer was notified")
+	}
+}
+
+func TestHandleAcl(t *testing.T) {
+	l, _ := NewListener("localhost:0", []string{"10.0.0.1"})
+	defer l.Close()
+	l.Handle("GET /page", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	for _, tc := range []struct {
+		addr string
+		want int
+	}{
+		{addr: "10.0.0.1:1234", want: http.StatusOK},
+		{addr: "10.0.0.2:1234", want: http.StatusUnauthorized},
+	} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/page", nil)
+		r.RemoteAddr = tc.addr
+		l.mux.ServeHTTP(w, r)
+		if w.Code != tc.want {
+			t.Errorf("GET /page from %s = %d, want %d", tc.addr, w.Code, tc.want)
+		}
+	}
+}
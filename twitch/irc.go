@@ -0,0 +1,61 @@
+package twitch
+
+import (
+	"strings"
+)
+
+// message is one line sent by the IRC server.
+type message struct {
+	// tags are the IRCv3 tags Twitch adds to messages, like the user-id and
+	// display-name of whoever sent it.
+	tags    map[string]string
+	prefix  string
+	command string
+	params  []string
+}
+
+// tagUnescaper reverses the escaping of IRCv3 tag values.
+var tagUnescaper = strings.NewReplacer(`\:`, ";", `\s`, " ", `\\`, `\`, `\r`, "\r", `\n`, "\n")
+
+func parseMessage(line string) message {
+	m := message{tags: make(map[string]string)}
+	if strings.HasPrefix(line, "@") {
+		var tags string
+		tags, line, _ = strings.Cut(line[1:], " ")
+		for _, tag := range strings.Split(tags, ";") {
+			k, v, _ := strings.Cut(tag, "=")
+			m.tags[k] = tagUnescaper.Replace(v)
+		}
+	}
+	if strings.HasPrefix(line, ":") {
+		m.prefix, line, _ = strings.Cut(line[1:], " ")
+	}
+	line, trailing, hasTrailing := strings.Cut(line, " :")
+	if strings.HasPrefix(line, ":") {
+		// The line is only the trailing parameter.
+		line, trailing, hasTrailing = "", line[1:], true
+	}
+	m.params = strings.Fields(line)
+	if len(m.params) > 0 {
+		m.command = m.params[0]
+		m.params = m.params[1:]
+	}
+	if hasTrailing {
+		m.params = append(m.params, trailing)
+	}
+	return m
+}
+
+// nick is the nickname of the user who sent the message.
+func (m message) nick() string {
+	nick, _, _ := strings.Cut(m.prefix, "!")
+	return nick
+}
+
+// text is the last parameter, which is the text of a chat message.
+func (m message) text() string {
+	if len(m.params) == 0 {
+		return ""
+	}
+	return m.params[len(m.params)-1]
+}
@@ -0,0 +1,182 @@
+package twitch
+
+import (
+	"bet/core/commands"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// UsageError is returned when a chat command's arguments couldn't be parsed.
+type UsageError struct {
+	Usage string
+}
+
+func (e UsageError) Error() string {
+	return fmt.Sprintf("usage: %s", e.Usage)
+}
+
+// parser converts the arguments of a chat command into the options of a
+// request.
+type parser struct {
+	usage string
+	parse func(args []string, r *commands.Request) bool
+}
+
+// parsers are the chat commands that can be run from Twitch, keyed by the
+// command name.  Commands that need to name another user aren't supported
+// since chat only knows them by name.
+var parsers = map[string]parser{
+	"bet": {
+		usage: "!bet <event> <amount> <over|under|exactly> <phase> or !bet <event> <amount> <yes|no>",
+		parse: parseBet,
+	},
+	"balance": {usage: "!balance", parse: noArgs},
+	"bets":    {usage: "!bets", parse: noArgs},
+	"daily":   {usage: "!daily", parse: noArgs},
+	"jackpot": {usage: "!jackpot", parse: noArgs},
+	"profile": {usage: "!profile", parse: noArgs},
+	"loan":    {usage: "!loan [amount]", parse: optionalInt("amount")},
+	"season":  {usage: "!season [number]", parse: optionalInt("number")},
+	"ledger":  {usage: "!ledger <event>", parse: requiredString("event")},
+	"soon":    {usage: "!soon <event>", parse: requiredString("event")},
+	"link":    {usage: "!link [code]", parse: optionalString("code")},
+	"leaderboard": {
+		usage: "!leaderboard [ranking] [page]",
+		parse: parseLeaderboard,
+	},
+}
+
+// directions maps the words chat can use for the side of a phase bet to the
+// over-under option.
+var directions = map[string]string{
+	"over":    ">",
+	"above":   ">",
+	">":       ">",
+	"under":   "<",
+	"below":   "<",
+	"<":       "<",
+	"exactly": "=",
+	"exact":   "=",
+	"=":       "=",
+}
+
+// guesses maps the words chat can use for an item bet to the guess option.
+var guesses = map[string]bool{
+	"yes":   true,
+	"y":     true,
+	"true":  true,
+	"no":    false,
+	"n":     false,
+	"false": false,
+}
+
+// parseCommand converts a chat message like "!bet shiny 500 under 8000" into
+// the name of the command and its request.  ok is false when the message isn't
+// a supported command.
+func parseCommand(text string, r *commands.Request) (name string, ok bool, err error) {
+	if !strings.HasPrefix(text, "!") {
+		return "", false, nil
+	}
+	fields := strings.Fields(text[1:])
+	if len(fields) == 0 {
+		return "", false, nil
+	}
+	name = strings.ToLower(fields[0])
+	p, ok := parsers[name]
+	if !ok {
+		return "", false, nil
+	}
+	if r.Options == nil {
+		r.Options = make(map[string]any)
+	}
+	if !p.parse(fields[1:], r) {
+		return name, true, UsageError{Usage: p.usage}
+	}
+	return name, true, nil
+}
+
+func parseBet(args []string, r *commands.Request) bool {
+	if len(args) != 3 && len(args) != 4 {
+		return false
+	}
+	r.Subcommand = strings.ToLower(args[0])
+	amount, err := strconv.Atoi(args[1])
+	if err != nil {
+		return false
+	}
+	r.Options["amount"] = amount
+	if len(args) == 3 {
+		guess, ok := guesses[strings.ToLower(args[2])]
+		if !ok {
+			return false
+		}
+		r.Options["guess"] = guess
+		return true
+	}
+	direction, ok := directions[strings.ToLower(args[2])]
+	if !ok {
+		return false
+	}
+	phase, err := strconv.Atoi(args[3])
+	if err != nil {
+		return false
+	}
+	r.Options["over-under"] = direction
+	r.Options["phase"] = phase
+	return true
+}
+
+func parseLeaderboard(args []string, r *commands.Request) bool {
+	if len(args) > 2 {
+		return false
+	}
+	for _, arg := range args {
+		if page, err := strconv.Atoi(arg); err == nil {
+			r.Options["page"] = page
+		} else {
+			r.Options["by"] = strings.ToLower(arg)
+		}
+	}
+	return true
+}
+
+func noArgs(args []string, r *commands.Request) bool {
+	return len(args) == 0
+}
+
+func optionalInt(name string) func(args []string, r *commands.Request) bool {
+	return func(args []string, r *commands.Request) bool {
+		if len(args) == 0 {
+			return true
+		}
+		if len(args) > 1 {
+			return false
+		}
+		v, err := strconv.Atoi(args[0])
+		if err != nil {
+			return false
+		}
+		r.Options[name] = v
+		return true
+	}
+}
+
+func requiredString(name string) func(args []string, r *commands.Request) bool {
+	return func(args []string, r *commands.Request) bool {
+		if len(args) != 1 {
+			return false
+		}
+		r.Options[name] = strings.ToLower(args[0])
+		return true
+	}
+}
+
+func optionalString(name string) func(args []string, r *commands.Request) bool {
+	return func(args []string, r *commands.Request) bool {
+		if len(args) == 0 {
+			return true
+		}
+		return requiredString(name)(args, r)
+	}
+}
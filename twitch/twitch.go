@@ -0,0 +1,325 @@
+// Package twitch adapts the platform-neutral commands to Twitch chat, so
+// viewers can bet from stream chat with messages like "!bet shiny 500 under
+// 8000", and sends core's messages to the chat.
+package twitch
+
+import (
+	"bet/core"
+	"bet/core/commands"
+	"bet/env"
+	"bufio"
+	"fmt"
+	"log/slog"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultServer is Twitch's chat IRC server.
+const DefaultServer = "irc.chat.twitch.tv:6667"
+
+// MaxMessageLength is the most characters Twitch allows in one chat message.
+const MaxMessageLength = 500
+
+// reconnectDelay is how long to wait before reconnecting after the connection
+// to chat drops.
+const reconnectDelay = 10 * time.Second
+
+// IdentityPrefix prefixes a Twitch user id to make their identity, so they
+// can't collide with the ids of Discord users.
+const IdentityPrefix = "twitch:"
+
+// Identity returns the identity of the Twitch user id.  See core.Core.Account.
+func Identity(twitchID string) string {
+	return IdentityPrefix + twitchID
+}
+
+// secretReply is sent in place of a secret response, like a link code, which
+// would be seen by everyone in chat.
+const secretReply = "that reply is secret, so run the command on Discord instead."
+
+// NotConnectedError is returned when sending a message while the bot isn't
+// connected to chat.
+type NotConnectedError struct{}
+
+func (e NotConnectedError) Error() string {
+	return "not connected to twitch chat"
+}
+
+// Client is a Twitch chat bot that runs commands sent in chat.
+type Client struct {
+	conf     env.TwitchConfig
+	channel  string
+	core     *core.Core
+	commands map[string]commands.Command
+	closed   chan struct{}
+	once     sync.Once
+	// mu guards the connection and names.
+	mu   sync.Mutex
+	conn net.Conn
+	// names are the display names of the accounts seen in chat, keyed by the
+	// account id, so mentions can be shown by name.
+	names map[string]string
+}
+
+// New creates a chat bot for the configured channel.  It doesn't connect until
+// Serve is called, so that it can be used as core's messenger before the
+// commands exist.
+func New(conf env.TwitchConfig) *Client {
+	if conf.Server == "" {
+		conf.Server = DefaultServer
+	}
+	return &Client{
+		conf:    conf,
+		channel: "#" + strings.ToLower(strings.TrimPrefix(conf.Channel, "#")),
+		closed:  make(chan struct{}),
+		names:   make(map[string]string),
+	}
+}
+
+// Serve connects to chat and runs the commands, which are keyed by their chat
+// command name, until Close is called.  The connection is retried whenever it
+// drops.
+func (c *Client) Serve(core *core.Core, cs map[string]commands.Command) {
+	c.core = core
+	c.commands = cs
+	for {
+		err := c.run()
+		select {
+		case <-c.closed:
+			return
+		default:
+		}
+		slog.Warn(fmt.Sprintf("disconnected from twitch chat, reconnecting: %v", err))
+		select {
+		case <-c.closed:
+			return
+		case <-time.After(reconnectDelay):
+		}
+	}
+}
+
+// Close disconnects from chat and stops Serve.
+func (c *Client) Close() error {
+	c.once.Do(func() { close(c.closed) })
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	return c.conn.Close()
+}
+
+// Send posts the message to the configured chat.  The channel is ignored since
+// core's messages are addressed to Discord channels, and the bot only joins
+// one chat.
+func (c *Client) Send(channel, message string) error {
+	for _, m := range chatMessages(c.render(message)) {
+		if err := c.write(fmt.Sprintf("PRIVMSG %s :%s", c.channel, m)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// run connects to chat and handles messages until the connection drops.
+func (c *Client) run() error {
+	conn, err := net.Dial("tcp", c.conf.Server)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	select {
+	case <-c.closed:
+		c.mu.Unlock()
+		conn.Close()
+		return nil
+	default:
+	}
+	c.conn = conn
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.conn = nil
+		c.mu.Unlock()
+		conn.Close()
+	}()
+
+	token := c.conf.Token
+	if token != "" && !strings.HasPrefix(token, "oauth:") {
+		token = "oauth:" + token
+	}
+	login := []string{
+		// The tags capability adds the user-id of the sender to every message.
+		"CAP REQ :twitch.tv/tags twitch.tv/commands",
+		fmt.Sprintf("PASS %s", token),
+		fmt.Sprintf("NICK %s", strings.ToLower(c.conf.Nick)),
+		fmt.Sprintf("JOIN %s", c.channel),
+	}
+	for _, line := range login {
+		if err := c.write(line); err != nil {
+			return err
+		}
+	}
+	slog.Info(fmt.Sprintf("joined twitch chat %s", c.channel))
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		m := parseMessage(strings.TrimRight(scanner.Text(), "\r"))
+		switch m.command {
+		case "PING":
+			if err := c.write(fmt.Sprintf("PONG :%s", m.text())); err != nil {
+				return err
+			}
+		case "PRIVMSG":
+			c.privmsg(m)
+		case "RECONNECT":
+			return fmt.Errorf("server asked to reconnect")
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("connection closed")
+}
+
+// privmsg runs the command in a chat message, if it has one, and replies in
+// chat.
+func (c *Client) privmsg(m message) {
+	twitchID := m.tags["user-id"]
+	if twitchID == "" {
+		return
+	}
+	name := m.tags["display-name"]
+	if name == "" {
+		name = m.nick()
+	}
+	identity := Identity(twitchID)
+	uid := c.core.Account(identity)
+	c.mu.Lock()
+	c.names[uid] = name
+	c.mu.Unlock()
+
+	r := commands.Request{UID: uid, Identity: identity, Time: sentTime(m)}
+	command, ok, err := parseCommand(m.text(), &r)
+	if !ok {
+		return
+	}
+	cmd, ok := c.commands[command]
+	if !ok {
+		return
+	}
+	var content string
+	if err != nil {
+		content = fmt.Sprintf("Sorry, I didn't understand that, %v", err)
+	} else {
+		slog.Debug(fmt.Sprintf("twitch command from %s: %s", identity, m.text()))
+		response := cmd.Handle(r)
+		// Whispers can't be sent over IRC, so private responses are posted
+		// in chat too, addressed to the user who ran the command.  Secret
+		// ones are withheld.
+		content = response.Content
+		if response.Secret {
+			content = secretReply
+		}
+	}
+	// Every reply is addressed to the user who ran the command.
+	if !strings.Contains(content, fmt.Sprintf("<@%s>", uid)) {
+		content = fmt.Sprintf("@%s %s", name, content)
+	}
+	if err := c.Send(c.channel, content); err != nil {
+		slog.Warn(fmt.Sprintf("could not reply in twitch chat: %v", err))
+	}
+}
+
+// sentTime is when the message was sent according to Twitch, falling back to
+// when it was received.
+func sentTime(m message) time.Time {
+	if ms, err := strconv.ParseInt(m.tags["tmi-sent-ts"], 10, 64); err == nil {
+		return time.UnixMilli(ms)
+	}
+	return time.Now()
+}
+
+func (c *Client) write(line string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return NotConnectedError{}
+	}
+	_, err := c.conn.Write([]byte(line + "\r\n"))
+	return err
+}
+
+var (
+	mentionPattern   = regexp.MustCompile(`<@!?([^>]+)>`)
+	timestampPattern = regexp.MustCompile(`<t:(-?\d+)(:[a-zA-Z])?>`)
+)
+
+// render converts a message written for Discord to plain chat text.  Mentions
+// are shown by name when the user has been seen in chat, and timestamps are
+// shown in UTC.
+func (c *Client) render(message string) string {
+	c.mu.Lock()
+	message = mentionPattern.ReplaceAllStringFunc(message, func(mention string) string {
+		uid := mentionPattern.FindStringSubmatch(mention)[1]
+		if name, ok := c.names[uid]; ok {
+			return "@" + name
+		}
+		return uid
+	})
+	c.mu.Unlock()
+	message = timestampPattern.ReplaceAllStringFunc(message, func(ts string) string {
+		unix, err := strconv.ParseInt(timestampPattern.FindStringSubmatch(ts)[1], 10, 64)
+		if err != nil {
+			return ts
+		}
+		return time.Unix(unix, 0).UTC().Format(time.DateTime) + " UTC"
+	})
+	return strings.NewReplacer("**", "", "`", "").Replace(message)
+}
+
+// chatMessages splits a message into chat messages.  Chat messages are a
+// single line, so the lines are joined with separators, and split into as many
+// messages as it takes to stay under MaxMessageLength.
+func chatMessages(message string) []string {
+	const separator = " | "
+	messages := make([]string, 0)
+	current := strings.Builder{}
+	// length is the number of characters in current, which can be fewer than
+	// its bytes.
+	length := 0
+	for _, line := range strings.Split(message, "\n") {
+		runes := []rune(strings.TrimSpace(line))
+		if len(runes) == 0 {
+			continue
+		}
+		for len(runes) > MaxMessageLength {
+			if length > 0 {
+				messages = append(messages, current.String())
+				current.Reset()
+				length = 0
+			}
+			messages = append(messages, string(runes[:MaxMessageLength]))
+			runes = runes[MaxMessageLength:]
+		}
+		if length > 0 && length+len(separator)+len(runes) > MaxMessageLength {
+			messages = append(messages, current.String())
+			current.Reset()
+			length = 0
+		}
+		if length > 0 {
+			current.WriteString(separator)
+			length += len(separator)
+		}
+		current.WriteString(string(runes))
+		length += len(runes)
+	}
+	if current.Len() > 0 {
+		messages = append(messages, current.String())
+	}
+	return messages
+}
@@ -0,0 +1,245 @@
+package twitch
+
+import (
+	"bet/core"
+	"bet/core/commands"
+	"bet/core/db"
+	"bet/env"
+	"bufio"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeServer is a stand-in for the Twitch IRC server that accepts one
+// connection.
+type fakeServer struct {
+	listener net.Listener
+	conn     net.Conn
+	lines    *bufio.Scanner
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return &fakeServer{listener: l}
+}
+
+func (s *fakeServer) accept(t *testing.T) {
+	conn, err := s.listener.Accept()
+	if err != nil {
+		t.Fatalf("could not accept: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	s.conn = conn
+	s.lines = bufio.NewScanner(conn)
+}
+
+func (s *fakeServer) send(t *testing.T, line string) {
+	if _, err := fmt.Fprintf(s.conn, "%s\r\n", line); err != nil {
+		t.Fatalf("could not send %q: %v", line, err)
+	}
+}
+
+func (s *fakeServer) read(t *testing.T) string {
+	if !s.lines.Scan() {
+		t.Fatalf("connection ended early: %v", s.lines.Err())
+	}
+	return strings.TrimRight(s.lines.Text(), "\r")
+}
+
+// fakeCommand records the requests it handles.
+type fakeCommand struct {
+	requests chan commands.Request
+	content  string
+}
+
+func (c *fakeCommand) Handle(r commands.Request) commands.Response {
+	c.requests <- r
+	return commands.Response{Content: c.content}
+}
+
+func TestClient(t *testing.T) {
+	server := newFakeServer(t)
+	bet := &fakeCommand{requests: make(chan commands.Request, 1), content: "<@user1> put 500 cakes on the shiny phase."}
+	client := New(env.TwitchConfig{
+		Server:  server.listener.Addr().String(),
+		Nick:    "BetBot",
+		Token:   "secret",
+		Channel: "Streamer",
+	})
+	defer client.Close()
+	c := core.New(db.Fake(), client, nil)
+	balance := &commands.BalanceCommand{Core: c}
+	// The viewer's Twitch identity is linked to a Discord user's account.
+	code, err := c.LinkCode("user1", time.Now())
+	if err != nil {
+		t.Fatalf("could not make link code: %v", err)
+	}
+	if _, err := c.Link("twitch:42", code, time.Now()); err != nil {
+		t.Fatalf("could not link: %v", err)
+	}
+	link := &commands.LinkCommand{Core: c}
+	go client.Serve(c, map[string]commands.Command{"bet": bet, "balance": balance, "link": link})
+
+	server.accept(t)
+	login := []string{
+		"CAP REQ :twitch.tv/tags twitch.tv/commands",
+		"PASS oauth:secret",
+		"NICK betbot",
+		"JOIN #streamer",
+	}
+	for _, want := range login {
+		if got := server.read(t); got != want {
+			t.Errorf("login got %q, want %q", got, want)
+		}
+	}
+
+	server.send(t, "PING :tmi.twitch.tv")
+	if got, want := server.read(t), "PONG :tmi.twitch.tv"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	server.send(t, `@display-name=Viewer;tmi-sent-ts=1700000000000;user-id=42 :viewer!viewer@viewer.tmi.twitch.tv PRIVMSG #streamer :!bet shiny 500 under 8000`)
+	r := <-bet.requests
+	want := commands.Request{
+		UID:        "user1",
+		Identity:   "twitch:42",
+		Time:       time.UnixMilli(1700000000000),
+		Subcommand: "shiny",
+		Options:    map[string]any{"amount": 500, "over-under": "<", "phase": 8000},
+	}
+	if !reflect.DeepEqual(r, want) {
+		t.Errorf("got request %+v, want %+v", r, want)
+	}
+	if got, want := server.read(t), "PRIVMSG #streamer :@Viewer put 500 cakes on the shiny phase."; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// Private responses are posted in chat, addressed to the user.
+	server.send(t, `@display-name=Viewer;user-id=42 :viewer!viewer@viewer.tmi.twitch.tv PRIVMSG #streamer :!balance`)
+	if got, want := server.read(t), "PRIVMSG #streamer :@Viewer You have 1000 cakes (0 in bets)"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// Secret responses, like link codes, are withheld, but link outcomes are
+	// posted.
+	server.send(t, `@display-name=Viewer;user-id=42 :viewer!viewer@viewer.tmi.twitch.tv PRIVMSG #streamer :!link`)
+	if got, want := server.read(t), "PRIVMSG #streamer :@Viewer "+secretReply; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	server.send(t, `@display-name=Viewer;user-id=42 :viewer!viewer@viewer.tmi.twitch.tv PRIVMSG #streamer :!link NOPE`)
+	if got, want := server.read(t), "PRIVMSG #streamer :@Viewer That link code is invalid or has expired."; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	server.send(t, `@display-name=Viewer;user-id=42 :viewer!viewer@viewer.tmi.twitch.tv PRIVMSG #streamer :!bet shiny lots`)
+	if got, want := server.read(t), "PRIVMSG #streamer :@Viewer Sorry, I didn't understand that, usage: "+parsers["bet"].usage; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// Messages from core are sent to chat with mentions of known users.
+	if err := client.Send("discord-channel", "The shiny phase was 7000 encounters.\n<@user1> won 1000 cakes."); err != nil {
+		t.Fatalf("could not send: %v", err)
+	}
+	if got, want := server.read(t), "PRIVMSG #streamer :The shiny phase was 7000 encounters. | @Viewer won 1000 cakes."; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestSendNotConnected(t *testing.T) {
+	client := New(env.TwitchConfig{Channel: "streamer"})
+	if err := client.Send("", "hello"); err == nil {
+		t.Errorf("expected an error sending while not connected")
+	}
+}
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		text       string
+		wantName   string
+		wantOk     bool
+		wantErr    bool
+		subcommand string
+		options    map[string]any
+	}{
+		{text: "hello chat"},
+		{text: "!unknown 1 2 3"},
+		{text: "!bet shiny 500 under 8000", wantName: "bet", wantOk: true, subcommand: "shiny", options: map[string]any{"amount": 500, "over-under": "<", "phase": 8000}},
+		{text: "!BET Anti 10 over 20", wantName: "bet", wantOk: true, subcommand: "anti", options: map[string]any{"amount": 10, "over-under": ">", "phase": 20}},
+		{text: "!bet shiny 1 exactly 42", wantName: "bet", wantOk: true, subcommand: "shiny", options: map[string]any{"amount": 1, "over-under": "=", "phase": 42}},
+		{text: "!bet item 100 yes", wantName: "bet", wantOk: true, subcommand: "item", options: map[string]any{"amount": 100, "guess": true}},
+		{text: "!bet item 100 no", wantName: "bet", wantOk: true, subcommand: "item", options: map[string]any{"amount": 100, "guess": false}},
+		{text: "!bet shiny 500 sideways 8000", wantName: "bet", wantOk: true, wantErr: true},
+		{text: "!bet shiny", wantName: "bet", wantOk: true, wantErr: true},
+		{text: "!balance", wantName: "balance", wantOk: true, options: map[string]any{}},
+		{text: "!balance someone", wantName: "balance", wantOk: true, wantErr: true},
+		{text: "!loan 50", wantName: "loan", wantOk: true, options: map[string]any{"amount": 50}},
+		{text: "!ledger shiny", wantName: "ledger", wantOk: true, options: map[string]any{"event": "shiny"}},
+		{text: "!ledger", wantName: "ledger", wantOk: true, wantErr: true},
+		{text: "!leaderboard profit 2", wantName: "leaderboard", wantOk: true, options: map[string]any{"by": "profit", "page": 2}},
+	}
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			var r commands.Request
+			name, ok, err := parseCommand(test.text, &r)
+			if name != test.wantName || ok != test.wantOk || (err != nil) != test.wantErr {
+				t.Fatalf("got (%q, %t, %v), want (%q, %t, error %t)", name, ok, err, test.wantName, test.wantOk, test.wantErr)
+			}
+			if !ok || err != nil {
+				return
+			}
+			if r.Subcommand != test.subcommand {
+				t.Errorf("got subcommand %q, want %q", r.Subcommand, test.subcommand)
+			}
+			if !reflect.DeepEqual(r.Options, test.options) {
+				t.Errorf("got options %v, want %v", r.Options, test.options)
+			}
+		})
+	}
+}
+
+func TestParseMessage(t *testing.T) {
+	m := parseMessage(`@display-name=Some\sViewer;user-id=42 :viewer!viewer@viewer.tmi.twitch.tv PRIVMSG #streamer :!bet shiny 500 under 8000`)
+	if m.command != "PRIVMSG" {
+		t.Errorf("got command %q, want PRIVMSG", m.command)
+	}
+	if m.tags["display-name"] != "Some Viewer" || m.tags["user-id"] != "42" {
+		t.Errorf("got tags %v", m.tags)
+	}
+	if m.nick() != "viewer" {
+		t.Errorf("got nick %q, want viewer", m.nick())
+	}
+	if want := []string{"#streamer", "!bet shiny 500 under 8000"}; !reflect.DeepEqual(m.params, want) {
+		t.Errorf("got params %q, want %q", m.params, want)
+	}
+}
+
+func TestChatMessages(t *testing.T) {
+	long := strings.Repeat("a", MaxMessageLength-10)
+	got := chatMessages("one\n\ntwo\n" + long)
+	want := []string{"one | two", long}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	for _, m := range chatMessages(strings.Repeat("b", MaxMessageLength*2+1)) {
+		if len(m) > MaxMessageLength {
+			t.Errorf("message of length %d is too long", len(m))
+		}
+	}
+	// Lines are split by character, so multi-byte characters aren't cut in
+	// half.
+	wide := strings.Repeat("🍰", MaxMessageLength+1)
+	got = chatMessages(wide)
+	want = []string{strings.Repeat("🍰", MaxMessageLength), "🍰"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}